@@ -0,0 +1,1830 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/handlers"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/lottie"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/testenv"
+	"github.com/andybalholm/brotli"
+)
+
+// server démarre le mux complet de main.go (avec CORS) sur un environnement
+// de test hors ligne
+type server struct {
+	*httptest.Server
+	env *testenv.Env
+}
+
+func newTestServer(t *testing.T) *server {
+	t.Helper()
+	env := testenv.New(t)
+	srv := httptest.NewServer(newServer(env.Handler))
+	t.Cleanup(srv.Close)
+	return &server{Server: srv, env: env}
+}
+
+// do envoie une requête ; body peut être nil, un []byte ou une valeur encodée en JSON
+func (s *server) do(t *testing.T, method, path, apiKey string, body interface{}) *http.Response {
+	t.Helper()
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case []byte:
+		reader = bytes.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, s.URL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// upload envoie un fichier via le formulaire multipart de /v1/files et renvoie son CID
+func (s *server) upload(t *testing.T, apiKey, name, mimeType string, content []byte, private bool) string {
+	t.Helper()
+	resp := s.uploadForm(t, apiKey, name, mimeType, content, map[string]string{"is_private": fmt.Sprint(private)})
+	expectStatus(t, resp, http.StatusOK)
+
+	var out struct {
+		CID string `json:"cid"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil || out.CID == "" {
+		t.Fatalf("réponse d'upload invalide : %v", err)
+	}
+	return out.CID
+}
+
+// uploadForm envoie un fichier avec les champs de formulaire donnés
+func (s *server) uploadForm(t *testing.T, apiKey, name, mimeType string, content []byte, fields map[string]string) *http.Response {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, name))
+	h.Set("Content-Type", mimeType)
+	part, _ := mw.CreatePart(h)
+	part.Write(content)
+	mw.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, s.URL+"/v1/files", &buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("X-API-Key", apiKey)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func expectStatus(t *testing.T, resp *http.Response, want int) {
+	t.Helper()
+	if resp.StatusCode != want {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("%s %s : statut %d, attendu %d (%s)", resp.Request.Method, resp.Request.URL.Path, resp.StatusCode, want, strings.TrimSpace(string(body)))
+	}
+}
+
+func decode(t *testing.T, resp *http.Response, out interface{}) {
+	t.Helper()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		t.Fatalf("%s %s : JSON invalide : %v", resp.Request.Method, resp.Request.URL.Path, err)
+	}
+}
+
+func readBody(t *testing.T, resp *http.Response) []byte {
+	t.Helper()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+type filePage struct {
+	Files []struct {
+		CID       string `json:"cid"`
+		FileName  string `json:"file_name"`
+		IsPrivate bool   `json:"is_private"`
+	} `json:"files"`
+	Total      int `json:"total"`
+	Page       int `json:"page"`
+	Limit      int `json:"limit"`
+	TotalPages int `json:"totalPages"`
+}
+
+func TestFileLifecycle(t *testing.T) {
+	s := newTestServer(t)
+	content := []byte("\x89PNG\r\n\x1a\nfake image")
+
+	// Upload → liste
+	cid := s.upload(t, testenv.WriteKey, "logo.png", "image/png", content, false)
+	privateCID := s.upload(t, testenv.WriteKey, "notes.txt", "text/plain", []byte("secret"), true)
+
+	var public filePage
+	resp := s.do(t, http.MethodGet, "/v1/files", testenv.ReadKey, nil)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &public)
+	if public.Total != 1 || public.Files[0].CID != cid {
+		t.Errorf("fichiers publics = %+v, attendu uniquement %s", public, cid)
+	}
+
+	var mine filePage
+	resp = s.do(t, http.MethodGet, "/v1/account/files", testenv.WriteKey, nil)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &mine)
+	if mine.Total != 2 {
+		t.Errorf("fichiers de la clé : %d, attendu 2", mine.Total)
+	}
+
+	// Recherche : seul le fichier public est indexé
+	var search struct {
+		Results []map[string]interface{} `json:"results"`
+		Total   int                      `json:"total"`
+	}
+	resp = s.do(t, http.MethodGet, "/v1/files/search?query=logo.png", "", nil)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &search)
+	if search.Total != 1 || search.Results[0]["cid"] != cid {
+		t.Errorf("recherche = %+v", search)
+	}
+	resp = s.do(t, http.MethodGet, "/v1/files/search?query=notes.txt", "", nil)
+	decode(t, resp, &search)
+	if search.Total != 0 {
+		t.Errorf("un fichier privé apparaît dans la recherche : %+v", search)
+	}
+
+	// Récupération
+	resp = s.do(t, http.MethodGet, "/v1/files/"+cid, testenv.ReadKey, nil)
+	expectStatus(t, resp, http.StatusOK)
+	if got := readBody(t, resp); !bytes.Equal(got, content) {
+		t.Errorf("contenu = %q", got)
+	}
+	resp = s.do(t, http.MethodGet, "/v1/files/"+cid+"/image", "", nil)
+	expectStatus(t, resp, http.StatusOK)
+	if resp.Header.Get("Content-Type") != "image/png" {
+		t.Errorf("Content-Type = %q", resp.Header.Get("Content-Type"))
+	}
+	expectStatus(t, s.do(t, http.MethodGet, "/v1/files/"+privateCID+"/display", "", nil), http.StatusNotFound)
+
+	// Basculement de visibilité
+	var toggled struct {
+		IsPrivate bool `json:"is_private"`
+	}
+	expectStatus(t, s.do(t, http.MethodPost, "/v1/files/"+cid+"/toggle-private", testenv.OtherKey, nil), http.StatusNotFound)
+	resp = s.do(t, http.MethodPost, "/v1/files/"+cid+"/toggle-private", testenv.WriteKey, nil)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &toggled)
+	if !toggled.IsPrivate {
+		t.Error("le fichier devrait être privé")
+	}
+	expectStatus(t, s.do(t, http.MethodGet, "/v1/files/"+cid+"/display", "", nil), http.StatusNotFound)
+	expectStatus(t, s.do(t, http.MethodGet, "/v1/files/"+cid+"/private-image", testenv.WriteKey, nil), http.StatusOK)
+	expectStatus(t, s.do(t, http.MethodGet, "/v1/files/"+cid+"/private-image", testenv.OtherKey, nil), http.StatusUnauthorized)
+
+	// Suppression : réservée au propriétaire, désépingle le contenu
+	expectStatus(t, s.do(t, http.MethodDelete, "/v1/files/"+cid, testenv.OtherKey, nil), http.StatusNotFound)
+	expectStatus(t, s.do(t, http.MethodDelete, "/v1/files/"+cid, testenv.WriteKey, nil), http.StatusOK)
+	expectStatus(t, s.do(t, http.MethodGet, "/v1/files/"+cid, testenv.WriteKey, nil), http.StatusNotFound)
+	if s.env.IPFS.Has(cid) {
+		t.Error("le contenu supprimé est toujours épinglé")
+	}
+}
+
+func TestFileDetails(t *testing.T) {
+	s := newTestServer(t)
+	cid := s.upload(t, testenv.WriteKey, "notes.txt", "text/plain", []byte("notes"), false)
+	path := "/v1/files/" + cid
+
+	resp := s.do(t, http.MethodPatch, path, testenv.WriteKey, map[string]interface{}{"display_name": " Logo ", "tags": []string{"Brand", "brand", " png "}})
+	expectStatus(t, resp, http.StatusOK)
+	var file map[string]interface{}
+	decode(t, resp, &file)
+	if file["display_name"] != "Logo" || file["description"] != "" || fmt.Sprint(file["tags"]) != "[brand png]" || file["file_name"] != "notes.txt" {
+		t.Errorf("fichier modifié = %+v", file)
+	}
+	// Le patch conserve les champs absents et null les efface
+	resp = s.do(t, http.MethodPatch, path, testenv.WriteKey, map[string]interface{}{"description": "Logo du site", "display_name": nil})
+	expectStatus(t, resp, http.StatusOK)
+	file = nil
+	decode(t, resp, &file)
+	if file["display_name"] != "" || file["description"] != "Logo du site" || fmt.Sprint(file["tags"]) != "[brand png]" {
+		t.Errorf("fichier modifié = %+v", file)
+	}
+
+	var public struct {
+		Files []map[string]interface{} `json:"files"`
+	}
+	decode(t, s.do(t, http.MethodGet, "/v1/files", testenv.WriteKey, nil), &public)
+	if len(public.Files) != 1 || public.Files[0]["description"] != "Logo du site" || public.Files[0]["display_name"] != nil {
+		t.Errorf("fichiers listés = %+v", public.Files)
+	}
+	var results struct {
+		Results []map[string]interface{} `json:"results"`
+	}
+	decode(t, s.do(t, http.MethodGet, "/v1/files/search?query=tags:brand", testenv.WriteKey, nil), &results)
+	if len(results.Results) != 1 || results.Results[0]["cid"] != cid {
+		t.Errorf("recherche par tag = %+v", results.Results)
+	}
+
+	for name, body := range map[string]map[string]interface{}{
+		"champ inconnu":    {"file_name": "x.png"},
+		"tag avec virgule": {"tags": []string{"a,b"}},
+		"nom trop long":    {"display_name": strings.Repeat("x", 256)},
+	} {
+		if resp := s.do(t, http.MethodPatch, path, testenv.WriteKey, body); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s : statut %d, attendu 400", name, resp.StatusCode)
+		}
+	}
+	expectStatus(t, s.do(t, http.MethodPatch, path, testenv.OtherKey, map[string]interface{}{"description": "x"}), http.StatusNotFound)
+	expectStatus(t, s.do(t, http.MethodPatch, path, "", map[string]interface{}{"description": "x"}), http.StatusUnauthorized)
+}
+
+func TestImageResizing(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	var src bytes.Buffer
+	png.Encode(&src, image.NewRGBA(image.Rect(0, 0, 400, 200)))
+	cid := s.upload(t, testenv.WriteKey, "wide.png", "image/png", src.Bytes(), false)
+
+	// Miniatures générées à l'upload
+	variants, err := s.env.Store.Variants.ListBySource(ctx, cid)
+	if err != nil || len(variants) != 2 {
+		t.Fatalf("miniatures après upload = %+v, %v", variants, err)
+	}
+
+	// Une nouvelle taille n'est générée qu'avec une API key, puis servie
+	// depuis le cache à tous
+	expectStatus(t, s.do(t, http.MethodGet, "/v1/files/"+cid+"/image?w=100&format=jpeg", "", nil), http.StatusUnauthorized)
+	for _, key := range []string{testenv.ReadKey, ""} {
+		resp := s.do(t, http.MethodGet, "/v1/files/"+cid+"/image?w=100&format=jpeg", key, nil)
+		expectStatus(t, resp, http.StatusOK)
+		cfg, format, err := image.DecodeConfig(resp.Body)
+		if err != nil || format != "jpeg" || cfg.Width != 100 || cfg.Height != 50 {
+			t.Fatalf("variante = %dx%d %s, %v", cfg.Width, cfg.Height, format, err)
+		}
+	}
+	// La seconde requête est servie depuis le cache
+	if variants, _ = s.env.Store.Variants.ListBySource(ctx, cid); len(variants) != 3 {
+		t.Errorf("%d variantes en cache, attendu 3", len(variants))
+	}
+
+	expectStatus(t, s.do(t, http.MethodGet, "/v1/files/"+cid+"/image?w=5000", "", nil), http.StatusBadRequest)
+	expectStatus(t, s.do(t, http.MethodGet, "/v1/files/"+cid+"/image?w=10&format=webp", "", nil), http.StatusBadRequest)
+	expectStatus(t, s.do(t, http.MethodGet, "/file/img?cid="+cid+"&w=10&h=10&fit=cover", testenv.ReadKey, nil), http.StatusOK)
+
+	svg := s.upload(t, testenv.WriteKey, "icon.svg", "image/svg+xml", []byte("<svg/>"), false)
+	expectStatus(t, s.do(t, http.MethodGet, "/v1/files/"+svg+"/image?w=10", testenv.ReadKey, nil), http.StatusUnsupportedMediaType)
+
+	// Le nombre de tailles par image est borné
+	for w := 20; w < 32; w++ {
+		expectStatus(t, s.do(t, http.MethodGet, fmt.Sprintf("/v1/files/%s/image?w=%d", cid, w), testenv.ReadKey, nil), http.StatusOK)
+	}
+	expectStatus(t, s.do(t, http.MethodGet, "/v1/files/"+cid+"/image?w=99", testenv.ReadKey, nil), http.StatusForbidden)
+	expectStatus(t, s.do(t, http.MethodGet, "/v1/files/"+cid+"/image?w=20", "", nil), http.StatusOK)
+	if variants, _ = s.env.Store.Variants.ListBySource(ctx, cid); len(variants) != 16 {
+		t.Errorf("%d variantes en cache, attendu 16", len(variants))
+	}
+
+	// Les variantes disparaissent avec le fichier
+	expectStatus(t, s.do(t, http.MethodDelete, "/v1/files/"+cid, testenv.WriteKey, nil), http.StatusOK)
+	for _, v := range variants {
+		if s.env.IPFS.Has(v.CID) {
+			t.Errorf("variante %s toujours épinglée", v.VariantKey)
+		}
+	}
+	if variants, _ = s.env.Store.Variants.ListBySource(ctx, cid); len(variants) != 0 {
+		t.Errorf("variantes restantes : %+v", variants)
+	}
+}
+
+func TestImageMetadata(t *testing.T) {
+	s := newTestServer(t)
+
+	// JPEG 30x20 portant un bloc XMP avec des coordonnées GPS
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 30, 20)), nil)
+	xmp := "http://ns.adobe.com/xap/1.0/\x00<exif:GPSLatitude>48,51N</exif:GPSLatitude>"
+	photo := append([]byte{0xFF, 0xD8, 0xFF, 0xE1, byte((len(xmp) + 2) >> 8), byte(len(xmp) + 2)}, xmp...)
+	photo = append(photo, buf.Bytes()[2:]...)
+
+	upload := func(name string, strip string) map[string]interface{} {
+		fields := map[string]string{"is_private": "false"}
+		if strip != "" {
+			fields["strip_metadata"] = strip
+		}
+		resp := s.uploadForm(t, testenv.WriteKey, name, "image/jpeg", photo, fields)
+		expectStatus(t, resp, http.StatusOK)
+		var out map[string]interface{}
+		decode(t, resp, &out)
+		return out
+	}
+	gpsKept := func(cid string) bool {
+		content, err := s.env.IPFS.DownloadFileFromIPFS(cid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bytes.Contains(content, []byte("GPSLatitude"))
+	}
+
+	kept := upload("kept.jpg", "")
+	if kept["width"] != 30.0 || kept["height"] != 20.0 || kept["color_model"] != "gray" || kept["metadata_stripped"] != false {
+		t.Errorf("réponse d'upload = %+v", kept)
+	}
+	if !gpsKept(kept["cid"].(string)) {
+		t.Error("les métadonnées ont été retirées sans être demandées")
+	}
+
+	stripped := upload("stripped.jpg", "true")
+	if stripped["metadata_stripped"] != true || gpsKept(stripped["cid"].(string)) {
+		t.Errorf("métadonnées non retirées : %+v", stripped)
+	}
+
+	// Réglage par défaut de la clé, que le formulaire peut contredire
+	if _, err := s.env.DB.Exec("UPDATE api_keys SET strip_metadata = TRUE WHERE api_key = ?", testenv.WriteKey); err != nil {
+		t.Fatal(err)
+	}
+	if out := upload("default.jpg", ""); gpsKept(out["cid"].(string)) {
+		t.Error("le réglage strip_metadata de la clé est ignoré")
+	}
+	if out := upload("optout.jpg", "false"); !gpsKept(out["cid"].(string)) {
+		t.Error("strip_metadata=false n'a pas priorité sur la clé")
+	}
+	var page struct {
+		Files []map[string]interface{} `json:"files"`
+	}
+	resp := s.do(t, http.MethodGet, "/v1/files?limit=1", testenv.ReadKey, nil)
+	decode(t, resp, &page)
+	if page.Files[0]["width"] != 30.0 || page.Files[0]["orientation"] != 1.0 {
+		t.Errorf("liste des fichiers = %+v", page.Files[0])
+	}
+
+	// Le type se lit dans le contenu, pas dans le Content-Type annoncé
+	resp = s.uploadForm(t, testenv.WriteKey, "photo.bin", "application/octet-stream", photo, map[string]string{"is_private": "false"})
+	expectStatus(t, resp, http.StatusOK)
+	var out map[string]interface{}
+	decode(t, resp, &out)
+	if out["metadata_stripped"] != true || gpsKept(out["cid"].(string)) {
+		t.Errorf("JPEG annoncé en application/octet-stream : %+v", out)
+	}
+	// Une image indécodable garderait ses métadonnées : refusée
+	resp = s.uploadForm(t, testenv.WriteKey, "photo.heic", "image/heic", []byte("\x00\x00\x00\x18ftypheic"), map[string]string{"is_private": "false"})
+	expectStatus(t, resp, http.StatusBadRequest)
+}
+
+func TestLottieUploads(t *testing.T) {
+	s := newTestServer(t)
+	animation := []byte(`{"v":"5.7.4","fr":25,"ip":0,"op":50,"w":100,"h":80,"layers":[{"ty":4}],` +
+		`"assets":[{"id":"i","p":"data:image/png;base64,AA","e":1}]}`)
+	notLottie := []byte(`{"name":"config"}`)
+	public := map[string]string{"is_private": "false"}
+	lottie := map[string]string{"is_private": "false", "kind": "lottie"}
+
+	// Reconnue automatiquement, même envoyée en application/octet-stream
+	resp := s.uploadForm(t, testenv.WriteKey, "loader.json", "application/octet-stream", animation, public)
+	expectStatus(t, resp, http.StatusOK)
+	var up map[string]interface{}
+	decode(t, resp, &up)
+	if up["kind"] != "lottie" || up["duration"] != 2.0 || up["layer_count"] != 1.0 || up["asset_count"] != 1.0 || up["width"] != 100.0 {
+		t.Errorf("réponse d'upload = %+v", up)
+	}
+	cid := up["cid"].(string)
+
+	// kind=lottie rejette un contenu non conforme, sans kind il est stocké tel quel
+	expectStatus(t, s.uploadForm(t, testenv.WriteKey, "config.json", "application/json", notLottie, lottie), http.StatusBadRequest)
+	expectStatus(t, s.uploadForm(t, testenv.WriteKey, "a.png", "image/png", []byte("png"), lottie), http.StatusBadRequest)
+	expectStatus(t, s.uploadForm(t, testenv.WriteKey, "a.json", "application/json", animation, map[string]string{"is_private": "false", "kind": "gif"}), http.StatusBadRequest)
+	config := s.upload(t, testenv.WriteKey, "config.json", "application/json", notLottie, false)
+
+	resp = s.do(t, http.MethodGet, "/v1/files/"+cid+"/lottie", testenv.ReadKey, nil)
+	expectStatus(t, resp, http.StatusOK)
+	if resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Content-Type = %q", resp.Header.Get("Content-Type"))
+	}
+	expectStatus(t, s.do(t, http.MethodGet, "/v1/files/"+config+"/lottie", testenv.ReadKey, nil), http.StatusBadRequest)
+
+	// Métadonnées indexées
+	var search struct {
+		Results []map[string]interface{} `json:"results"`
+		Total   int                      `json:"total"`
+	}
+	resp = s.do(t, http.MethodGet, "/v1/files/search?query=%2Bkind:lottie%20%2Bduration:<3", "", nil)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &search)
+	if search.Total != 1 || search.Results[0]["cid"] != cid || search.Results[0]["frame_rate"] != 25.0 {
+		t.Errorf("recherche = %+v", search)
+	}
+
+	var page struct {
+		Files []map[string]interface{} `json:"files"`
+	}
+	resp = s.do(t, http.MethodGet, "/v1/account/files", testenv.WriteKey, nil)
+	decode(t, resp, &page)
+	if page.Files[0]["frame_rate"] != 25.0 || page.Files[1]["kind"] != nil {
+		t.Errorf("liste des fichiers = %+v", page.Files)
+	}
+}
+
+func TestLottieCompression(t *testing.T) {
+	s := newTestServer(t)
+	layers := strings.Repeat(`{"ty":4,"nm":"shape","mn":"ADBE Vector Group","ks":{"o":{"a":0,"k":100.00001}}},`, 40)
+	animation := []byte(`{"v":"5.7.4","fr":30,"ip":0,"op":60,"w":64,"h":64,"meta":{"g":"editor"},` +
+		`"layers":[` + strings.TrimSuffix(layers, ",") + `]}`)
+
+	// optimize=true réécrit l'animation avant son ajout à IPFS
+	resp := s.uploadForm(t, testenv.WriteKey, "loader.json", "application/json", animation,
+		map[string]string{"is_private": "false", "optimize": "true", "precision": "2"})
+	expectStatus(t, resp, http.StatusOK)
+	var up map[string]interface{}
+	decode(t, resp, &up)
+	cid := up["cid"].(string)
+	if up["layer_count"] != 40.0 {
+		t.Errorf("réponse d'upload = %+v", up)
+	}
+
+	// Les encodages sont précalculés dès l'upload
+	variants, err := s.env.Store.Variants.ListBySource(context.Background(), cid)
+	if err != nil || len(variants) != 2 {
+		t.Fatalf("variantes = %+v, %v", variants, err)
+	}
+
+	get := func(acceptEncoding string) (*http.Response, []byte) {
+		req, _ := http.NewRequest(http.MethodGet, s.URL+"/v1/files/"+cid+"/lottie", nil)
+		req.Header.Set("X-API-Key", testenv.ReadKey)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body := readBody(t, resp)
+		if resp.Header.Get("Vary") != "Accept-Encoding" {
+			t.Errorf("Vary = %q", resp.Header.Get("Vary"))
+		}
+		if resp.Header.Get("Content-Length") != fmt.Sprint(len(body)) {
+			t.Errorf("Content-Length = %s pour %d octets", resp.Header.Get("Content-Length"), len(body))
+		}
+		return resp, body
+	}
+
+	resp, plain := get("identity")
+	expectStatus(t, resp, http.StatusOK)
+	if resp.Header.Get("Content-Encoding") != "" || len(plain) >= len(animation) {
+		t.Errorf("identity : Content-Encoding %q, %d octets", resp.Header.Get("Content-Encoding"), len(plain))
+	}
+	for _, gone := range []string{`"meta"`, `"mn"`, `100.00001`} {
+		if bytes.Contains(plain, []byte(gone)) {
+			t.Errorf("%s toujours présent après optimisation", gone)
+		}
+	}
+
+	resp, body := get("gzip, br")
+	if resp.Header.Get("Content-Encoding") != "br" {
+		t.Fatalf("Content-Encoding = %q, attendu br", resp.Header.Get("Content-Encoding"))
+	}
+	if decoded, _ := io.ReadAll(brotli.NewReader(bytes.NewReader(body))); !bytes.Equal(decoded, plain) {
+		t.Error("contenu brotli incorrect")
+	}
+
+	resp, body = get("gzip;q=1, br;q=0.5")
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Content-Encoding = %q, attendu gzip", resp.Header.Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded, _ := io.ReadAll(zr); !bytes.Equal(decoded, plain) {
+		t.Error("contenu gzip incorrect")
+	}
+
+	// Une variante en cache est servie sans relire l'original sur IPFS
+	if err := s.env.IPFS.UnpinFileFromIPFS(cid); err != nil {
+		t.Fatal(err)
+	}
+	if resp, _ := get("br"); resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Encoding") != "br" {
+		t.Errorf("br sans original : %d, Content-Encoding %q", resp.StatusCode, resp.Header.Get("Content-Encoding"))
+	}
+	if resp, _ := get("identity"); resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("identity sans original : %d", resp.StatusCode)
+	}
+
+	// optimize n'accepte que des animations Lottie
+	expectStatus(t, s.uploadForm(t, testenv.WriteKey, "a.png", "image/png", []byte("png"),
+		map[string]string{"is_private": "false", "optimize": "true"}), http.StatusBadRequest)
+	expectStatus(t, s.uploadForm(t, testenv.WriteKey, "a.json", "application/json", animation,
+		map[string]string{"is_private": "false", "optimize": "true", "precision": "9"}), http.StatusBadRequest)
+}
+
+func TestDotLottie(t *testing.T) {
+	s := newTestServer(t)
+	animation := []byte(`{"v":"5.7.4","fr":30,"ip":0,"op":60,"w":64,"h":64,"layers":[{"ty":4}]}`)
+	archive, err := lottie.Pack("spinner", animation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public := map[string]string{"is_private": "false"}
+
+	// Reconnue par son extension ; une archive invalide est rejetée
+	resp := s.uploadForm(t, testenv.WriteKey, "spinner.lottie", "application/octet-stream", archive, public)
+	expectStatus(t, resp, http.StatusOK)
+	var up map[string]interface{}
+	decode(t, resp, &up)
+	if up["kind"] != "dotlottie" || up["animation_count"] != 1.0 || up["duration"] != 2.0 {
+		t.Errorf("réponse d'upload = %+v", up)
+	}
+	cid := up["cid"].(string)
+	expectStatus(t, s.uploadForm(t, testenv.WriteKey, "broken.lottie", "application/zip", []byte("PK\x03\x04"), public), http.StatusBadRequest)
+	expectStatus(t, s.uploadForm(t, testenv.WriteKey, "a.json", "application/json", animation,
+		map[string]string{"is_private": "false", "kind": "dotlottie"}), http.StatusBadRequest)
+
+	// Archive entière, ou une animation extraite
+	resp = s.do(t, http.MethodGet, "/v1/files/"+cid+"/lottie", testenv.ReadKey, nil)
+	expectStatus(t, resp, http.StatusOK)
+	if got := readBody(t, resp); resp.Header.Get("Content-Type") != "application/zip" || !bytes.Equal(got, archive) {
+		t.Errorf("archive servie en %q", resp.Header.Get("Content-Type"))
+	}
+	resp = s.do(t, http.MethodGet, "/v1/files/"+cid+"/lottie?animation=spinner", testenv.ReadKey, nil)
+	expectStatus(t, resp, http.StatusOK)
+	if got := readBody(t, resp); !bytes.Equal(got, animation) {
+		t.Errorf("animation extraite = %s", got)
+	}
+	expectStatus(t, s.do(t, http.MethodGet, "/v1/files/"+cid+"/lottie?animation=absent", testenv.ReadKey, nil), http.StatusNotFound)
+
+	var manifest struct {
+		ActiveAnimationID string                   `json:"active_animation_id"`
+		Animations        []map[string]interface{} `json:"animations"`
+		Themes            []interface{}            `json:"themes"`
+	}
+	resp = s.do(t, http.MethodGet, "/v1/files/"+cid+"/lottie/manifest", testenv.ReadKey, nil)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &manifest)
+	if manifest.ActiveAnimationID != "spinner" || len(manifest.Animations) != 1 || manifest.Themes == nil {
+		t.Errorf("manifest = %+v", manifest)
+	}
+
+	// Conversion d'un Lottie JSON
+	jsonCID := s.upload(t, testenv.WriteKey, "Mon loader.json", "application/json", animation, true)
+	expectStatus(t, s.do(t, http.MethodGet, "/v1/files/"+jsonCID+"/lottie/manifest", testenv.ReadKey, nil), http.StatusNotFound)
+	expectStatus(t, s.do(t, http.MethodPost, "/v1/files/"+jsonCID+"/dotlottie", testenv.OtherKey, nil), http.StatusNotFound)
+	resp = s.do(t, http.MethodPost, "/v1/files/"+jsonCID+"/dotlottie?is_private=false", testenv.WriteKey, nil)
+	expectStatus(t, resp, http.StatusCreated)
+	var conv map[string]interface{}
+	decode(t, resp, &conv)
+	if conv["file_name"] != "Mon loader.lottie" || conv["kind"] != "dotlottie" || conv["is_private"] != false {
+		t.Errorf("conversion = %+v", conv)
+	}
+	resp = s.do(t, http.MethodGet, "/v1/files/"+conv["cid"].(string)+"/lottie?animation=Mon-loader", testenv.ReadKey, nil)
+	expectStatus(t, resp, http.StatusOK)
+
+	// Le paramètre animation ne concerne que les archives
+	plain := s.upload(t, testenv.WriteKey, "plain.json", "application/json", animation, false)
+	expectStatus(t, s.do(t, http.MethodGet, "/v1/files/"+plain+"/lottie?animation=x", testenv.ReadKey, nil), http.StatusBadRequest)
+	expectStatus(t, s.do(t, http.MethodPost, "/v1/files/"+cid+"/dotlottie", testenv.WriteKey, nil), http.StatusBadRequest)
+}
+
+func TestPagination(t *testing.T) {
+	s := newTestServer(t)
+	for i := 0; i < 3; i++ {
+		s.upload(t, testenv.WriteKey, fmt.Sprintf("f%d.txt", i), "text/plain", []byte(fmt.Sprint(i)), false)
+	}
+
+	cases := []struct {
+		query                     string
+		page, limit, files, pages int
+	}{
+		{"", 1, 10, 3, 1},
+		{"?page=0&limit=-1", 1, 10, 3, 1},
+		{"?page=abc&limit=xyz", 1, 10, 3, 1},
+		{"?page=2&limit=2", 2, 2, 1, 2},
+		{"?page=1&limit=1", 1, 1, 1, 3},
+		{"?page=9&limit=2", 9, 2, 0, 2},
+	}
+	for _, c := range cases {
+		var got filePage
+		resp := s.do(t, http.MethodGet, "/v1/files"+c.query, testenv.ReadKey, nil)
+		expectStatus(t, resp, http.StatusOK)
+		decode(t, resp, &got)
+		if got.Page != c.page || got.Limit != c.limit || len(got.Files) != c.files || got.TotalPages != c.pages || got.Total != 3 {
+			t.Errorf("%q : page=%d limit=%d fichiers=%d pages=%d total=%d", c.query, got.Page, got.Limit, len(got.Files), got.TotalPages, got.Total)
+		}
+	}
+}
+
+func TestDocsCRUD(t *testing.T) {
+	s := newTestServer(t)
+
+	expectStatus(t, s.do(t, http.MethodPost, "/v1/docs", testenv.ReadKey, map[string]interface{}{"title": "x"}), http.StatusUnauthorized)
+	expectStatus(t, s.do(t, http.MethodPost, "/v1/docs", testenv.WriteKey, map[string]interface{}{"title": "x", "parent_id": 99}), http.StatusBadRequest)
+
+	var parent, child map[string]interface{}
+	resp := s.do(t, http.MethodPost, "/v1/docs", testenv.WriteKey, map[string]interface{}{"status": "published", "title": "Guides", "path": "/guides", "doc_src": "# Guides"})
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &parent)
+	parentID := int(parent["id"].(float64))
+
+	resp = s.do(t, http.MethodPost, "/v1/docs", testenv.WriteKey, map[string]interface{}{"status": "published", "title": "Setup", "path": "/guides/setup", "doc_src": "Install", "parent_id": parentID})
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &child)
+	childID := int(child["id"].(float64))
+
+	var docs []map[string]interface{}
+	resp = s.do(t, http.MethodGet, "/v1/docs", "", nil)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &docs)
+	if len(docs) != 2 {
+		t.Fatalf("%d documents, attendu 2", len(docs))
+	}
+
+	update := map[string]interface{}{"title": "Installation", "path": "/guides/setup", "doc_src": "Install v2", "parent_id": parentID}
+	expectStatus(t, s.do(t, http.MethodPut, fmt.Sprintf("/v1/docs/%d", childID), testenv.WriteKey, update), http.StatusOK)
+
+	var got map[string]interface{}
+	resp = s.do(t, http.MethodGet, fmt.Sprintf("/docs/get?id=%d", childID), "", nil)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &got)
+	if got["title"] != "Installation" || got["doc_src"] != "Install v2" {
+		t.Errorf("document mis à jour = %+v", got)
+	}
+
+	expectStatus(t, s.do(t, http.MethodDelete, fmt.Sprintf("/v1/docs/%d", childID), testenv.WriteKey, nil), http.StatusOK)
+	expectStatus(t, s.do(t, http.MethodGet, fmt.Sprintf("/v1/docs/%d", childID), "", nil), http.StatusNotFound)
+	expectStatus(t, s.do(t, http.MethodGet, "/v1/docs/abc", "", nil), http.StatusBadRequest)
+}
+
+func TestDocsPatch(t *testing.T) {
+	s := newTestServer(t)
+	var parent, doc map[string]interface{}
+	decode(t, s.do(t, http.MethodPost, "/v1/docs", testenv.WriteKey, map[string]interface{}{"status": "published", "title": "Guides", "path": "/guides", "doc_src": "# Guides"}), &parent)
+	resp := s.do(t, http.MethodPost, "/v1/docs", testenv.WriteKey, map[string]interface{}{"status": "published", "title": "Setup", "path": "/guides/setup", "doc_src": "Install", "parent_id": parent["id"]})
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &doc)
+	path := fmt.Sprintf("/v1/docs/%d", int(doc["id"].(float64)))
+
+	// Les champs absents du patch sont conservés
+	expectStatus(t, s.do(t, http.MethodPatch, path, testenv.WriteKey, map[string]interface{}{"doc_src": "Install v2"}), http.StatusOK)
+	doc = nil
+	decode(t, s.do(t, http.MethodGet, path, "", nil), &doc)
+	if doc["title"] != "Setup" || doc["path"] != "/guides/setup" || doc["doc_src"] != "Install v2" || doc["parent_id"] != parent["id"] {
+		t.Errorf("document après patch = %+v", doc)
+	}
+	// null efface le champ : parent_id null rattache au premier niveau
+	expectStatus(t, s.do(t, http.MethodPatch, path, testenv.WriteKey, map[string]interface{}{"parent_id": nil, "title": "Installation"}), http.StatusOK)
+	doc = nil
+	decode(t, s.do(t, http.MethodGet, path, "", nil), &doc)
+	if doc["title"] != "Installation" || doc["doc_src"] != "Install v2" || doc["parent_id"] != nil || doc["position"] != 2.0 {
+		t.Errorf("document après patch = %+v", doc)
+	}
+
+	req, _ := http.NewRequest(http.MethodPatch, s.URL+path, strings.NewReader(`{"title":"x"}`))
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("X-API-Key", testenv.WriteKey)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("patch text/plain : statut %d, attendu 415", resp.StatusCode)
+	}
+	expectStatus(t, s.do(t, http.MethodPatch, path, testenv.WriteKey, map[string]interface{}{"parent_id": doc["id"]}), http.StatusConflict)
+	expectStatus(t, s.do(t, http.MethodPatch, "/v1/docs/999", testenv.WriteKey, map[string]interface{}{"title": "x"}), http.StatusNotFound)
+	expectStatus(t, s.do(t, http.MethodPatch, path, testenv.ReadKey, map[string]interface{}{"title": "x"}), http.StatusUnauthorized)
+}
+
+func TestDocsByPath(t *testing.T) {
+	s := newTestServer(t)
+	create := func(title, path string, parentID interface{}) int {
+		t.Helper()
+		resp := s.do(t, http.MethodPost, "/v1/docs", testenv.WriteKey, map[string]interface{}{"status": "published", "title": title, "path": path, "doc_src": title, "parent_id": parentID})
+		expectStatus(t, resp, http.StatusOK)
+		var doc map[string]interface{}
+		decode(t, resp, &doc)
+		return int(doc["id"].(float64))
+	}
+	guides := create("Guides", "/guides", nil)
+	intro := create("Intro", "/guides/intro", guides)
+	setup := create("Setup", "/guides/setup", guides)
+	create("FAQ", "/guides/faq", guides)
+	install := create("Install", "/guides/setup/install", setup)
+
+	type link struct {
+		ID   int    `json:"id"`
+		Path string `json:"path"`
+	}
+	var nav struct {
+		Doc struct {
+			ID    int    `json:"id"`
+			Title string `json:"title"`
+		} `json:"doc"`
+		Breadcrumbs []link `json:"breadcrumbs"`
+		Prev        *link  `json:"prev"`
+		Next        *link  `json:"next"`
+	}
+	resp := s.do(t, http.MethodGet, "/v1/docs/by-path?path=/guides/setup/", "", nil)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &nav)
+	if nav.Doc.ID != setup || len(nav.Breadcrumbs) != 1 || nav.Breadcrumbs[0].ID != guides ||
+		nav.Prev == nil || nav.Prev.ID != intro || nav.Next == nil || nav.Next.Path != "/guides/faq" {
+		t.Errorf("navigation de /guides/setup = %+v", nav)
+	}
+	nav.Breadcrumbs, nav.Prev, nav.Next = nil, nil, nil
+	decode(t, s.do(t, http.MethodGet, "/v1/docs/by-path?path=/guides/setup/install", "", nil), &nav)
+	if nav.Doc.ID != install || len(nav.Breadcrumbs) != 2 || nav.Breadcrumbs[0].ID != guides || nav.Breadcrumbs[1].ID != setup || nav.Prev != nil || nav.Next != nil {
+		t.Errorf("navigation de /guides/setup/install = %+v", nav)
+	}
+
+	// Le chemin est unique par parent
+	expectStatus(t, s.do(t, http.MethodPost, "/v1/docs", testenv.WriteKey, map[string]interface{}{"title": "x", "path": "/guides/intro", "parent_id": guides}), http.StatusConflict)
+	expectStatus(t, s.do(t, http.MethodPatch, fmt.Sprintf("/v1/docs/%d", intro), testenv.WriteKey, map[string]interface{}{"path": "/guides/faq"}), http.StatusConflict)
+	expectStatus(t, s.do(t, http.MethodPost, fmt.Sprintf("/v1/docs/%d/move", install), testenv.WriteKey, map[string]interface{}{"parent_id": guides}), http.StatusOK)
+	// ... mais peut se répéter sous un autre parent, que parent_id désigne
+	create("Setup bis", "/guides/setup/install", nil)
+	expectStatus(t, s.do(t, http.MethodGet, "/v1/docs/by-path?path=/guides/setup/install", "", nil), http.StatusConflict)
+	resp = s.do(t, http.MethodGet, fmt.Sprintf("/v1/docs/by-path?path=/guides/setup/install&parent_id=%d", guides), "", nil)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &nav)
+	if nav.Doc.ID != install {
+		t.Errorf("document sous %d = %+v", guides, nav.Doc)
+	}
+	expectStatus(t, s.do(t, http.MethodDelete, fmt.Sprintf("/v1/docs/%d?mode=reparent", guides), testenv.WriteKey, nil), http.StatusConflict)
+
+	expectStatus(t, s.do(t, http.MethodGet, "/v1/docs/by-path?path=/inconnu", "", nil), http.StatusNotFound)
+	expectStatus(t, s.do(t, http.MethodGet, "/v1/docs/by-path", "", nil), http.StatusBadRequest)
+}
+
+func TestDocsHTML(t *testing.T) {
+	s := newTestServer(t)
+	create := func(title, path, src string) int {
+		t.Helper()
+		resp := s.do(t, http.MethodPost, "/v1/docs", testenv.WriteKey, map[string]interface{}{"status": "published", "title": title, "path": path, "doc_src": src})
+		expectStatus(t, resp, http.StatusOK)
+		var doc map[string]interface{}
+		decode(t, resp, &doc)
+		return int(doc["id"].(float64))
+	}
+	create("FAQ", "/guides/faq", "# FAQ")
+	cid := "bafy0123456789abcdef0123456789abcdef01234567"
+	id := create("Setup", "/guides/setup", "# Installation\n\nVoir la [FAQ](faq.md#compte) et [ailleurs](absent.md).\n\n"+
+		"![schéma](ipfs://"+cid+")\n\n## Étapes\n\n<script>alert(1)</script>\n")
+
+	var rendered struct {
+		DocSrc string `json:"doc_src"`
+		HTML   string `json:"html"`
+		TOC    []struct {
+			Level int    `json:"level"`
+			ID    string `json:"id"`
+		} `json:"toc"`
+	}
+	resp := s.do(t, http.MethodGet, fmt.Sprintf("/v1/docs/%d?format=html", id), "", nil)
+	expectStatus(t, resp, http.StatusOK)
+	if resp.Header.Get("ETag") != "" {
+		t.Errorf("ETag sur le rendu HTML : %q", resp.Header.Get("ETag"))
+	}
+	decode(t, resp, &rendered)
+	for _, want := range []string{`<h1 id="installation">`, `<h2 id="étapes">`, `href="/guides/faq#compte"`, `href="absent.md"`, `src="/v1/files/` + cid + `/display"`} {
+		if !strings.Contains(rendered.HTML, want) {
+			t.Errorf("HTML sans %q :\n%s", want, rendered.HTML)
+		}
+	}
+	if strings.Contains(rendered.HTML, "<script") || rendered.DocSrc == "" || len(rendered.TOC) != 2 || rendered.TOC[1].Level != 2 {
+		t.Errorf("rendu = %+v", rendered)
+	}
+
+	var nav struct {
+		Doc struct {
+			HTML string `json:"html"`
+		} `json:"doc"`
+	}
+	resp = s.do(t, http.MethodGet, "/v1/docs/by-path?path=/guides/faq&format=html", "", nil)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &nav)
+	if !strings.Contains(nav.Doc.HTML, `<h1 id="faq">FAQ</h1>`) {
+		t.Errorf("rendu par chemin = %q", nav.Doc.HTML)
+	}
+	expectStatus(t, s.do(t, http.MethodGet, fmt.Sprintf("/v1/docs/%d?format=pdf", id), "", nil), http.StatusBadRequest)
+}
+
+func TestDocsSearch(t *testing.T) {
+	s := newTestServer(t)
+	create := func(title, path, src string, parentID interface{}) int {
+		t.Helper()
+		resp := s.do(t, http.MethodPost, "/v1/docs", testenv.WriteKey, map[string]interface{}{"status": "published", "title": title, "path": path, "doc_src": src, "parent_id": parentID})
+		expectStatus(t, resp, http.StatusOK)
+		var doc map[string]interface{}
+		decode(t, resp, &doc)
+		return int(doc["id"].(float64))
+	}
+	type results struct {
+		Results []struct {
+			ID        int                 `json:"id"`
+			Path      string              `json:"path"`
+			Fragments map[string][]string `json:"fragments"`
+		} `json:"results"`
+		Total int `json:"total"`
+	}
+	search := func(query string) results {
+		t.Helper()
+		resp := s.do(t, http.MethodGet, "/v1/docs/search?"+query, "", nil)
+		expectStatus(t, resp, http.StatusOK)
+		var res results
+		decode(t, resp, &res)
+		return res
+	}
+	ids := func(res results) []int {
+		out := []int{}
+		for _, r := range res.Results {
+			out = append(out, r.ID)
+		}
+		return out
+	}
+
+	guides := create("Guides", "/guides", "Sommaire des guides", nil)
+	mentions := create("Sommaire", "/guides/sommaire", "Voir le **déploiement** [détaillé](deploy.md)", guides)
+	deploy := create("Déploiement", "/guides/deploy", "Étapes pour mettre en production", guides)
+	other := create("Notes", "/notes", "Un déploiement manuel", nil)
+
+	// Le titre pèse plus que le corps ; le Markdown est retiré des extraits
+	res := search("q=d%C3%A9ploiement")
+	if got := ids(res); res.Total != 3 || got[0] != deploy || res.Results[0].Path != "/guides/deploy" {
+		t.Fatalf("recherche = %+v", res)
+	}
+	for _, r := range res.Results {
+		if r.ID == mentions {
+			if body := strings.Join(r.Fragments["body"], ""); !strings.Contains(body, "<mark>déploiement</mark>") || strings.Contains(body, "**") || strings.Contains(body, "](") {
+				t.Errorf("extrait = %q", body)
+			}
+		}
+	}
+	if got := ids(search(fmt.Sprintf("q=d%%C3%%A9ploiement&root=%d", guides))); len(got) != 2 || got[0] == other || got[1] == other {
+		t.Errorf("recherche sous %d = %v", guides, got)
+	}
+
+	// Mises à jour, déplacements et suppressions suivent
+	expectStatus(t, s.do(t, http.MethodPatch, fmt.Sprintf("/v1/docs/%d", other), testenv.WriteKey, map[string]interface{}{"doc_src": "Rien"}), http.StatusOK)
+	expectStatus(t, s.do(t, http.MethodPost, fmt.Sprintf("/v1/docs/%d/move", deploy), testenv.WriteKey, map[string]interface{}{"parent_id": nil}), http.StatusOK)
+	if got := ids(search(fmt.Sprintf("q=d%%C3%%A9ploiement&root=%d", guides))); len(got) != 1 || got[0] != mentions {
+		t.Errorf("recherche sous %d après déplacement = %v", guides, got)
+	}
+	expectStatus(t, s.do(t, http.MethodDelete, fmt.Sprintf("/v1/docs/%d?mode=cascade", guides), testenv.WriteKey, nil), http.StatusOK)
+	if got := ids(search("q=d%C3%A9ploiement")); len(got) != 1 || got[0] != deploy {
+		t.Errorf("recherche après suppression = %v", got)
+	}
+
+	// L'index se reconstruit depuis la table docs
+	for _, id := range []int{deploy, other, mentions} {
+		s.env.DocsIndex.Delete(strconv.Itoa(id))
+	}
+	if err := handler.IndexDocs(context.Background(), s.env.Store.Docs, s.env.DocsIndex); err != nil {
+		t.Fatal(err)
+	}
+	if res := search("q=production"); res.Total != 1 || res.Results[0].ID != deploy {
+		t.Errorf("recherche après reconstruction = %+v", res)
+	}
+
+	expectStatus(t, s.do(t, http.MethodGet, "/v1/docs/search", "", nil), http.StatusBadRequest)
+	expectStatus(t, s.do(t, http.MethodGet, "/v1/docs/search?q=x&root=abc", "", nil), http.StatusBadRequest)
+}
+
+func TestDocsWorkflow(t *testing.T) {
+	s := newTestServer(t)
+	resp := s.do(t, http.MethodPost, "/v1/docs", testenv.WriteKey, map[string]interface{}{"title": "Guides", "path": "/guides", "doc_src": "# Guides"})
+	expectStatus(t, resp, http.StatusOK)
+	var parent map[string]interface{}
+	decode(t, resp, &parent)
+	if parent["status"] != "draft" {
+		t.Fatalf("statut par défaut = %v", parent["status"])
+	}
+	parentURL := fmt.Sprintf("/v1/docs/%v", parent["id"])
+	resp = s.do(t, http.MethodPost, "/v1/docs", testenv.WriteKey, map[string]interface{}{"title": "Setup", "path": "/guides/setup", "doc_src": "Install", "parent_id": parent["id"], "status": "published"})
+	expectStatus(t, resp, http.StatusOK)
+	var child map[string]interface{}
+	decode(t, resp, &child)
+	childURL := fmt.Sprintf("/v1/docs/%v", child["id"])
+
+	// visible vérifie les documents lus par la clé key : want en liste et en
+	// arborescence, hits résultats de recherche (seul Setup contient install)
+	visible := func(key string, want, hits int) {
+		t.Helper()
+		var docs []map[string]interface{}
+		decode(t, s.do(t, http.MethodGet, "/v1/docs", key, nil), &docs)
+		var tree struct {
+			Nodes []map[string]interface{} `json:"nodes"`
+		}
+		decode(t, s.do(t, http.MethodGet, "/v1/docs/tree", key, nil), &tree)
+		var found struct {
+			Total int `json:"total"`
+		}
+		decode(t, s.do(t, http.MethodGet, "/v1/docs/search?q=install", key, nil), &found)
+		if len(docs) != want || (want == 0) != (len(tree.Nodes) == 0) || found.Total != hits {
+			t.Errorf("clé %q : %d documents, %d racines, %d résultats ; attendu %d et %d", key, len(docs), len(tree.Nodes), found.Total, want, hits)
+		}
+	}
+
+	// Un brouillon, et tout son sous-arbre, n'existe que pour les clés en écriture
+	for _, key := range []string{"", testenv.ReadKey} {
+		expectStatus(t, s.do(t, http.MethodGet, parentURL, key, nil), http.StatusNotFound)
+		expectStatus(t, s.do(t, http.MethodGet, childURL, key, nil), http.StatusNotFound)
+		expectStatus(t, s.do(t, http.MethodGet, parentURL+"/revisions", key, nil), http.StatusNotFound)
+		expectStatus(t, s.do(t, http.MethodGet, "/v1/docs/by-path?path=/guides/setup", key, nil), http.StatusNotFound)
+		visible(key, 0, 0)
+	}
+	expectStatus(t, s.do(t, http.MethodGet, childURL, testenv.WriteKey, nil), http.StatusOK)
+	visible(testenv.WriteKey, 2, 1)
+
+	expectStatus(t, s.do(t, http.MethodPatch, parentURL, testenv.WriteKey, map[string]interface{}{"status": "published"}), http.StatusOK)
+	expectStatus(t, s.do(t, http.MethodGet, "/v1/docs/by-path?path=/guides/setup", "", nil), http.StatusOK)
+	visible("", 2, 1)
+
+	// Publication programmée, puis retrait passé
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	expectStatus(t, s.do(t, http.MethodPatch, parentURL, testenv.WriteKey, map[string]interface{}{"publish_at": future}), http.StatusOK)
+	expectStatus(t, s.do(t, http.MethodGet, parentURL, "", nil), http.StatusNotFound)
+	var doc map[string]interface{}
+	decode(t, s.do(t, http.MethodGet, parentURL, testenv.WriteKey, nil), &doc)
+	if doc["publish_at"] != future || doc["status"] != "published" {
+		t.Errorf("document programmé = %v", doc)
+	}
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	expectStatus(t, s.do(t, http.MethodPatch, parentURL, testenv.WriteKey, map[string]interface{}{"publish_at": nil, "unpublish_at": past}), http.StatusOK)
+	expectStatus(t, s.do(t, http.MethodGet, parentURL, "", nil), http.StatusNotFound)
+	expectStatus(t, s.do(t, http.MethodPatch, parentURL, testenv.WriteKey, map[string]interface{}{"unpublish_at": nil}), http.StatusOK)
+	expectStatus(t, s.do(t, http.MethodGet, parentURL, "", nil), http.StatusOK)
+
+	expectStatus(t, s.do(t, http.MethodPatch, parentURL, testenv.WriteKey, map[string]interface{}{"status": "live"}), http.StatusBadRequest)
+	expectStatus(t, s.do(t, http.MethodPatch, parentURL, testenv.WriteKey, map[string]interface{}{"publish_at": future, "unpublish_at": past}), http.StatusBadRequest)
+	expectStatus(t, s.do(t, http.MethodPatch, parentURL, testenv.WriteKey, map[string]interface{}{"status": "archived"}), http.StatusOK)
+	expectStatus(t, s.do(t, http.MethodGet, childURL, "", nil), http.StatusNotFound)
+}
+
+func TestDocsTree(t *testing.T) {
+	s := newTestServer(t)
+	create := func(title string, fields map[string]interface{}) int {
+		body := map[string]interface{}{"status": "published", "title": title, "path": "/" + title, "doc_src": "# " + title}
+		for k, v := range fields {
+			body[k] = v
+		}
+		resp := s.do(t, http.MethodPost, "/v1/docs", testenv.WriteKey, body)
+		expectStatus(t, resp, http.StatusOK)
+		var d map[string]interface{}
+		decode(t, resp, &d)
+		return int(d["id"].(float64))
+	}
+	guides := create("guides", nil)
+	api := create("api", map[string]interface{}{"position": 1}) // avant guides (position 1 aussi, id plus grand)
+	setup := create("setup", map[string]interface{}{"parent_id": guides})
+	intro := create("intro", map[string]interface{}{"parent_id": guides, "position": -1})
+	deep := create("deep", map[string]interface{}{"parent_id": setup, "is_children": false})
+
+	type node struct {
+		ID          int    `json:"id"`
+		Title       string `json:"title"`
+		HasChildren bool   `json:"has_children"`
+		Children    []node `json:"children"`
+	}
+	var tree struct {
+		Nodes []node `json:"nodes"`
+	}
+	resp := s.do(t, http.MethodGet, "/v1/docs/tree", "", nil)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &tree)
+	if len(tree.Nodes) != 2 || tree.Nodes[0].ID != guides || tree.Nodes[1].ID != api {
+		t.Fatalf("premier niveau = %+v", tree.Nodes)
+	}
+	children := tree.Nodes[0].Children
+	if len(children) != 2 || children[0].ID != intro || children[1].ID != setup || children[1].Children[0].ID != deep {
+		t.Errorf("enfants de guides = %+v", children)
+	}
+
+	// Profondeur limitée et sous-arbre
+	tree.Nodes = nil
+	decode(t, s.do(t, http.MethodGet, "/v1/docs/tree?depth=1", "", nil), &tree)
+	if len(tree.Nodes[0].Children) != 0 || !tree.Nodes[0].HasChildren {
+		t.Errorf("depth=1 = %+v", tree.Nodes)
+	}
+	tree.Nodes = nil
+	decode(t, s.do(t, http.MethodGet, fmt.Sprintf("/v1/docs/tree?root=%d&depth=2", setup), "", nil), &tree)
+	if len(tree.Nodes) != 1 || tree.Nodes[0].ID != setup || len(tree.Nodes[0].Children) != 1 || tree.Nodes[0].Children[0].ID != deep {
+		t.Errorf("sous-arbre de setup = %+v", tree.Nodes)
+	}
+	expectStatus(t, s.do(t, http.MethodGet, "/v1/docs/tree?root=9999", "", nil), http.StatusNotFound)
+	expectStatus(t, s.do(t, http.MethodGet, "/v1/docs/tree?depth=-1", "", nil), http.StatusBadRequest)
+
+	// is_children suit parent_id, quelle que soit la valeur envoyée
+	var got map[string]interface{}
+	decode(t, s.do(t, http.MethodGet, fmt.Sprintf("/v1/docs/%d", deep), "", nil), &got)
+	if got["is_children"] != true {
+		t.Errorf("is_children = %v pour un document avec parent", got["is_children"])
+	}
+	update := map[string]interface{}{"title": "deep", "path": "/deep", "doc_src": "x", "parent_id": 0, "is_children": true}
+	expectStatus(t, s.do(t, http.MethodPut, fmt.Sprintf("/v1/docs/%d", deep), testenv.WriteKey, update), http.StatusOK)
+	decode(t, s.do(t, http.MethodGet, fmt.Sprintf("/v1/docs/%d", deep), "", nil), &got)
+	// Sans position, un document qui change de parent passe après ses nouveaux frères
+	if got["is_children"] != false || got["parent_id"] != nil || got["position"] != 2.0 {
+		t.Errorf("document remonté au premier niveau = %+v", got)
+	}
+}
+
+func TestDocsMoveAndDelete(t *testing.T) {
+	s := newTestServer(t)
+	create := func(title string, parentID int) int {
+		body := map[string]interface{}{"status": "published", "title": title, "path": "/" + title, "doc_src": "x", "parent_id": parentID}
+		resp := s.do(t, http.MethodPost, "/v1/docs", testenv.WriteKey, body)
+		expectStatus(t, resp, http.StatusOK)
+		var d map[string]interface{}
+		decode(t, resp, &d)
+		return int(d["id"].(float64))
+	}
+	// childIDs renvoie les enfants de parent, dans l'ordre de l'arborescence
+	childIDs := func(parent int) []int {
+		var tree struct {
+			Nodes []struct {
+				Children []struct {
+					ID int `json:"id"`
+				} `json:"children"`
+			} `json:"nodes"`
+		}
+		resp := s.do(t, http.MethodGet, fmt.Sprintf("/v1/docs/tree?root=%d&depth=2", parent), "", nil)
+		expectStatus(t, resp, http.StatusOK)
+		decode(t, resp, &tree)
+		var ids []int
+		for _, c := range tree.Nodes[0].Children {
+			ids = append(ids, c.ID)
+		}
+		return ids
+	}
+	move := func(id int, body map[string]interface{}) *http.Response {
+		return s.do(t, http.MethodPost, fmt.Sprintf("/v1/docs/%d/move", id), testenv.WriteKey, body)
+	}
+
+	a := create("a", 0)
+	b := create("b", 0)
+	a1 := create("a1", a)
+	a2 := create("a2", a)
+	a1x := create("a1x", a1)
+
+	// Cycles, parent inconnu et document inconnu
+	expectStatus(t, move(a, map[string]interface{}{"parent_id": a1x}), http.StatusConflict)
+	expectStatus(t, move(a, map[string]interface{}{"parent_id": a}), http.StatusConflict)
+	expectStatus(t, move(a, map[string]interface{}{"parent_id": 999}), http.StatusBadRequest)
+	expectStatus(t, move(999, map[string]interface{}{"parent_id": nil}), http.StatusNotFound)
+	expectStatus(t, move(a, map[string]interface{}{"position": -1}), http.StatusBadRequest)
+	update := map[string]interface{}{"title": "a", "path": "/a", "doc_src": "x", "parent_id": a1}
+	expectStatus(t, s.do(t, http.MethodPut, fmt.Sprintf("/v1/docs/%d", a), testenv.WriteKey, update), http.StatusConflict)
+	expectStatus(t, s.do(t, http.MethodPut, "/v1/docs/999", testenv.WriteKey, update), http.StatusNotFound)
+
+	resp := move(b, map[string]interface{}{"parent_id": a, "position": 1})
+	expectStatus(t, resp, http.StatusOK)
+	var moved map[string]interface{}
+	decode(t, resp, &moved)
+	if moved["parent_id"] != float64(a) || moved["position"] != 1.0 || moved["is_children"] != true {
+		t.Errorf("document déplacé = %+v", moved)
+	}
+	if got := childIDs(a); fmt.Sprint(got) != fmt.Sprint([]int{b, a1, a2}) {
+		t.Errorf("enfants de a après déplacement = %v", got)
+	}
+
+	reorder := func(ids []int) *http.Response {
+		return s.do(t, http.MethodPost, "/v1/docs/reorder", testenv.WriteKey, map[string]interface{}{"parent_id": a, "ids": ids})
+	}
+	expectStatus(t, reorder([]int{a2, b, a1}), http.StatusOK)
+	if got := childIDs(a); fmt.Sprint(got) != fmt.Sprint([]int{a2, b, a1}) {
+		t.Errorf("enfants de a après réordonnancement = %v", got)
+	}
+	expectStatus(t, reorder([]int{a2, b}), http.StatusBadRequest)
+	expectStatus(t, reorder([]int{a2, b, b}), http.StatusBadRequest)
+	expectStatus(t, reorder([]int{a2, b, a1x}), http.StatusBadRequest)
+
+	// Suppression : refus par défaut, puis rattachement et cascade
+	del := func(id int, mode string) *http.Response {
+		return s.do(t, http.MethodDelete, fmt.Sprintf("/v1/docs/%d?mode=%s", id, mode), testenv.WriteKey, nil)
+	}
+	expectStatus(t, s.do(t, http.MethodDelete, fmt.Sprintf("/v1/docs/%d", a), testenv.WriteKey, nil), http.StatusConflict)
+	expectStatus(t, del(a, "bogus"), http.StatusBadRequest)
+	expectStatus(t, del(999, "cascade"), http.StatusNotFound)
+
+	var report struct {
+		Deleted    []int `json:"deleted"`
+		Reparented []int `json:"reparented"`
+	}
+	resp = del(a1, "reparent")
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &report)
+	if fmt.Sprint(report.Deleted) != fmt.Sprint([]int{a1}) || fmt.Sprint(report.Reparented) != fmt.Sprint([]int{a1x}) {
+		t.Errorf("reparent = %+v", report)
+	}
+	if got := childIDs(a); fmt.Sprint(got) != fmt.Sprint([]int{a2, b, a1x}) {
+		t.Errorf("enfants de a après rattachement = %v", got)
+	}
+
+	resp = del(a, "cascade")
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &report)
+	if fmt.Sprint(report.Deleted) != fmt.Sprint([]int{a, a2, b, a1x}) || len(report.Reparented) != 0 {
+		t.Errorf("cascade = %+v", report)
+	}
+	expectStatus(t, s.do(t, http.MethodGet, fmt.Sprintf("/v1/docs/%d", a1x), "", nil), http.StatusNotFound)
+}
+
+func TestDocRevisions(t *testing.T) {
+	s := newTestServer(t)
+	resp := s.do(t, http.MethodPost, "/v1/docs", testenv.WriteKey, map[string]interface{}{"status": "published", "title": "Guide", "path": "/guide", "doc_src": "a\nb\nc\n", "version": 9})
+	expectStatus(t, resp, http.StatusOK)
+	var doc map[string]interface{}
+	decode(t, resp, &doc)
+	id := int(doc["id"].(float64))
+	if doc["version"] != 1.0 {
+		t.Errorf("version à la création = %v, attendu 1", doc["version"])
+	}
+	docURL := fmt.Sprintf("/v1/docs/%d", id)
+
+	for _, src := range []string{"a\nB\nc\n", "a\nB\nc\nd\n"} {
+		update := map[string]interface{}{"title": "Guide", "path": "/guide", "doc_src": src, "version": 1}
+		expectStatus(t, s.do(t, http.MethodPut, docURL, testenv.WriteKey, update), http.StatusOK)
+	}
+	decode(t, s.do(t, http.MethodGet, docURL, "", nil), &doc)
+	if doc["version"] != 3.0 {
+		t.Errorf("version après deux mises à jour = %v, attendu 3", doc["version"])
+	}
+
+	var page struct {
+		Revisions []map[string]interface{} `json:"revisions"`
+		Total     int                      `json:"total"`
+	}
+	decode(t, s.do(t, http.MethodGet, docURL+"/revisions?limit=2", "", nil), &page)
+	if page.Total != 3 || len(page.Revisions) != 2 || page.Revisions[0]["version"] != 3.0 || page.Revisions[0]["doc_src"] != nil {
+		t.Errorf("historique = %+v", page)
+	}
+
+	var rev map[string]interface{}
+	decode(t, s.do(t, http.MethodGet, docURL+"/revisions/1", testenv.WriteKey, nil), &rev)
+	if rev["doc_src"] != "a\nb\nc\n" || rev["api_key_id"] == nil || rev["status"] != "published" {
+		t.Errorf("révision 1 = %+v", rev)
+	}
+	// Sans clé, la clé auteur n'est pas exposée
+	rev = nil
+	decode(t, s.do(t, http.MethodGet, docURL+"/revisions/1", "", nil), &rev)
+	if _, ok := rev["api_key_id"]; ok || rev["doc_src"] != "a\nb\nc\n" {
+		t.Errorf("révision 1 sans clé = %+v", rev)
+	}
+	expectStatus(t, s.do(t, http.MethodGet, docURL+"/revisions/9", "", nil), http.StatusNotFound)
+	expectStatus(t, s.do(t, http.MethodGet, docURL+"/revisions/x", "", nil), http.StatusBadRequest)
+
+	var diff struct {
+		Added   int    `json:"added"`
+		Removed int    `json:"removed"`
+		Diff    string `json:"diff"`
+	}
+	decode(t, s.do(t, http.MethodGet, docURL+"/revisions/diff?from=1", "", nil), &diff)
+	if diff.Added != 2 || diff.Removed != 1 || !strings.Contains(diff.Diff, "-b\n+B\n") {
+		t.Errorf("diff 1..3 = %+v", diff)
+	}
+	expectStatus(t, s.do(t, http.MethodGet, docURL+"/revisions/diff", "", nil), http.StatusBadRequest)
+
+	expectStatus(t, s.do(t, http.MethodPost, docURL+"/revisions/1/restore", testenv.ReadKey, nil), http.StatusUnauthorized)
+	resp = s.do(t, http.MethodPost, docURL+"/revisions/1/restore", testenv.WriteKey, nil)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &doc)
+	if doc["version"] != 4.0 || doc["doc_src"] != "a\nb\nc\n" {
+		t.Errorf("document restauré = %+v", doc)
+	}
+	decode(t, s.do(t, http.MethodGet, docURL+"/revisions/4", "", nil), &rev)
+	if rev["restored_from"] != 1.0 {
+		t.Errorf("révision de restauration = %+v", rev)
+	}
+}
+
+func TestDraftRevisionsHidden(t *testing.T) {
+	s := newTestServer(t)
+	resp := s.do(t, http.MethodPost, "/v1/docs", testenv.WriteKey, map[string]interface{}{"title": "Brouillon", "path": "/brouillon", "doc_src": "secret"})
+	expectStatus(t, resp, http.StatusOK)
+	var doc map[string]interface{}
+	decode(t, resp, &doc)
+	id := int(doc["id"].(float64))
+	draftCID, _ := doc["cid"].(string)
+	docURL := fmt.Sprintf("/v1/docs/%d", id)
+
+	update := map[string]interface{}{"status": "published", "title": "Brouillon", "path": "/brouillon", "doc_src": "public"}
+	expectStatus(t, s.do(t, http.MethodPut, docURL, testenv.WriteKey, update), http.StatusOK)
+
+	// La révision écrite en brouillon reste invisible sans clé en écriture
+	var page struct {
+		Revisions []map[string]interface{} `json:"revisions"`
+		Total     int                      `json:"total"`
+	}
+	decode(t, s.do(t, http.MethodGet, docURL+"/revisions", "", nil), &page)
+	if page.Total != 1 || len(page.Revisions) != 1 || page.Revisions[0]["version"] != 2.0 {
+		t.Errorf("historique sans clé = %+v", page)
+	}
+	for _, rev := range page.Revisions {
+		if _, ok := rev["api_key_id"]; ok {
+			t.Errorf("api_key_id exposé sans clé : %+v", rev)
+		}
+	}
+	expectStatus(t, s.do(t, http.MethodGet, docURL+"/revisions/1", "", nil), http.StatusNotFound)
+	expectStatus(t, s.do(t, http.MethodGet, docURL+"/revisions/1", testenv.ReadKey, nil), http.StatusNotFound)
+	expectStatus(t, s.do(t, http.MethodGet, docURL+"/revisions/diff?from=1", "", nil), http.StatusNotFound)
+	expectStatus(t, s.do(t, http.MethodGet, docURL+"?cid="+draftCID, "", nil), http.StatusNotFound)
+
+	decode(t, s.do(t, http.MethodGet, docURL+"/revisions", testenv.WriteKey, nil), &page)
+	if page.Total != 2 {
+		t.Errorf("historique avec clé en écriture = %+v", page)
+	}
+	expectStatus(t, s.do(t, http.MethodGet, docURL+"/revisions/1", testenv.WriteKey, nil), http.StatusOK)
+}
+
+func TestDocsOnIPFS(t *testing.T) {
+	s := newTestServer(t)
+	resp := s.do(t, http.MethodPost, "/v1/docs", testenv.WriteKey, map[string]interface{}{"status": "published", "title": "Guides", "path": "/guides", "doc_src": "# v1"})
+	expectStatus(t, resp, http.StatusOK)
+	var doc map[string]interface{}
+	decode(t, resp, &doc)
+	id := int(doc["id"].(float64))
+	first, _ := doc["cid"].(string)
+	if content, err := s.env.IPFS.DownloadFileFromIPFS(first); err != nil || string(content) != "# v1" {
+		t.Fatalf("contenu sur IPFS sous %q = %q, %v", first, content, err)
+	}
+
+	update := map[string]interface{}{"title": "Guides", "path": "/guides", "doc_src": "# v2"}
+	expectStatus(t, s.do(t, http.MethodPut, fmt.Sprintf("/v1/docs/%d", id), testenv.WriteKey, update), http.StatusOK)
+	child := map[string]interface{}{"status": "published", "title": "Setup", "path": "/guides/setup", "doc_src": "install", "parent_id": id}
+	expectStatus(t, s.do(t, http.MethodPost, "/v1/docs", testenv.WriteKey, child), http.StatusOK)
+
+	// Le document à un CID antérieur : contenu et version de cette révision
+	decode(t, s.do(t, http.MethodGet, fmt.Sprintf("/v1/docs/%d?cid=%s", id, first), "", nil), &doc)
+	if doc["doc_src"] != "# v1" || doc["version"] != 1.0 || doc["cid"] != first {
+		t.Errorf("document au CID %s = %+v", first, doc)
+	}
+	expectStatus(t, s.do(t, http.MethodGet, fmt.Sprintf("/v1/docs/%d?cid=bafyinconnu", id), "", nil), http.StatusNotFound)
+	var rev map[string]interface{}
+	decode(t, s.do(t, http.MethodGet, fmt.Sprintf("/v1/docs/%d/revisions/2", id), "", nil), &rev)
+	if rev["cid"] == "" || rev["cid"] == first {
+		t.Errorf("CID de la révision 2 = %v", rev["cid"])
+	}
+
+	expectStatus(t, s.do(t, http.MethodPost, "/v1/docs/publish", testenv.ReadKey, nil), http.StatusUnauthorized)
+	resp = s.do(t, http.MethodPost, "/v1/docs/publish", testenv.WriteKey, nil)
+	expectStatus(t, resp, http.StatusOK)
+	var pub struct {
+		CID  string `json:"cid"`
+		Docs []struct {
+			ID   int    `json:"id"`
+			File string `json:"file"`
+		} `json:"docs"`
+	}
+	decode(t, resp, &pub)
+	if len(pub.Docs) != 2 || pub.Docs[0].File != "guides.md" || pub.Docs[1].File != "guides/setup.md" {
+		t.Fatalf("publication = %+v", pub)
+	}
+	for file, want := range map[string]string{"guides.md": "# v2", "guides/setup.md": "install"} {
+		if content, err := s.env.IPFS.DownloadFileFromIPFS(pub.CID + "/" + file); err != nil || string(content) != want {
+			t.Errorf("%s/%s = %q, %v", pub.CID, file, content, err)
+		}
+	}
+	if _, err := s.env.IPFS.DownloadFileFromIPFS(pub.CID + "/index.json"); err != nil {
+		t.Errorf("index.json absent du répertoire publié : %v", err)
+	}
+	expectStatus(t, s.do(t, http.MethodPost, "/v1/docs/publish?root=999", testenv.WriteKey, nil), http.StatusNotFound)
+}
+
+func TestDocsExport(t *testing.T) {
+	s := newTestServer(t)
+	resp := s.do(t, http.MethodPost, "/v1/docs", testenv.WriteKey, map[string]interface{}{"status": "published", "title": "Guides", "path": "/guides", "doc_src": "# Guides\n\nVoir [setup](guides/setup.md)."})
+	expectStatus(t, resp, http.StatusOK)
+	var doc map[string]interface{}
+	decode(t, resp, &doc)
+	child := map[string]interface{}{"status": "published", "title": "Setup", "path": "/guides/setup", "doc_src": "# Setup", "parent_id": doc["id"]}
+	expectStatus(t, s.do(t, http.MethodPost, "/v1/docs", testenv.WriteKey, child), http.StatusOK)
+	draft := map[string]interface{}{"title": "Brouillon", "path": "/draft", "doc_src": "# secret"}
+	expectStatus(t, s.do(t, http.MethodPost, "/v1/docs", testenv.WriteKey, draft), http.StatusOK)
+
+	expectStatus(t, s.do(t, http.MethodPost, "/v1/docs/export", testenv.ReadKey, nil), http.StatusUnauthorized)
+	expectStatus(t, s.do(t, http.MethodPost, "/v1/docs/export?format=rar", testenv.WriteKey, nil), http.StatusBadRequest)
+
+	resp = s.do(t, http.MethodPost, "/v1/docs/export?title=Bakiverse", testenv.WriteKey, nil)
+	expectStatus(t, resp, http.StatusOK)
+	if got := resp.Header.Get("Content-Disposition"); got != `attachment; filename="docs-site.zip"` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(b)
+	}
+	if len(files) != 5 || files["guides/setup/index.html"] == "" || files["style.css"] == "" || files["search-index.json"] == "" {
+		t.Fatalf("fichiers de l'archive : %d", len(files))
+	}
+	if !strings.Contains(files["guides/index.html"], `href="../guides/setup/index.html"`) || !strings.Contains(files["index.html"], "Bakiverse") {
+		t.Errorf("pages inattendues :\n%s\n%s", files["guides/index.html"], files["index.html"])
+	}
+	if strings.Contains(files["search-index.json"], "secret") {
+		t.Errorf("un brouillon est exporté : %s", files["search-index.json"])
+	}
+
+	resp = s.do(t, http.MethodPost, "/v1/docs/export?format=ipfs", testenv.WriteKey, nil)
+	expectStatus(t, resp, http.StatusOK)
+	var export struct {
+		CID   string `json:"cid"`
+		Docs  int    `json:"docs"`
+		Files int    `json:"files"`
+	}
+	decode(t, resp, &export)
+	if export.Docs != 2 || export.Files != 5 {
+		t.Errorf("export = %+v", export)
+	}
+	if content, err := s.env.IPFS.DownloadFileFromIPFS(export.CID + "/guides/setup/index.html"); err != nil || !strings.Contains(string(content), `<h1 id="setup">Setup</h1>`) {
+		t.Errorf("page sur IPFS = %q, %v", content, err)
+	}
+}
+
+func TestThemesCRUD(t *testing.T) {
+	s := newTestServer(t)
+	animation := []byte(`{"v":"5.7.4","fr":30,"ip":0,"op":60,"w":64,"h":64,"layers":[{"ty":4}]}`)
+	cid := s.upload(t, testenv.WriteKey, "loader.json", "application/json", animation, false)
+	private := s.upload(t, testenv.WriteKey, "secret.json", "application/json", []byte(`{"v":"5","fr":30,"ip":0,"op":60,"w":1,"h":1,"layers":[]}`), true)
+	plain := s.upload(t, testenv.WriteKey, "notes.txt", "text/plain", []byte("notes"), false)
+	theme := map[string]interface{}{"cid": cid, "name": "dark"}
+
+	expectStatus(t, s.do(t, http.MethodPost, "/v1/themes", "", theme), http.StatusUnauthorized)
+	expectStatus(t, s.do(t, http.MethodPost, "/v1/themes", testenv.ReadKey, theme), http.StatusForbidden)
+	resp := s.do(t, http.MethodPost, "/v1/themes", testenv.WriteKey, theme)
+	expectStatus(t, resp, http.StatusCreated)
+	var created map[string]interface{}
+	decode(t, resp, &created)
+	if created["cid"] != cid || created["name"] != "dark" || created["id"] == nil {
+		t.Fatalf("thème créé = %+v", created)
+	}
+	id := int(created["id"].(float64))
+
+	// Références invalides et noms en double
+	for name, body := range map[string]map[string]interface{}{
+		"cid inconnu":   {"cid": "bafyinconnu", "name": "x"},
+		"cid privé":     {"cid": private, "name": "x"},
+		"pas un Lottie": {"cid": plain, "name": "x"},
+		"sans nom":      {"cid": cid, "name": " "},
+	} {
+		if resp := s.do(t, http.MethodPost, "/v1/themes", testenv.WriteKey, body); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s : statut %d, attendu 400", name, resp.StatusCode)
+		}
+	}
+	expectStatus(t, s.do(t, http.MethodPost, "/v1/themes", testenv.WriteKey, map[string]interface{}{"cid": cid, "name": "DARK"}), http.StatusConflict)
+
+	var themes themePage
+	resp = s.do(t, http.MethodGet, "/v1/themes", "", nil)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &themes)
+	if themes.Total != 1 {
+		t.Fatalf("%d thèmes, attendu 1", themes.Total)
+	}
+
+	resp = s.do(t, http.MethodPut, fmt.Sprintf("/v1/themes/%d", id), testenv.WriteKey, map[string]interface{}{"cid": cid, "name": "light"})
+	expectStatus(t, resp, http.StatusOK)
+	var updated map[string]interface{}
+	decode(t, resp, &updated)
+	if updated["name"] != "light" || int(updated["id"].(float64)) != id {
+		t.Errorf("thème mis à jour = %+v", updated)
+	}
+	// Renvoyer le même contenu n'est pas un conflit avec soi-même
+	expectStatus(t, s.do(t, http.MethodPut, fmt.Sprintf("/v1/themes/%d", id), testenv.WriteKey, map[string]interface{}{"cid": cid, "name": "light"}), http.StatusOK)
+	expectStatus(t, s.do(t, http.MethodPut, "/v1/themes/9999", testenv.WriteKey, map[string]interface{}{"cid": cid, "name": "x"}), http.StatusNotFound)
+
+	// L'animation référencée ne peut pas être supprimée sans force=true
+	expectStatus(t, s.do(t, http.MethodDelete, "/v1/files/"+cid, testenv.WriteKey, nil), http.StatusConflict)
+
+	expectStatus(t, s.do(t, http.MethodDelete, fmt.Sprintf("/cid-themes/delete?id=%d", id), testenv.WriteKey, nil), http.StatusOK)
+	expectStatus(t, s.do(t, http.MethodDelete, fmt.Sprintf("/v1/themes/%d", id), testenv.WriteKey, nil), http.StatusNotFound)
+	resp = s.do(t, http.MethodGet, "/v1/themes", "", nil)
+	decode(t, resp, &themes)
+	if themes.Total != 0 || len(themes.Themes) != 0 {
+		t.Errorf("thèmes après suppression = %+v", themes)
+	}
+	expectStatus(t, s.do(t, http.MethodDelete, "/v1/files/"+cid, testenv.WriteKey, nil), http.StatusOK)
+}
+
+func TestOptimisticConcurrency(t *testing.T) {
+	s := newTestServer(t)
+	resp := s.do(t, http.MethodPost, "/v1/docs", testenv.WriteKey, map[string]interface{}{"status": "published", "title": "Guides", "path": "/guides", "doc_src": "v1"})
+	expectStatus(t, resp, http.StatusOK)
+	created := resp.Header.Get("ETag")
+	var doc map[string]interface{}
+	decode(t, resp, &doc)
+	docPath := fmt.Sprintf("/v1/docs/%d", int(doc["id"].(float64)))
+
+	// conditional envoie une requête avec un en-tête de précondition
+	conditional := func(method, path, header, etag string, body interface{}) *http.Response {
+		t.Helper()
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, s.URL+path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", testenv.WriteKey)
+		req.Header.Set(header, etag)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	resp = s.do(t, http.MethodGet, docPath, "", nil)
+	expectStatus(t, resp, http.StatusOK)
+	if resp.Header.Get("ETag") != created {
+		t.Fatalf("ETag du GET = %q, attendu celui de la création %q", resp.Header.Get("ETag"), created)
+	}
+	expectStatus(t, conditional(http.MethodGet, docPath, "If-None-Match", created, nil), http.StatusNotModified)
+
+	resp = conditional(http.MethodPut, docPath, "If-Match", created, map[string]interface{}{"title": "Guides", "path": "/guides", "doc_src": "v2"})
+	expectStatus(t, resp, http.StatusOK)
+	current := resp.Header.Get("ETag")
+	if current == "" || current == created {
+		t.Fatalf("ETag après mise à jour = %q", current)
+	}
+	// Une écriture fondée sur la version périmée est refusée avec la version actuelle
+	resp = conditional(http.MethodPatch, docPath, "If-Match", created, map[string]interface{}{"title": "Guides", "path": "/guides", "doc_src": "v3"})
+	expectStatus(t, resp, http.StatusPreconditionFailed)
+	decode(t, resp, &doc)
+	if doc["doc_src"] != "v2" || resp.Header.Get("ETag") != current {
+		t.Errorf("réponse 412 = %+v (ETag %q), attendu la version actuelle", doc, resp.Header.Get("ETag"))
+	}
+	expectStatus(t, conditional(http.MethodPut, docPath, "If-Match", "W/"+current, map[string]interface{}{"title": "Guides", "path": "/guides"}), http.StatusPreconditionFailed)
+	// Un déplacement change aussi la version
+	expectStatus(t, s.do(t, http.MethodPost, docPath+"/move", testenv.WriteKey, map[string]interface{}{"parent_id": nil, "position": 1}), http.StatusOK)
+	expectStatus(t, conditional(http.MethodGet, docPath, "If-None-Match", current, nil), http.StatusOK)
+
+	animation := []byte(`{"v":"5.7.4","fr":30,"ip":0,"op":60,"w":64,"h":64,"layers":[{"ty":4}]}`)
+	cid := s.upload(t, testenv.WriteKey, "loader.json", "application/json", animation, false)
+	resp = s.do(t, http.MethodPost, "/v1/themes", testenv.WriteKey, map[string]interface{}{"cid": cid, "name": "dark"})
+	expectStatus(t, resp, http.StatusCreated)
+	created = resp.Header.Get("ETag")
+	var theme map[string]interface{}
+	decode(t, resp, &theme)
+	themePath := fmt.Sprintf("/v1/themes/%d", int(theme["id"].(float64)))
+
+	expectStatus(t, conditional(http.MethodGet, themePath, "If-None-Match", created, nil), http.StatusNotModified)
+	resp = conditional(http.MethodPut, themePath, "If-Match", created, map[string]interface{}{"cid": cid, "name": "light"})
+	expectStatus(t, resp, http.StatusOK)
+	current = resp.Header.Get("ETag")
+	resp = conditional(http.MethodPut, themePath, "If-Match", created, map[string]interface{}{"cid": cid, "name": "blue"})
+	expectStatus(t, resp, http.StatusPreconditionFailed)
+	decode(t, resp, &theme)
+	if theme["name"] != "light" || resp.Header.Get("ETag") != current {
+		t.Errorf("réponse 412 = %+v (ETag %q), attendu la version actuelle", theme, resp.Header.Get("ETag"))
+	}
+	expectStatus(t, conditional(http.MethodPut, themePath, "If-Match", "*", map[string]interface{}{"cid": cid, "name": "blue"}), http.StatusOK)
+}
+
+type themePage struct {
+	Themes []map[string]interface{} `json:"themes"`
+	Total  int                      `json:"total"`
+}
+
+func TestThemeModel(t *testing.T) {
+	s := newTestServer(t)
+	lottieFile := func(name string, op int) string {
+		return s.upload(t, testenv.WriteKey, name, "application/json",
+			[]byte(fmt.Sprintf(`{"v":"5.7.4","fr":30,"ip":0,"op":%d,"w":64,"h":64,"layers":[{"ty":4}]}`, op)), false)
+	}
+	idle, loading, dark := lottieFile("idle.json", 30), lottieFile("loading.json", 60), lottieFile("loading-dark.json", 90)
+	var img bytes.Buffer
+	png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 8, 8)))
+	preview := s.upload(t, testenv.WriteKey, "preview.png", "image/png", img.Bytes(), false)
+
+	theme := map[string]interface{}{
+		"cid": idle, "name": "Ocean", "category": "seasonal", "sort_order": 2, "preview_cid": preview,
+		"animations": []map[string]interface{}{
+			{"role": "idle", "cid": idle},
+			{"role": "loading", "cid": loading},
+			{"role": "loading", "variant": "dark", "cid": dark},
+		},
+	}
+	resp := s.do(t, http.MethodPost, "/v1/themes", testenv.WriteKey, theme)
+	expectStatus(t, resp, http.StatusCreated)
+	var created map[string]interface{}
+	decode(t, resp, &created)
+	id := int(created["id"].(float64))
+	if created["enabled"] != true || created["preview_cid"] != preview || len(created["animations"].([]interface{})) != 3 {
+		t.Fatalf("thème créé = %+v", created)
+	}
+
+	// Rôles, variantes et fichiers référencés sont validés
+	for name, animations := range map[string][]map[string]interface{}{
+		"rôle invalide":     {{"role": "Idle!", "cid": idle}},
+		"variante inconnue": {{"role": "idle", "variant": "sepia", "cid": idle}},
+		"rôle en double":    {{"role": "idle", "cid": idle}, {"role": "idle", "cid": loading}},
+		"pas un Lottie":     {{"role": "idle", "cid": preview}},
+	} {
+		body := map[string]interface{}{"cid": idle, "name": "Invalid", "animations": animations}
+		if resp := s.do(t, http.MethodPost, "/v1/themes", testenv.WriteKey, body); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s : statut %d, attendu 400", name, resp.StatusCode)
+		}
+	}
+	expectStatus(t, s.do(t, http.MethodPost, "/v1/themes", testenv.WriteKey,
+		map[string]interface{}{"cid": idle, "name": "Invalid", "preview_cid": loading}), http.StatusBadRequest)
+
+	for _, body := range []map[string]interface{}{
+		{"cid": loading, "name": "Forest", "category": "seasonal", "sort_order": 1},
+		{"cid": loading, "name": "Classic", "category": "default", "enabled": false},
+	} {
+		expectStatus(t, s.do(t, http.MethodPost, "/v1/themes", testenv.WriteKey, body), http.StatusCreated)
+	}
+
+	var page themePage
+	decode(t, s.do(t, http.MethodGet, "/v1/themes?category=seasonal", "", nil), &page)
+	if page.Total != 2 || page.Themes[0]["name"] != "Forest" || page.Themes[1]["name"] != "Ocean" {
+		t.Errorf("catégorie seasonal = %+v", page)
+	}
+	decode(t, s.do(t, http.MethodGet, "/v1/themes?enabled=false", "", nil), &page)
+	if page.Total != 1 || page.Themes[0]["name"] != "Classic" {
+		t.Errorf("thèmes désactivés = %+v", page)
+	}
+	decode(t, s.do(t, http.MethodGet, "/v1/themes?limit=1&page=2", "", nil), &page)
+	if page.Total != 3 || len(page.Themes) != 1 || page.Themes[0]["name"] != "Forest" {
+		t.Errorf("page 2 = %+v", page)
+	}
+	expectStatus(t, s.do(t, http.MethodGet, "/v1/themes?enabled=maybe", "", nil), http.StatusBadRequest)
+
+	// L'ancienne route renvoie toujours un tableau
+	var legacy []map[string]interface{}
+	decode(t, s.do(t, http.MethodGet, "/cid-themes?category=seasonal", "", nil), &legacy)
+	if len(legacy) != 2 {
+		t.Errorf("/cid-themes = %+v", legacy)
+	}
+
+	var got map[string]interface{}
+	resp = s.do(t, http.MethodGet, fmt.Sprintf("/v1/themes/%d", id), "", nil)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &got)
+	if got["name"] != "Ocean" || got["category"] != "seasonal" || got["sort_order"] != 2.0 {
+		t.Errorf("GET /v1/themes/%d = %+v", id, got)
+	}
+	expectStatus(t, s.do(t, http.MethodGet, "/v1/themes/9999", "", nil), http.StatusNotFound)
+
+	// La mise à jour remplace les animations
+	theme["animations"] = []map[string]interface{}{{"role": "success", "cid": loading}}
+	resp = s.do(t, http.MethodPut, fmt.Sprintf("/v1/themes/%d", id), testenv.WriteKey, theme)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &got)
+	if animations := got["animations"].([]interface{}); len(animations) != 1 || animations[0].(map[string]interface{})["role"] != "success" {
+		t.Errorf("animations après mise à jour = %+v", got["animations"])
+	}
+
+	// L'ancienne route ne remet pas à zéro les champs qu'elle ne connaît pas
+	theme["enabled"] = false
+	expectStatus(t, s.do(t, http.MethodPut, fmt.Sprintf("/v1/themes/%d", id), testenv.WriteKey, theme), http.StatusOK)
+	legacyUpdate := map[string]interface{}{"id": id, "cid": loading, "name": "Ocean Blue"}
+	resp = s.do(t, http.MethodPut, "/cid-themes/update", testenv.WriteKey, legacyUpdate)
+	expectStatus(t, resp, http.StatusOK)
+	got = nil
+	decode(t, resp, &got)
+	if got["name"] != "Ocean Blue" || got["cid"] != loading || got["category"] != "seasonal" || got["sort_order"] != 2.0 ||
+		got["preview_cid"] != preview || got["enabled"] != false || len(got["animations"].([]interface{})) != 1 {
+		t.Errorf("thème après mise à jour par l'ancienne route = %+v", got)
+	}
+
+	// Une animation de rôle ou un aperçu référencés bloquent aussi la suppression
+	expectStatus(t, s.do(t, http.MethodDelete, "/v1/files/"+preview, testenv.WriteKey, nil), http.StatusConflict)
+	expectStatus(t, s.do(t, http.MethodDelete, "/v1/files/"+dark, testenv.WriteKey, nil), http.StatusOK)
+}
+
+func TestThemeBundle(t *testing.T) {
+	s := newTestServer(t)
+	idle := []byte(`{"v":"5.7.4","fr":30,"ip":0,"op":30,"w":64,"h":64,"layers":[{"ty":4}]}`)
+	loading := []byte(`{"v":"5.7.4","fr":30,"ip":0,"op":60,"w":64,"h":64,"layers":[{"ty":4}]}`)
+	archive, err := lottie.Pack("success", idle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idleCID := s.upload(t, testenv.WriteKey, "idle.json", "application/json", idle, false)
+	loadingCID := s.upload(t, testenv.WriteKey, "loading.json", "application/json", loading, false)
+	archiveCID := s.upload(t, testenv.WriteKey, "success.lottie", "application/zip", archive, false)
+
+	resp := s.do(t, http.MethodPost, "/v1/themes", testenv.WriteKey, map[string]interface{}{
+		"cid": idleCID, "name": "Ocean",
+		"animations": []map[string]interface{}{
+			{"role": "idle", "cid": idleCID},
+			{"role": "loading", "cid": loadingCID},
+			{"role": "success", "cid": archiveCID},
+		},
+	})
+	expectStatus(t, resp, http.StatusCreated)
+	var created map[string]interface{}
+	decode(t, resp, &created)
+	bundlePath := fmt.Sprintf("/v1/themes/%d/bundle", int(created["id"].(float64)))
+
+	expectStatus(t, s.do(t, http.MethodGet, bundlePath, "", nil), http.StatusUnauthorized)
+	expectStatus(t, s.do(t, http.MethodGet, bundlePath+"?format=tar", testenv.ReadKey, nil), http.StatusBadRequest)
+	expectStatus(t, s.do(t, http.MethodGet, "/v1/themes/9999/bundle", testenv.ReadKey, nil), http.StatusNotFound)
+
+	type bundleFile struct {
+		Kind      string          `json:"kind"`
+		Animation json.RawMessage `json:"animation"`
+		Data      []byte          `json:"data"`
+		Path      string          `json:"path"`
+	}
+	var bundle struct {
+		Theme   map[string]interface{} `json:"theme"`
+		Files   map[string]bundleFile  `json:"files"`
+		Missing []string               `json:"missing"`
+	}
+	resp = s.do(t, http.MethodGet, bundlePath, testenv.ReadKey, nil)
+	expectStatus(t, resp, http.StatusOK)
+	etag := resp.Header.Get("ETag")
+	decode(t, resp, &bundle)
+	if etag == "" || bundle.Theme["name"] != "Ocean" || len(bundle.Files) != 3 || len(bundle.Missing) != 0 {
+		t.Fatalf("bundle = %+v (ETag %q)", bundle, etag)
+	}
+	if !bytes.Equal(bundle.Files[loadingCID].Animation, loading) || !bytes.Equal(bundle.Files[archiveCID].Data, archive) ||
+		bundle.Files[archiveCID].Kind != "dotlottie" {
+		t.Errorf("contenus inlinés = %+v", bundle.Files)
+	}
+
+	// Revalidation sans téléchargement
+	get := func(path, etag string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, s.URL+path, nil)
+		req.Header.Set("X-API-Key", testenv.ReadKey)
+		req.Header.Set("If-None-Match", etag)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+	expectStatus(t, get(bundlePath, etag), http.StatusNotModified)
+
+	// Archive zip : bundle.json et un fichier par animation, octet pour octet stable
+	resp = s.do(t, http.MethodGet, bundlePath+"?format=zip", testenv.ReadKey, nil)
+	expectStatus(t, resp, http.StatusOK)
+	zipETag := resp.Header.Get("ETag")
+	body := readBody(t, resp)
+	if zipETag == etag || resp.Header.Get("Content-Type") != "application/zip" {
+		t.Errorf("zip : ETag %q, Content-Type %q", zipETag, resp.Header.Get("Content-Type"))
+	}
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := map[string]bool{}
+	for _, f := range zr.File {
+		entries[f.Name] = true
+	}
+	for _, name := range []string{"bundle.json", "animations/" + loadingCID + ".json", "animations/" + archiveCID + ".lottie"} {
+		if !entries[name] {
+			t.Errorf("%s absent du zip (%v)", name, entries)
+		}
+	}
+	if again := readBody(t, s.do(t, http.MethodGet, bundlePath+"?format=zip", testenv.ReadKey, nil)); !bytes.Equal(again, body) {
+		t.Error("le zip change d'une requête à l'autre")
+	}
+
+	// Une animation devenue privée est signalée et change l'ETag
+	expectStatus(t, s.do(t, http.MethodPost, "/v1/files/"+loadingCID+"/toggle-private", testenv.WriteKey, nil), http.StatusOK)
+	resp = get(bundlePath, etag)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &bundle)
+	if resp.Header.Get("ETag") == etag || len(bundle.Missing) != 1 || bundle.Missing[0] != loadingCID {
+		t.Errorf("après passage en privé : ETag %q, missing %v", resp.Header.Get("ETag"), bundle.Missing)
+	}
+}
+
+func TestForcedDeleteOfThemedFile(t *testing.T) {
+	s := newTestServer(t)
+	animation := []byte(`{"v":"5.7.4","fr":30,"ip":0,"op":60,"w":64,"h":64,"layers":[{"ty":4}]}`)
+	cid := s.upload(t, testenv.WriteKey, "loader.json", "application/json", animation, false)
+	expectStatus(t, s.do(t, http.MethodPost, "/v1/themes", testenv.WriteKey, map[string]interface{}{"cid": cid, "name": "dark"}), http.StatusCreated)
+
+	// Une autre clé publie le même contenu : le thème reste servi
+	s.upload(t, testenv.OtherKey, "copy.json", "application/json", animation, false)
+	expectStatus(t, s.do(t, http.MethodDelete, "/v1/files/"+cid, testenv.WriteKey, nil), http.StatusOK)
+
+	// La copie restante reste recherchable
+	var search struct {
+		Total int `json:"total"`
+	}
+	decode(t, s.do(t, http.MethodGet, "/v1/files/search?query=copy.json", "", nil), &search)
+	if search.Total != 1 {
+		t.Errorf("copie restante absente de la recherche : %+v", search)
+	}
+
+	expectStatus(t, s.do(t, http.MethodDelete, "/v1/files/"+cid, testenv.OtherKey, nil), http.StatusConflict)
+	resp := s.do(t, http.MethodDelete, "/v1/files/"+cid+"?force=true", testenv.OtherKey, nil)
+	expectStatus(t, resp, http.StatusOK)
+	var out struct {
+		OrphanedThemes []map[string]interface{} `json:"orphaned_themes"`
+	}
+	decode(t, resp, &out)
+	if len(out.OrphanedThemes) != 1 || out.OrphanedThemes[0]["name"] != "dark" {
+		t.Errorf("orphaned_themes = %+v", out.OrphanedThemes)
+	}
+	decode(t, s.do(t, http.MethodGet, "/v1/files/search?query=copy.json", "", nil), &search)
+	if search.Total != 0 {
+		t.Errorf("fichier supprimé encore recherchable : %+v", search)
+	}
+}
+
+func TestAuthFailures(t *testing.T) {
+	s := newTestServer(t)
+	routes := []struct{ method, path string }{
+		{http.MethodGet, "/v1/files"},
+		{http.MethodGet, "/v1/account/files"},
+		{http.MethodGet, "/v1/files/bafyx"},
+		{http.MethodDelete, "/v1/files/bafyx"},
+		{http.MethodGet, "/v1/files/bafyx/private-image"},
+		{http.MethodGet, "/v1/files/bafyx/lottie"},
+		{http.MethodPost, "/v1/files/bafyx/toggle-private"},
+		{http.MethodPut, "/v1/docs/1"},
+		{http.MethodDelete, "/v1/docs/1"},
+		{http.MethodDelete, "/v1/themes/1"},
+		{http.MethodGet, "/private-files"},
+	}
+	for _, rt := range routes {
+		for _, key := range []string{"", "bogus"} {
+			resp := s.do(t, rt.method, rt.path, key, nil)
+			if resp.StatusCode != http.StatusUnauthorized {
+				t.Errorf("%s %s avec la clé %q : statut %d, attendu 401", rt.method, rt.path, key, resp.StatusCode)
+			}
+		}
+	}
+}
+
+func TestRouting(t *testing.T) {
+	s := newTestServer(t)
+	cid := s.upload(t, testenv.WriteKey, "a.txt", "text/plain", []byte("a"), false)
+
+	// Les anciennes routes restent servies mais annoncent leur successeur
+	resp := s.do(t, http.MethodGet, "/file/display?cid="+cid, "", nil)
+	expectStatus(t, resp, http.StatusOK)
+	if resp.Header.Get("Deprecation") == "" {
+		t.Error("en-tête Deprecation absent sur une ancienne route")
+	}
+	if link := resp.Header.Get("Link"); !strings.Contains(link, "/v1/files/"+cid+"/display") {
+		t.Errorf("Link = %q", link)
+	}
+	if resp := s.do(t, http.MethodGet, "/v1/files/"+cid+"/display", "", nil); resp.Header.Get("Deprecation") != "" {
+		t.Error("en-tête Deprecation sur une route /v1")
+	}
+
+	// 405 avec Allow
+	resp = s.do(t, http.MethodPost, "/v1/docs/1", testenv.WriteKey, nil)
+	expectStatus(t, resp, http.StatusMethodNotAllowed)
+	if allow := resp.Header.Get("Allow"); !strings.Contains(allow, "GET") || !strings.Contains(allow, "PATCH") {
+		t.Errorf("Allow = %q", allow)
+	}
+	expectStatus(t, s.do(t, http.MethodGet, "/upload", "", nil), http.StatusMethodNotAllowed)
+
+	// CORS et pré-vol
+	resp = s.do(t, http.MethodOptions, "/v1/files", "", nil)
+	expectStatus(t, resp, http.StatusOK)
+	if resp.Header.Get("Access-Control-Allow-Origin") != "*" {
+		t.Error("en-têtes CORS absents")
+	}
+
+	expectStatus(t, s.do(t, http.MethodGet, "/inconnue", "", nil), http.StatusNotFound)
+	expectStatus(t, s.do(t, http.MethodGet, "/openapi.json", "", nil), http.StatusOK)
+}
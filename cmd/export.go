@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/docsite"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/service"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/store"
+)
+
+// runExportSite exécute la sous-commande `export-site` : le site statique des
+// documents visibles est écrit en archive zip ou tar, ou ajouté sur IPFS
+func runExportSite(docs store.DocRepository, ipfs service.IPFS, args []string) error {
+	fs := flag.NewFlagSet("export-site", flag.ContinueOnError)
+	format := fs.String("format", "zip", "zip, tar ou ipfs")
+	output := fs.String("o", "", "fichier de l'archive (docs-site.<format> par défaut, - : sortie standard)")
+	title := fs.String("title", "", "nom du site (Documentation par défaut)")
+	gateway := fs.String("gateway", "", "passerelle IPFS des fichiers référencés par les documents")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	write := docsite.WriteZip
+	switch *format {
+	case "zip", "ipfs":
+	case "tar":
+		write = docsite.WriteTar
+	default:
+		return fmt.Errorf("format invalide : %q (zip, tar ou ipfs)", *format)
+	}
+
+	all, err := docs.List(context.Background())
+	if err != nil {
+		return err
+	}
+	live := store.LiveDocs(all, time.Now())
+	files, err := docsite.Build(live, docsite.Options{Title: *title, Gateway: *gateway})
+	if err != nil {
+		return err
+	}
+
+	if *format == "ipfs" {
+		cid, err := service.AddFiles(ipfs, files)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d documents, %d fichiers publiés sur IPFS\n%s\n", len(live), len(files), cid)
+		return nil
+	}
+
+	name := *output
+	if name == "" {
+		name = "docs-site." + *format
+	}
+	if name == "-" {
+		return write(os.Stdout, files)
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := write(f, files); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%d documents exportés dans %s\n", len(live), name)
+	return nil
+}
@@ -6,6 +6,9 @@ import (
 	"os"
 
 	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/handlers"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/router"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/service"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/store"
 	"github.com/TomPo62/bakiverse-ipfs-service-go/pkg/bleve"
 	"github.com/TomPo62/bakiverse-ipfs-service-go/pkg/cors"
 	"github.com/TomPo62/bakiverse-ipfs-service-go/pkg/database"
@@ -21,70 +24,74 @@ func main() {
 	}
 
 	// Connexion à la base de données
-	db, err := database.ConnectDB()
+	db, dialect, err := database.Connect()
 	if err != nil {
 		log.Fatal("Erreur lors de la connexion à la base de données :", err)
 	}
 	defer db.Close()
 
+	// Sous-commandes : ipfs-api migrate up | down [n] | status
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(db, dialect, os.Args[2:]); err != nil {
+			log.Fatal("Erreur lors de la migration :", err)
+		}
+		return
+	}
+
+	// Refuser de démarrer sur un schéma incompatible
+	if err := database.CheckSchemaVersion(db, dialect); err != nil {
+		log.Fatal("Schéma de base de données incompatible : ", err)
+	}
+
+	s := store.New(db)
+
+	// Adresse de l'API du nœud IPFS
+	ipfsAddr := os.Getenv("IPFS_API")
+	if ipfsAddr == "" {
+		ipfsAddr = "localhost:5001"
+	}
+	node := service.NewNode(ipfsAddr)
+
+	// ipfs-api export-site [-format zip|tar|ipfs] [-o fichier] [-title nom] [-gateway url]
+	if len(os.Args) > 1 && os.Args[1] == "export-site" {
+		if err := runExportSite(s.Docs, node, os.Args[2:]); err != nil {
+			log.Fatal("Erreur lors de l'export du site des documents :", err)
+		}
+		return
+	}
+
 	index, err := bleve.InitBleveIndex()
 	if err != nil {
 		log.Fatal("Erreur lors de la création de l'index Bleve:", err)
 	}
 	defer index.Close()
 
+	docsIndex, docsIndexCreated, err := bleve.InitDocsIndex()
+	if err != nil {
+		log.Fatal("Erreur lors de la création de l'index des documents :", err)
+	}
+	defer docsIndex.Close()
+
 	// Vérifier si l’indexation initiale doit être effectuée
 	if os.Getenv("INIT_INDEX") == "true" {
 		log.Println("Démarrage de l'indexation initiale...")
-		initbleeveindex.IndexInitialData(db, index)
+		initbleeveindex.IndexInitialData(s.Files, index)
 		log.Println("Indexation initiale terminée avec succès.")
 	}
-
-	h := &handler.Handler{
-		DB:    db,
-		Index: index,
+	// Un index des documents neuf est rempli depuis la table docs
+	if os.Getenv("INIT_INDEX") == "true" || docsIndexCreated {
+		initbleeveindex.IndexInitialDocs(s.Docs, docsIndex)
 	}
 
-	// Configurer les routes
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("Hello, from Baki-IPFS-Service!"))
-	})
-
-	mux.HandleFunc("/upload", h.UploadFileHandler)
-
-	mux.HandleFunc("/public-files", h.GetPublicFilesHandler)
-	mux.HandleFunc("/private-files", h.GetAllFilesForAPIKeyHandler)
-
-	mux.HandleFunc("/file", h.GetFileByCIDHandler)
-
-	mux.HandleFunc("/file/display", h.DisplayFileByCIDHandler)
-
-	mux.HandleFunc("/search-public-files", h.SearchPublicFilesHandler)
-
-	mux.HandleFunc("/file/img", h.GetImageByCIDHandler)
-	mux.HandleFunc("/file/private/img", h.GetPrivateImageByCIDHandler)
-
-	mux.HandleFunc("/file/toggle-private", h.ToggleFilePrivacyHandler)
-
-
-	mux.HandleFunc("/file/lottie", h.GetLottieFileByCIDHandler)
-
-	mux.HandleFunc("/cid-themes", h.GetCidThemesHandler)
-	mux.HandleFunc("/cid-themes/add", h.AddCidThemeHandler)
-	mux.HandleFunc("/cid-themes/update", h.UpdateCidThemeHandler)
-	mux.HandleFunc("/cid-themes/delete", h.DeleteCidThemeHandler)
-
-	mux.HandleFunc("/docs/create", h.CreateDocHandler)
-	mux.HandleFunc("/docs/get", h.GetDocHandler)
-	mux.HandleFunc("/docs/update", h.UpdateDocHandler)
-	mux.HandleFunc("/docs/delete", h.DeleteDocHandler)
-	mux.HandleFunc("/docs/all", h.GetAllDocsHandler)
-
-	// Configuration CORS
-	handlerWithCORS := cors.CORSMiddleware(mux)
+	h := handler.New(s, index, docsIndex, node)
 
 	// Démarrer le serveur
 	log.Println("Serveur IPFS démarré sur le port 8085")
-	log.Fatal(http.ListenAndServe(":8085", handlerWithCORS))
+	log.Fatal(http.ListenAndServe(":8085", newServer(h)))
+}
+
+// newServer construit le mux complet (API v1 et alias dépréciés) enveloppé du
+// middleware CORS
+func newServer(h *handler.Handler) http.Handler {
+	return cors.CORSMiddleware(router.New(h))
 }
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/TomPo62/bakiverse-ipfs-service-go/pkg/database"
+)
+
+const migrateUsage = "usage: ipfs-api migrate up | down [n] | status"
+
+// runMigrate exécute la sous-commande `migrate`
+func runMigrate(db *sql.DB, dialect database.Dialect, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := database.MigrateUp(db, dialect)
+		for _, m := range applied {
+			fmt.Printf("appliquée : %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("Schéma déjà à jour")
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("nombre de migrations invalide : %q", args[1])
+			}
+			steps = n
+		}
+		reverted, err := database.MigrateDown(db, dialect, steps)
+		for _, m := range reverted {
+			fmt.Printf("annulée : %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+
+	case "status":
+		status, err := database.Status(db, dialect)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNOM\tÉTAT\tAPPLIQUÉE LE")
+		for _, s := range status {
+			state := "en attente"
+			if s.Applied {
+				state = "appliquée"
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, state, s.AppliedAt)
+		}
+		return tw.Flush()
+
+	default:
+		return errors.New(migrateUsage)
+	}
+	return nil
+}
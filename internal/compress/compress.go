@@ -0,0 +1,90 @@
+// Package compress précalcule les encodages gzip et brotli des fichiers
+// servis et choisit celui qui convient à l'en-tête Accept-Encoding.
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Encodages pris en charge, dans l'ordre de préférence du serveur
+const (
+	Brotli = "br"
+	Gzip   = "gzip"
+)
+
+// Supported liste les encodages précalculés
+var Supported = []string{Brotli, Gzip}
+
+// Encode compresse data au niveau maximal : le coût n'est payé qu'une fois
+// par CID
+func Encode(data []byte, encoding string) ([]byte, error) {
+	var buf bytes.Buffer
+	switch encoding {
+	case Brotli:
+		w := brotli.NewWriterLevel(&buf, brotli.BestCompression)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+	case Gzip:
+		w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("compress: unsupported encoding %q", encoding)
+	}
+	return buf.Bytes(), nil
+}
+
+// Negotiate renvoie l'encodage de Supported à utiliser pour l'en-tête
+// Accept-Encoding donné, ou "" pour servir le contenu tel quel. À qualité
+// égale, l'ordre de Supported départage.
+func Negotiate(acceptEncoding string) string {
+	q := map[string]float64{}
+	wildcard := -1.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		quality := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				quality = f
+			}
+		}
+		if name == "*" {
+			wildcard = quality
+		} else {
+			q[name] = quality
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, enc := range Supported {
+		quality, ok := q[enc]
+		if !ok {
+			quality = wildcard
+		}
+		if quality > bestQ {
+			best, bestQ = enc, quality
+		}
+	}
+	return best
+}
@@ -0,0 +1,58 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestNegotiate(t *testing.T) {
+	cases := map[string]string{
+		"":                       "",
+		"identity":               "",
+		"gzip":                   Gzip,
+		"gzip, deflate, br":      Brotli,
+		"br;q=0.5, gzip":         Gzip,
+		"br;q=0, gzip;q=0":       "",
+		"*":                      Brotli,
+		"*;q=0.1, br;q=0":        Gzip,
+		" GZIP ; q=0.8 , br;q=x": Brotli,
+	}
+	for header, want := range cases {
+		if got := Negotiate(header); got != want {
+			t.Errorf("Negotiate(%q) = %q, attendu %q", header, got, want)
+		}
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	data := []byte(strings.Repeat(`{"ty":4,"ks":{"o":{"a":0,"k":100}}}`, 50))
+
+	gz, err := Encode(data, Gzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _ := gzip.NewReader(bytes.NewReader(gz))
+	if got, _ := io.ReadAll(r); !bytes.Equal(got, data) {
+		t.Error("aller-retour gzip incorrect")
+	}
+
+	br, err := Encode(data, Brotli)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := io.ReadAll(brotli.NewReader(bytes.NewReader(br))); !bytes.Equal(got, data) {
+		t.Error("aller-retour brotli incorrect")
+	}
+	if len(br) >= len(data) || len(gz) >= len(data) {
+		t.Errorf("aucun gain : %d octets, gzip %d, brotli %d", len(data), len(gz), len(br))
+	}
+
+	if _, err := Encode(data, "zstd"); err == nil {
+		t.Error("zstd accepté")
+	}
+}
@@ -0,0 +1,51 @@
+package docsite
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"io"
+	"sort"
+	"time"
+)
+
+// names renvoie les chemins des fichiers triés, pour des archives stables
+func names(files map[string][]byte) []string {
+	out := make([]string, 0, len(files))
+	for name := range files {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// WriteZip écrit les fichiers du site dans une archive zip
+func WriteZip(w io.Writer, files map[string][]byte) error {
+	zw := zip.NewWriter(w)
+	now := time.Now()
+	for _, name := range names(files) {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(files[name]); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// WriteTar écrit les fichiers du site dans une archive tar
+func WriteTar(w io.Writer, files map[string][]byte) error {
+	tw := tar.NewWriter(w)
+	now := time.Now()
+	for _, name := range names(files) {
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(files[name])), ModTime: now, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
@@ -0,0 +1,313 @@
+// Package docsite génère un site statique à partir de l'arborescence des
+// documents : une page HTML par document avec navigation, une page
+// d'accueil, une feuille de style et un index de recherche JSON. Les liens
+// sont relatifs, le site se sert donc depuis n'importe quel répertoire ou
+// passerelle IPFS.
+package docsite
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"html/template"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/markdown"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/store"
+)
+
+//go:embed page.html
+var pageHTML string
+
+//go:embed style.css
+var styleCSS []byte
+
+var pageTemplate = template.Must(template.New("page").Parse(pageHTML))
+
+// Fichiers à la racine du site, qu'aucune page ne doit masquer
+const (
+	indexFile  = "index.html"
+	styleFile  = "style.css"
+	searchFile = "search-index.json"
+)
+
+var rootFiles = map[string]bool{indexFile: true, styleFile: true, searchFile: true}
+
+// Options règle la génération
+type Options struct {
+	// Title est le nom du site (« Documentation » par défaut)
+	Title string
+	// Gateway préfixe les URL des fichiers IPFS référencés par les
+	// documents : <Gateway>/ipfs/<cid> (par défaut relatives à l'hôte qui
+	// sert le site)
+	Gateway string
+}
+
+// SearchEntry est l'entrée d'un document dans search-index.json
+type SearchEntry struct {
+	ID       int      `json:"id"`
+	Title    string   `json:"title"`
+	Path     string   `json:"path"`
+	URL      string   `json:"url"`
+	Headings []string `json:"headings"`
+	Text     string   `json:"text"`
+}
+
+// link est un lien de navigation, relatif à la page qui le contient
+type link struct {
+	Title    string
+	URL      string
+	Current  bool
+	Children []*link
+}
+
+// page est le modèle d'une page
+type page struct {
+	Site        string
+	Title       string
+	Root        string
+	HTML        template.HTML
+	TOC         []markdown.Heading
+	Nav         []*link
+	Breadcrumbs []*link
+	Prev, Next  *link
+}
+
+// site indexe les documents à publier
+type site struct {
+	docs     map[int]*store.Doc
+	children map[int][]*store.Doc // clé 0 : documents de premier niveau
+	order    []*store.Doc         // ordre de lecture : parcours préfixe
+	slugs    map[int]string       // répertoire de la page de chaque document
+	byPath   map[string]int       // premier document de chaque chemin
+}
+
+// Build génère le site des documents docs, sous forme de fichiers indexés
+// par leur chemin relatif. Un document dont le parent manque passe au
+// premier niveau.
+func Build(docs []store.Doc, opts Options) (map[string][]byte, error) {
+	if opts.Title == "" {
+		opts.Title = "Documentation"
+	}
+	s := newSite(docs)
+
+	files := map[string][]byte{styleFile: styleCSS}
+	search := []SearchEntry{}
+	for i, d := range s.order {
+		rendered, err := markdown.Render([]byte(d.DocSrc), markdown.Options{
+			Link:    markdown.DocLinks(d.Path, s.linkFrom(d)),
+			FileURL: func(cid string) string { return strings.TrimSuffix(opts.Gateway, "/") + "/ipfs/" + cid },
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		p := page{
+			Site:  opts.Title,
+			Title: d.Title,
+			Root:  s.root(d),
+			HTML:  template.HTML(rendered.HTML), // assaini par markdown.Render
+			Nav:   s.nav(0, d, map[int]bool{}),
+		}
+		for _, h := range rendered.TOC {
+			if h.Level == 2 || h.Level == 3 {
+				p.TOC = append(p.TOC, h)
+			}
+		}
+		for _, a := range s.ancestors(d) {
+			p.Breadcrumbs = append(p.Breadcrumbs, &link{Title: a.Title, URL: s.url(d, a)})
+		}
+		if i > 0 {
+			p.Prev = &link{Title: s.order[i-1].Title, URL: s.url(d, s.order[i-1])}
+		}
+		if i+1 < len(s.order) {
+			p.Next = &link{Title: s.order[i+1].Title, URL: s.url(d, s.order[i+1])}
+		}
+		var buf bytes.Buffer
+		if err := pageTemplate.Execute(&buf, p); err != nil {
+			return nil, err
+		}
+		files[s.slugs[d.ID]+"/"+indexFile] = buf.Bytes()
+
+		entry := SearchEntry{ID: d.ID, Title: d.Title, Path: d.Path, URL: slugURL(s.slugs[d.ID]),
+			Headings: []string{}, Text: markdown.PlainText([]byte(d.DocSrc))}
+		for _, h := range rendered.TOC {
+			entry.Headings = append(entry.Headings, h.Text)
+		}
+		search = append(search, entry)
+	}
+
+	var buf bytes.Buffer
+	if err := pageTemplate.Execute(&buf, page{Site: opts.Title, Nav: s.nav(0, nil, map[int]bool{})}); err != nil {
+		return nil, err
+	}
+	files[indexFile] = buf.Bytes()
+
+	index, err := json.MarshalIndent(map[string]interface{}{"docs": search}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	files[searchFile] = index
+	return files, nil
+}
+
+func newSite(docs []store.Doc) *site {
+	s := &site{
+		docs:     make(map[int]*store.Doc, len(docs)),
+		children: map[int][]*store.Doc{},
+		slugs:    map[int]string{},
+		byPath:   map[string]int{},
+	}
+	for i := range docs {
+		s.docs[docs[i].ID] = &docs[i]
+	}
+	ids := make([]int, 0, len(docs))
+	for i := range docs {
+		d := &docs[i]
+		ids = append(ids, d.ID)
+		parent := 0
+		if d.ParentID != nil && s.docs[*d.ParentID] != nil {
+			parent = *d.ParentID
+		}
+		s.children[parent] = append(s.children[parent], d)
+	}
+	for _, siblings := range s.children {
+		sort.Slice(siblings, func(i, j int) bool {
+			if siblings[i].Position != siblings[j].Position {
+				return siblings[i].Position < siblings[j].Position
+			}
+			return siblings[i].ID < siblings[j].ID
+		})
+	}
+
+	// Les chemins les plus anciens gardent leur répertoire en cas de doublon
+	sort.Ints(ids)
+	used := map[string]bool{}
+	plain := make(map[int]string, len(ids))
+	for _, id := range ids {
+		d := s.docs[id]
+		slug := strings.TrimSuffix(strings.TrimPrefix(path.Clean("/"+strings.TrimSpace(d.Path)), "/"), ".md")
+		if slug == "" {
+			slug = "doc"
+		}
+		plain[id] = slug
+		first, _, _ := strings.Cut(slug, "/")
+		if !used[slug] && path.Base(slug) != indexFile && !rootFiles[first] {
+			used[slug] = true
+			s.slugs[id] = slug
+		}
+		key := "/" + strings.TrimPrefix(d.Path, "/")
+		if _, ok := s.byPath[key]; !ok {
+			s.byPath[key] = id
+		}
+	}
+	// Les doublons ne reçoivent un suffixe qu'une fois tous les chemins
+	// littéraux réservés : /a-2 garde son répertoire face au second /a
+	for _, id := range ids {
+		if _, ok := s.slugs[id]; ok {
+			continue
+		}
+		slug := tagged(plain[id], "-"+strconv.Itoa(id))
+		for n := 2; used[slug]; n++ {
+			slug = tagged(plain[id], "-"+strconv.Itoa(id)+"-"+strconv.Itoa(n))
+		}
+		used[slug] = true
+		s.slugs[id] = slug
+	}
+
+	// visited protège des cycles que des données anciennes pourraient contenir
+	visited := map[int]bool{}
+	var walk func(parent int)
+	walk = func(parent int) {
+		for _, d := range s.children[parent] {
+			if !visited[d.ID] {
+				visited[d.ID] = true
+				s.order = append(s.order, d)
+				walk(d.ID)
+			}
+		}
+	}
+	walk(0)
+	return s
+}
+
+// tagged ajoute tag à slug ; un premier segment qui est un fichier racine
+// (style.css/intro) est marqué lui aussi, sans quoi le répertoire de la page
+// et le fichier porteraient le même nom
+func tagged(slug, tag string) string {
+	first, rest, nested := strings.Cut(slug, "/")
+	if nested && rootFiles[first] {
+		slug = first + tag + "/" + rest
+		if path.Base(rest) != indexFile {
+			return slug
+		}
+	}
+	return slug + tag
+}
+
+// slugURL échappe chaque segment de slug pour un lien : un chemin contenant
+// # ou ? resterait sinon coupé
+func slugURL(slug string) string {
+	segments := strings.Split(slug, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return strings.Join(segments, "/") + "/" + indexFile
+}
+
+// root renvoie le préfixe qui ramène de la page de d à la racine du site
+func (s *site) root(d *store.Doc) string {
+	return strings.Repeat("../", strings.Count(s.slugs[d.ID], "/")+1)
+}
+
+// url renvoie le lien de la page de from vers celle de to
+func (s *site) url(from, to *store.Doc) string {
+	return s.root(from) + slugURL(s.slugs[to.ID])
+}
+
+// linkFrom résout, pour markdown.DocLinks, le chemin d'un document en lien
+// depuis la page de from
+func (s *site) linkFrom(from *store.Doc) func(string) (string, bool) {
+	return func(p string) (string, bool) {
+		id, ok := s.byPath[p]
+		if !ok {
+			return "", false
+		}
+		return s.url(from, s.docs[id]), true
+	}
+}
+
+// nav construit l'arborescence de navigation vue depuis la page de current
+// (nil : page d'accueil)
+func (s *site) nav(parent int, current *store.Doc, visited map[int]bool) []*link {
+	var links []*link
+	for _, d := range s.children[parent] {
+		if visited[d.ID] {
+			continue
+		}
+		visited[d.ID] = true
+		l := &link{Title: d.Title, URL: slugURL(s.slugs[d.ID])}
+		if current != nil {
+			l.URL = s.url(current, d)
+			l.Current = d.ID == current.ID
+		}
+		l.Children = s.nav(d.ID, current, visited)
+		links = append(links, l)
+	}
+	return links
+}
+
+// ancestors renvoie les ancêtres de d, du premier niveau au parent
+func (s *site) ancestors(d *store.Doc) []*store.Doc {
+	var out []*store.Doc
+	seen := map[int]bool{d.ID: true}
+	for p := d.ParentID; p != nil && s.docs[*p] != nil && !seen[*p]; p = s.docs[*p].ParentID {
+		seen[*p] = true
+		out = append([]*store.Doc{s.docs[*p]}, out...)
+	}
+	return out
+}
@@ -0,0 +1,135 @@
+package docsite
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/store"
+)
+
+func intPtr(i int) *int { return &i }
+
+func testDocs() []store.Doc {
+	return []store.Doc{
+		{ID: 1, Title: "Guide", Path: "/guide", DocSrc: "# Guide\n\nVoir [l'installation](guide/install.md#linux).\n", Position: 1},
+		{ID: 2, Title: "Installation", Path: "/guide/install", DocSrc: "# Installation\n\n## Linux\n\n![logo](ipfs://bafy0123456789abcdef0123456789abcdef01234567)\n", ParentID: intPtr(1), Position: 1},
+		{ID: 3, Title: "FAQ", Path: "/faq", DocSrc: "# FAQ\n\n<script>alert(1)</script>[retour](/guide)\n", Position: 2},
+		{ID: 4, Title: "Style", Path: "style.css", DocSrc: "# Style\n", Position: 3},
+	}
+}
+
+func TestBuild(t *testing.T) {
+	files, err := Build(testDocs(), Options{Title: "Bakiverse", Gateway: "https://gw.example/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"index.html", "style.css", "search-index.json", "guide/index.html",
+		"guide/install/index.html", "faq/index.html", "style.css-4/index.html"} {
+		if files[name] == nil {
+			t.Errorf("fichier %s absent", name)
+		}
+	}
+
+	install := string(files["guide/install/index.html"])
+	for _, want := range []string{
+		`href="../../style.css"`,
+		`<a href="../../guide/index.html">Guide</a> / `,
+		`<li class="current"><a href="../../guide/install/index.html">Installation</a>`,
+		`<a href="#linux">Linux</a>`,
+		`src="https://gw.example/ipfs/bafy0123456789abcdef0123456789abcdef01234567"`,
+		`rel="prev" href="../../guide/index.html"`,
+		`rel="next" href="../../faq/index.html"`,
+	} {
+		if !strings.Contains(install, want) {
+			t.Errorf("page installation sans %s :\n%s", want, install)
+		}
+	}
+	if guide := string(files["guide/index.html"]); !strings.Contains(guide, `href="../guide/install/index.html#linux"`) {
+		t.Errorf("lien relatif non résolu :\n%s", guide)
+	}
+	faq := string(files["faq/index.html"])
+	if strings.Contains(faq, "<script>") || !strings.Contains(faq, `href="../guide/index.html"`) {
+		t.Errorf("page FAQ inattendue :\n%s", faq)
+	}
+	if home := string(files["index.html"]); !strings.Contains(home, `href="guide/install/index.html"`) {
+		t.Errorf("accueil sans lien vers l'installation :\n%s", home)
+	}
+
+	var index struct {
+		Docs []SearchEntry `json:"docs"`
+	}
+	if err := json.Unmarshal(files["search-index.json"], &index); err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Docs) != 4 || index.Docs[1].URL != "guide/install/index.html" ||
+		strings.Join(index.Docs[1].Headings, ",") != "Installation,Linux" || !strings.Contains(index.Docs[0].Text, "installation") {
+		t.Errorf("index de recherche inattendu : %+v", index.Docs)
+	}
+}
+
+func TestSlugCollisions(t *testing.T) {
+	s := newSite([]store.Doc{
+		{ID: 1, Title: "A", Path: "/a"},
+		{ID: 2, Title: "A bis", Path: "/a"},
+		{ID: 3, Title: "A-2", Path: "/a-2"},
+		{ID: 4, Title: "A-2-2", Path: "/a-2-2"},
+		{ID: 5, Title: "Intro", Path: "/style.css/intro"},
+		{ID: 6, Title: "Index", Path: "/search-index.json/index.html"},
+	})
+	for id, want := range map[int]string{1: "a", 2: "a-2-3", 3: "a-2", 4: "a-2-2", 5: "style.css-5/intro", 6: "search-index.json-6/index.html-6"} {
+		if s.slugs[id] != want {
+			t.Errorf("répertoire du document %d = %q, attendu %q", id, s.slugs[id], want)
+		}
+	}
+
+	// Les liens échappent chaque segment du répertoire
+	files, err := Build([]store.Doc{{ID: 1, Title: "C#", Path: "/c#/intro?"}}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files["c#/intro?/index.html"] == nil || !strings.Contains(string(files["index.html"]), `href="c%23/intro%3F/index.html"`) {
+		t.Errorf("lien vers un chemin avec # et ? non échappé :\n%s", files["index.html"])
+	}
+}
+
+func TestArchives(t *testing.T) {
+	files := map[string][]byte{"index.html": []byte("<p>accueil</p>"), "a/index.html": []byte("<p>a</p>")}
+
+	var buf bytes.Buffer
+	if err := WriteZip(&buf, files); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != 2 || zr.File[0].Name != "a/index.html" {
+		t.Fatalf("zip inattendu : %v", zr.File)
+	}
+
+	buf.Reset()
+	if err := WriteTar(&buf, files); err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(&buf)
+	got := map[string]string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(tr)
+		got[hdr.Name] = string(b)
+	}
+	if len(got) != 2 || got["index.html"] != "<p>accueil</p>" {
+		t.Errorf("tar inattendu : %v", got)
+	}
+}
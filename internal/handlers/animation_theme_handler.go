@@ -2,41 +2,88 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/store"
+)
+
+// CidTheme represents a theme: a main animation, per-role animations and
+// display settings
+type CidTheme = store.Theme
+
+// Rôles et variantes acceptés pour les animations d'un thème
+var (
+	themeRolePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+	themeVariants    = map[string]bool{"": true, "light": true, "dark": true}
 )
 
-// CidTheme represents a theme with a CID and a name
-type CidTheme struct {
-	ID   int    `json:"id"`
-	CID  string `json:"cid"`
-	Name string `json:"name"`
+const maxThemeCategoryLength = 64
+
+// themeQuery lit les filtres category et enabled de la requête
+func themeQuery(r *http.Request) (store.ThemeQuery, error) {
+	q := store.ThemeQuery{Category: strings.TrimSpace(r.URL.Query().Get("category"))}
+	if v := r.URL.Query().Get("enabled"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, err
+		}
+		q.Enabled = &enabled
+	}
+	return q, nil
 }
 
-// GetCidThemesHandler handles the GET request to retrieve all cidThemes
+// GetCidThemesHandler handles the GET request to retrieve a page of
+// cidThemes, optionally filtered by category and enabled flag
 func (h *Handler) GetCidThemesHandler(w http.ResponseWriter, r *http.Request) {
-	// Vérifier que la requête est bien en méthode GET
-	if r.Method != http.MethodGet {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+	q, err := themeQuery(r)
+	if err != nil {
+		http.Error(w, "Invalid enabled value", http.StatusBadRequest)
 		return
 	}
+	page, limit := pagination(r)
 
-	// Récupérer tous les cidThemes depuis la base de données
-	rows, err := h.DB.Query("SELECT id, cid, name FROM cid_themes")
+	cidThemes, total, err := h.Themes.List(r.Context(), q, limit, (page-1)*limit)
 	if err != nil {
 		http.Error(w, "Failed to query cidThemes", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
+	if cidThemes == nil {
+		cidThemes = []CidTheme{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"themes":     cidThemes,
+		"total":      total,
+		"page":       page,
+		"limit":      limit,
+		"totalPages": (total + limit - 1) / limit,
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
 
-	var cidThemes []CidTheme
+// GetCidThemesLegacyHandler keeps the historical /cid-themes contract: every
+// matching cidTheme, as a plain array
+func (h *Handler) GetCidThemesLegacyHandler(w http.ResponseWriter, r *http.Request) {
+	q, err := themeQuery(r)
+	if err != nil {
+		http.Error(w, "Invalid enabled value", http.StatusBadRequest)
+		return
+	}
 
-	for rows.Next() {
-		var theme CidTheme
-		if err := rows.Scan(&theme.ID, &theme.CID, &theme.Name); err != nil {
-			http.Error(w, "Failed to scan cidTheme", http.StatusInternalServerError)
-			return
-		}
-		cidThemes = append(cidThemes, theme)
+	// Récupérer tous les cidThemes depuis la base de données
+	cidThemes, _, err := h.Themes.List(r.Context(), q, 0, 0)
+	if err != nil {
+		http.Error(w, "Failed to query cidThemes", http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -46,138 +93,263 @@ func (h *Handler) GetCidThemesHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// AddCidThemeHandler handles the POST request to add a new cidTheme
-func (h *Handler) AddCidThemeHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+// GetCidThemeHandler handles the GET request to retrieve a single cidTheme
+func (h *Handler) GetCidThemeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID parameter", http.StatusBadRequest)
 		return
 	}
 
-	// Récupérer l'API key depuis les headers
-	apiKey := r.Header.Get("X-API-Key")
-	if apiKey == "" {
-		http.Error(w, "Missing API Key", http.StatusUnauthorized)
+	theme, err := h.Themes.Get(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "CidTheme not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to query cidTheme", http.StatusInternalServerError)
 		return
 	}
 
-	// Vérifier si l'API key existe et récupérer l'ID et les permissions
-	var apiKeyID int
-	var permission string
-	err := h.DB.QueryRow("SELECT id, permissions FROM api_keys WHERE api_key = ?", apiKey).Scan(&apiKeyID, &permission)
-	if err != nil {
-		http.Error(w, "Invalid API Key", http.StatusUnauthorized)
+	// L'ETag sert de condition If-Match aux mises à jour
+	etag := themeETag(theme)
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
-	// Vérifier que la permission est bien "write"
-	if permission != "write" {
-		http.Error(w, "Insufficient permissions", http.StatusForbidden)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(theme)
+}
+
+// AddCidThemeHandler handles the POST request to add a new cidTheme
+func (h *Handler) AddCidThemeHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier l'API key et que la permission est bien "write"
+	if _, ok := h.requireWriteAPIKey(w, r); !ok {
 		return
 	}
 
-
-	var theme CidTheme
+	theme := CidTheme{Enabled: true} // activé si le champ est omis
 	if err := json.NewDecoder(r.Body).Decode(&theme); err != nil {
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
+	theme.ID = 0
+	if !h.validateTheme(w, r, &theme) {
+		return
+	}
 
-	// Insérer le nouveau cidTheme dans la base de données
-	_, err = h.DB.Exec("INSERT INTO cid_themes (cid, name) VALUES (?, ?)", theme.CID, theme.Name)
-	if err != nil {
+	// Insérer le nouveau cidTheme dans la base de données ; l'index unique
+	// tranche entre deux créations concurrentes du même nom
+	err := h.Themes.Create(r.Context(), &theme)
+	if errors.Is(err, store.ErrDuplicateName) {
+		http.Error(w, fmt.Sprintf("A cidTheme named %q already exists", theme.Name), http.StatusConflict)
+		return
+	} else if err != nil {
 		http.Error(w, "Failed to insert cidTheme", http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", themeETag(&theme))
 	w.WriteHeader(http.StatusCreated)
-	w.Write([]byte("CidTheme added successfully"))
+	json.NewEncoder(w).Encode(theme)
 }
 
 func (h *Handler) UpdateCidThemeHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPut {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+	// Vérifier l'API key et que la permission est bien "write"
+	if _, ok := h.requireWriteAPIKey(w, r); !ok {
 		return
 	}
 
-	// Récupérer l'API key depuis les headers
-	apiKey := r.Header.Get("X-API-Key")
-	if apiKey == "" {
-		http.Error(w, "Missing API Key", http.StatusUnauthorized)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
-
-	// Vérifier si l'API key existe et récupérer l'ID et les permissions
-	var apiKeyID int
-	var permission string
-	err := h.DB.QueryRow("SELECT id, permissions FROM api_keys WHERE api_key = ?", apiKey).Scan(&apiKeyID, &permission)
-	if err != nil {
-		http.Error(w, "Invalid API Key", http.StatusUnauthorized)
+	theme := CidTheme{Enabled: true} // activé si le champ est omis
+	if err := json.Unmarshal(body, &theme); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
 
-	// Vérifier que la permission est bien "write"
-	if permission != "write" {
-		http.Error(w, "Insufficient permissions", http.StatusForbidden)
-		return
+	// Sur /v1/themes/{id}, l'ID du chemin fait foi
+	legacy := r.PathValue("id") == ""
+	if idStr := r.PathValue("id"); idStr != "" {
+		var err error
+		theme.ID, err = strconv.Atoi(idStr)
+		if err != nil {
+			http.Error(w, "Invalid ID parameter", http.StatusBadRequest)
+			return
+		}
 	}
 
-	var theme CidTheme
-	if err := json.NewDecoder(r.Body).Decode(&theme); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+	current, err := h.Themes.Get(r.Context(), theme.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "CidTheme not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to query cidTheme", http.StatusInternalServerError)
+		return
+	}
+	// L'ancienne route ne connaît que id, cid et name : les champs absents
+	// du corps gardent leur valeur au lieu d'être remis à zéro
+	if legacy {
+		theme = *current
+		theme.Animations = append([]store.ThemeAnimation(nil), current.Animations...)
+		theme.RowVersion = 0
+		if err := json.Unmarshal(body, &theme); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+	}
+	// Avec If-Match, seule la version lue par le client peut être remplacée
+	if header := r.Header.Get("If-Match"); header != "" {
+		if !ifMatch(header, themeETag(current)) {
+			preconditionFailed(w, themeETag(current), current)
+			return
+		}
+		theme.RowVersion = current.RowVersion
+	}
+	if !h.validateTheme(w, r, &theme) {
 		return
 	}
 
 	// Mettre à jour le cidTheme dans la base de données
-	_, err = h.DB.Exec("UPDATE cid_themes SET cid = ?, name = ? WHERE id = ?", theme.CID, theme.Name, theme.ID)
-	if err != nil {
+	err = h.Themes.Update(r.Context(), &theme)
+	if errors.Is(err, store.ErrStale) {
+		// Écriture concurrente entre la vérification et la mise à jour
+		if current, err := h.Themes.Get(r.Context(), theme.ID); err == nil {
+			preconditionFailed(w, themeETag(current), current)
+			return
+		}
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "CidTheme not found", http.StatusNotFound)
+		return
+	} else if errors.Is(err, store.ErrDuplicateName) {
+		http.Error(w, fmt.Sprintf("A cidTheme named %q already exists", theme.Name), http.StatusConflict)
+		return
+	} else if err != nil {
 		http.Error(w, "Failed to update cidTheme", http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("CidTheme updated successfully"))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", themeETag(&theme))
+	json.NewEncoder(w).Encode(theme)
 }
 
+// validateTheme vérifie qu'un thème porte un nom libre et ne référence que
+// des fichiers publics adaptés ; sinon elle répond à la requête et renvoie
+// false
+func (h *Handler) validateTheme(w http.ResponseWriter, r *http.Request, theme *CidTheme) bool {
+	theme.CID = strings.TrimSpace(theme.CID)
+	theme.Name = strings.TrimSpace(theme.Name)
+	theme.Category = strings.TrimSpace(theme.Category)
+	theme.PreviewCID = strings.TrimSpace(theme.PreviewCID)
+	if theme.CID == "" || theme.Name == "" {
+		http.Error(w, "cid and name are required", http.StatusBadRequest)
+		return false
+	}
+	if len(theme.Category) > maxThemeCategoryLength {
+		http.Error(w, fmt.Sprintf("category must not exceed %d characters", maxThemeCategoryLength), http.StatusBadRequest)
+		return false
+	}
 
-// DeleteCidThemeHandler handles the DELETE request to delete an existing cidTheme
-func (h *Handler) DeleteCidThemeHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodDelete {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
-		return
+	if !h.checkThemeFile(w, r, theme.CID, "cid", "a Lottie animation", isAnimation) {
+		return false
+	}
+	if theme.PreviewCID != "" && !h.checkThemeFile(w, r, theme.PreviewCID, "preview_cid", "an image", isImage) {
+		return false
 	}
 
-	// Récupérer l'API key depuis les headers
-	apiKey := r.Header.Get("X-API-Key")
-	if apiKey == "" {
-		http.Error(w, "Missing API Key", http.StatusUnauthorized)
-		return
+	if theme.Animations == nil {
+		theme.Animations = []store.ThemeAnimation{}
+	}
+	seen := map[string]bool{}
+	for i := range theme.Animations {
+		a := &theme.Animations[i]
+		a.Role, a.Variant, a.CID = strings.TrimSpace(a.Role), strings.TrimSpace(a.Variant), strings.TrimSpace(a.CID)
+		switch {
+		case !themeRolePattern.MatchString(a.Role):
+			http.Error(w, fmt.Sprintf("animations[%d]: invalid role %q", i, a.Role), http.StatusBadRequest)
+			return false
+		case !themeVariants[a.Variant]:
+			http.Error(w, fmt.Sprintf("animations[%d]: variant must be light, dark or empty", i), http.StatusBadRequest)
+			return false
+		case seen[a.Role+"/"+a.Variant]:
+			http.Error(w, fmt.Sprintf("animations[%d]: duplicate role %q for variant %q", i, a.Role, a.Variant), http.StatusBadRequest)
+			return false
+		}
+		seen[a.Role+"/"+a.Variant] = true
+		if !h.checkThemeFile(w, r, a.CID, fmt.Sprintf("animations[%d].cid", i), "a Lottie animation", isAnimation) {
+			return false
+		}
 	}
 
-	// Vérifier si l'API key existe et récupérer l'ID et les permissions
-	var apiKeyID int
-	var permission string
-	err := h.DB.QueryRow("SELECT id, permissions FROM api_keys WHERE api_key = ?", apiKey).Scan(&apiKeyID, &permission)
-	if err != nil {
-		http.Error(w, "Invalid API Key", http.StatusUnauthorized)
-		return
+	// Les noms sont uniques, sans tenir compte de la casse
+	other, err := h.Themes.FindByName(r.Context(), theme.Name)
+	if err == nil && other.ID != theme.ID {
+		http.Error(w, fmt.Sprintf("A cidTheme named %q already exists (id %d)", other.Name, other.ID), http.StatusConflict)
+		return false
+	} else if err != nil && !errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Failed to query cidTheme", http.StatusInternalServerError)
+		return false
 	}
+	return true
+}
 
-	// Vérifier que la permission est bien "write"
-	if permission != "write" {
-		http.Error(w, "Insufficient permissions", http.StatusForbidden)
+func isAnimation(f *store.File) bool {
+	return f.Kind == store.KindLottie || f.Kind == store.KindDotLottie || f.MimeType == "application/json"
+}
+
+func isImage(f *store.File) bool {
+	return strings.HasPrefix(f.MimeType, "image/")
+}
+
+// checkThemeFile vérifie que cid désigne un fichier public accepté par ok
+// (what le décrit dans le message d'erreur)
+func (h *Handler) checkThemeFile(w http.ResponseWriter, r *http.Request, cid, field, what string, ok func(*store.File) bool) bool {
+	if cid == "" {
+		http.Error(w, field+" is required", http.StatusBadRequest)
+		return false
+	}
+	f, err := h.Files.Find(r.Context(), cid, store.FileQuery{PublicOnly: true})
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, field+" does not reference a public file", http.StatusBadRequest)
+		return false
+	} else if err != nil {
+		http.Error(w, "Error retrieving file information", http.StatusInternalServerError)
+		return false
+	}
+	if !ok(f) {
+		http.Error(w, field+" does not reference "+what, http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// DeleteCidThemeHandler handles the DELETE request to delete an existing cidTheme
+func (h *Handler) DeleteCidThemeHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier l'API key et que la permission est bien "write"
+	if _, ok := h.requireWriteAPIKey(w, r); !ok {
 		return
 	}
 
 	// Récupérer l'ID du cidTheme depuis l'URL
-	id := r.URL.Query().Get("id")
-	if id == "" {
+	id, err := strconv.Atoi(pathOrQuery(r, "id"))
+	if err != nil {
 		http.Error(w, "Missing ID parameter", http.StatusBadRequest)
 		return
 	}
 
 	// Supprimer le cidTheme de la base de données
-	_, err = h.DB.Exec("DELETE FROM cid_themes WHERE id = ?", id)
-	if err != nil {
+	err = h.Themes.Delete(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "CidTheme not found", http.StatusNotFound)
+		return
+	} else if err != nil {
 		http.Error(w, "Failed to delete cidTheme", http.StatusInternalServerError)
 		return
 	}
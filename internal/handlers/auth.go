@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/store"
+)
+
+// requireAPIKey vérifie l'en-tête X-API-Key et renvoie la clé correspondante.
+// En cas d'échec, la réponse d'erreur est déjà écrite.
+func (h *Handler) requireAPIKey(w http.ResponseWriter, r *http.Request) (*store.APIKey, bool) {
+	// Récupérer l'API key depuis les headers
+	apiKey := r.Header.Get("X-API-Key")
+	if apiKey == "" {
+		http.Error(w, "Missing API Key", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	// Vérifier si l'API key existe et récupérer son ID
+	key, err := h.Keys.Lookup(r.Context(), apiKey)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Invalid API Key", http.StatusUnauthorized)
+		return nil, false
+	} else if err != nil {
+		log.Println("Error checking API key:", err)
+		http.Error(w, "Error checking API key", http.StatusInternalServerError)
+		return nil, false
+	}
+	return key, true
+}
+
+// hasAPIKey indique si la requête porte une API key valide, sur une route
+// qui ne l'exige pas
+func (h *Handler) hasAPIKey(r *http.Request) bool {
+	apiKey := r.Header.Get("X-API-Key")
+	if apiKey == "" {
+		return false
+	}
+	_, err := h.Keys.Lookup(r.Context(), apiKey)
+	return err == nil
+}
+
+// requireWriteAPIKey vérifie en plus que la clé possède la permission "write"
+func (h *Handler) requireWriteAPIKey(w http.ResponseWriter, r *http.Request) (*store.APIKey, bool) {
+	key, ok := h.requireAPIKey(w, r)
+	if !ok {
+		return nil, false
+	}
+	if !key.CanWrite() {
+		http.Error(w, "Insufficient permissions", http.StatusForbidden)
+		return nil, false
+	}
+	return key, true
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/docsite"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/service"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/store"
+)
+
+// docSiteExport est la réponse de ExportDocsHandler pour format=ipfs
+type docSiteExport struct {
+	CID   string `json:"cid"`
+	Docs  int    `json:"docs"`
+	Files int    `json:"files"`
+}
+
+// ExportDocsHandler génère le site statique des documents visibles et le
+// renvoie en archive zip ou tar, ou l'ajoute sur IPFS (format=ipfs) et en
+// renvoie le CID
+func (h *Handler) ExportDocsHandler(w http.ResponseWriter, r *http.Request) {
+	_, hasPermission, err := checkAPIKeyWritePermission(r.Context(), h.Keys, r.Header.Get("X-API-Key"))
+	if err != nil || !hasPermission {
+		http.Error(w, "API key non autorisée ou permissions insuffisantes", http.StatusUnauthorized)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "":
+		format = "zip"
+	case "zip", "tar", "ipfs":
+	default:
+		http.Error(w, "Format invalide : zip, tar ou ipfs", http.StatusBadRequest)
+		return
+	}
+
+	docs, err := h.Docs.List(r.Context())
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération des documents", http.StatusInternalServerError)
+		return
+	}
+	// Le site est public : seuls les documents visibles y entrent
+	live := store.LiveDocs(docs, time.Now())
+	files, err := docsite.Build(live, docsite.Options{
+		Title:   r.URL.Query().Get("title"),
+		Gateway: r.URL.Query().Get("gateway"),
+	})
+	if err != nil {
+		log.Println("Erreur lors de la génération du site des documents :", err)
+		http.Error(w, "Erreur lors de la génération du site", http.StatusInternalServerError)
+		return
+	}
+
+	switch format {
+	case "ipfs":
+		cid, err := service.AddFiles(h.IPFS, files)
+		if err != nil {
+			log.Println("Erreur lors de la publication du site des documents sur IPFS:", err)
+			http.Error(w, "Erreur lors de la publication du site sur IPFS", http.StatusInternalServerError)
+			return
+		}
+		log.Println("Site des documents publié sur IPFS, CID:", cid)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(docSiteExport{CID: cid, Docs: len(live), Files: len(files)})
+
+	default:
+		// L'archive est construite en mémoire pour répondre 500 sans corps
+		// partiel en cas d'erreur
+		var buf bytes.Buffer
+		write, contentType := docsite.WriteZip, "application/zip"
+		if format == "tar" {
+			write, contentType = docsite.WriteTar, "application/x-tar"
+		}
+		if err := write(&buf, files); err != nil {
+			http.Error(w, "Erreur lors de la génération du site", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="docs-site.`+format+`"`)
+		w.Write(buf.Bytes())
+	}
+}
@@ -1,51 +1,52 @@
 package handler
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
+
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/service"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/store"
 )
 
-type Doc struct {
-	ID          int     `json:"id"`
-	Title       string  `json:"title"`
-	Path        string  `json:"path"`
-	DocSrc      string  `json:"doc_src"`
-	Version     float64 `json:"version"`
-	IsChildren  bool    `json:"is_children"`
-	ParentID    *int     `json:"parent_id"`
-	CreatedAt   string  `json:"created_at"`
-	UpdatedAt   string  `json:"updated_at"`
-}
+// Doc est une page de documentation
+type Doc = store.Doc
 
-// checkAPIKeyWritePermission vérifie si l'API key est valide et possède les permissions de write
-func checkAPIKeyWritePermission(db *sql.DB, apiKey string) (bool, error) {
-	var permissions string
-	err := db.QueryRow("SELECT permissions FROM api_keys WHERE api_key = ?", apiKey).Scan(&permissions)
+// checkAPIKeyWritePermission vérifie si l'API key est valide et possède les permissions de write,
+// et renvoie la clé, auteur des révisions
+func checkAPIKeyWritePermission(ctx context.Context, keys store.KeyRepository, apiKey string) (*store.APIKey, bool, error) {
+	key, err := keys.Lookup(ctx, apiKey)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, store.ErrNotFound) {
 			log.Println("API key non trouvée dans la base de données.")
-			return false, nil
+			return nil, false, nil
 		}
 		log.Println("Erreur lors de la vérification des permissions de l'API key:", err)
-		return false, err
+		return nil, false, err
 	}
-	log.Println("Permissions de l'API key:", permissions)
-	return permissions == "write", nil
+	log.Println("Permissions de l'API key:", key.Permissions)
+	return key, key.CanWrite(), nil
 }
 
-// CreateDocHandler gère la création d'un document
-func (h *Handler) CreateDocHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
-		return
+// putDocSrc écrit le contenu du document sur IPFS et renseigne doc.CID
+func (h *Handler) putDocSrc(doc *Doc) error {
+	cid, err := service.AddBytes(h.IPFS, []byte(doc.DocSrc))
+	if err != nil {
+		log.Println("Erreur lors de l'envoi du document sur IPFS:", err)
+		return err
 	}
+	doc.CID = cid
+	return nil
+}
 
+// CreateDocHandler gère la création d'un document
+func (h *Handler) CreateDocHandler(w http.ResponseWriter, r *http.Request) {
 	apiKey := r.Header.Get("X-API-Key")
 	log.Println("API key reçue:", apiKey)
-	hasPermission, err := checkAPIKeyWritePermission(h.DB, apiKey)
+	key, hasPermission, err := checkAPIKeyWritePermission(r.Context(), h.Keys, apiKey)
 	if err != nil {
 		log.Println("Erreur lors de la vérification de l'API key:", err)
 		http.Error(w, "Erreur interne du serveur", http.StatusInternalServerError)
@@ -64,11 +65,13 @@ func (h *Handler) CreateDocHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	log.Println("Données du document à créer:", doc)
+	if !checkDocStatus(w, &doc) {
+		return
+	}
 
 	// Vérification du ParentID si non-nul
 	if doc.ParentID != nil && *doc.ParentID != 0 {
-		var parentExists bool
-		err = h.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM docs WHERE id = ?)", *doc.ParentID).Scan(&parentExists)
+		parentExists, err := h.Docs.Exists(r.Context(), *doc.ParentID)
 		if err != nil {
 			log.Println("Erreur lors de la vérification du ParentID:", err)
 			http.Error(w, "Erreur interne du serveur", http.StatusInternalServerError)
@@ -83,147 +86,251 @@ func (h *Handler) CreateDocHandler(w http.ResponseWriter, r *http.Request) {
 		doc.ParentID = nil // Assurer que ParentID est NULL si non spécifié
 	}
 
-	result, err := h.DB.Exec("INSERT INTO docs (title, path, doc_src, version, is_children, parent_id) VALUES (?, ?, ?, ?, ?, ?)",
-		doc.Title, doc.Path, doc.DocSrc, doc.Version, doc.IsChildren, doc.ParentID)
-	if err != nil {
-		log.Println("Erreur lors de l'insertion du document dans la base de données:", err)
-		http.Error(w, "Erreur lors de la création du document", http.StatusInternalServerError)
+	if err := h.putDocSrc(&doc); err != nil {
+		http.Error(w, "Erreur lors de l'envoi du document sur IPFS", http.StatusInternalServerError)
 		return
 	}
-
-	docID, err := result.LastInsertId()
-	if err != nil {
-		log.Println("Erreur lors de la récupération de l'ID du document créé:", err)
-		http.Error(w, "Erreur lors de la récupération de l'ID du document", http.StatusInternalServerError)
+	if err := h.Docs.Create(r.Context(), &doc, key.ID); err != nil {
+		log.Println("Erreur lors de l'insertion du document dans la base de données:", err)
+		docTreeError(w, err, "Erreur lors de la création du document")
 		return
 	}
-	doc.ID = int(docID)
 
 	log.Println("Document créé avec succès, ID:", doc.ID)
+	h.syncDocIndex(r.Context(), doc.ID)
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", docETag(&doc))
 	json.NewEncoder(w).Encode(doc)
 }
 
 // GetDocHandler gère la récupération d'un document spécifique
 func (h *Handler) GetDocHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
-		return
-	}
-
-	docIDStr := r.URL.Query().Get("id")
+	docIDStr := pathOrQuery(r, "id")
 	docID, err := strconv.Atoi(docIDStr)
 	if err != nil {
 		http.Error(w, "ID de document invalide", http.StatusBadRequest)
 		return
 	}
+	format, ok := docFormat(w, r)
+	if !ok {
+		return
+	}
 
-	var doc Doc
-	err = h.DB.QueryRow("SELECT id, title, path, doc_src, version, is_children, parent_id, created_at, updated_at FROM docs WHERE id = ?", docID).Scan(
-		&doc.ID, &doc.Title, &doc.Path, &doc.DocSrc, &doc.Version, &doc.IsChildren, &doc.ParentID, &doc.CreatedAt, &doc.UpdatedAt)
-	if err == sql.ErrNoRows {
+	doc, err := h.Docs.Get(r.Context(), docID)
+	if errors.Is(err, store.ErrNotFound) {
 		http.Error(w, "Document non trouvé", http.StatusNotFound)
 		return
 	} else if err != nil {
 		http.Error(w, "Erreur lors de la récupération du document", http.StatusInternalServerError)
 		return
 	}
+	// Sans clé en écriture, un document non publié n'existe pas
+	if !h.readableDoc(w, r, docID) {
+		return
+	}
+
+	// Avec cid, le document tel qu'il était dans cette révision, contenu lu sur IPFS
+	if cid := r.URL.Query().Get("cid"); cid != "" {
+		rev, err := h.Docs.FindRevisionByCID(r.Context(), docID, cid)
+		if err == nil && !revisionReadable(rev, h.canPreview(r)) {
+			err = store.ErrNotFound
+		}
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Aucune révision du document pour ce CID", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Erreur lors de la récupération du document", http.StatusInternalServerError)
+			return
+		}
+		content, err := h.IPFS.DownloadFileFromIPFS(cid)
+		if err != nil {
+			log.Println("Erreur lors de la récupération de", cid, "pour le document", docID, ":", err)
+			http.Error(w, "Erreur lors de la récupération du document sur IPFS", http.StatusInternalServerError)
+			return
+		}
+		doc.Title, doc.Path, doc.Version, doc.CID = rev.Title, rev.Path, float64(rev.Version), cid
+		doc.DocSrc, doc.UpdatedAt = string(content), rev.CreatedAt
+	} else if format == "markdown" {
+		// L'ETag sert de condition If-Match aux mises à jour ; le rendu HTML,
+		// qui dépend aussi des autres documents, n'en a pas
+		etag := docETag(doc)
+		w.Header().Set("ETag", etag)
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
+	if format == "html" {
+		docs, err := h.readableOutline(r)
+		if err != nil {
+			http.Error(w, "Erreur lors de la récupération des documents", http.StatusInternalServerError)
+			return
+		}
+		rendered, err := renderDoc(doc, docs)
+		if err != nil {
+			log.Println("Erreur lors du rendu du document", docID, ":", err)
+			http.Error(w, "Erreur lors du rendu du document", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(rendered)
+		return
+	}
 	json.NewEncoder(w).Encode(doc)
 }
 
-// UpdateDocHandler gère la mise à jour d'un document
+// UpdateDocHandler gère la mise à jour d'un document : PUT remplace le
+// document, PATCH lui applique un JSON Merge Patch
 func (h *Handler) UpdateDocHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPut {
-		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
-		return
-	}
-
 	apiKey := r.Header.Get("X-API-Key")
-	hasPermission, err := checkAPIKeyWritePermission(h.DB, apiKey)
+	key, hasPermission, err := checkAPIKeyWritePermission(r.Context(), h.Keys, apiKey)
 	if err != nil || !hasPermission {
 		http.Error(w, "API key non autorisée ou permissions insuffisantes", http.StatusUnauthorized)
 		return
 	}
 
+	// current est la version lue, base du patch ou de la condition If-Match
 	var doc Doc
-	if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
-		http.Error(w, "Erreur lors de la décodage du document", http.StatusBadRequest)
+	var current *Doc
+	if r.Method == http.MethodPatch {
+		if !isMergePatch(r) {
+			http.Error(w, "Le corps doit être un JSON Merge Patch (application/merge-patch+json)", http.StatusUnsupportedMediaType)
+			return
+		}
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "ID de document invalide", http.StatusBadRequest)
+			return
+		}
+		if current, err = h.Docs.Get(r.Context(), id); err != nil {
+			docTreeError(w, err, "Erreur lors de la récupération du document")
+			return
+		}
+		// Sans position dans le patch, le document garde sa place ou passe à
+		// la fin de son nouveau parent, comme avec PUT
+		base := *current
+		base.Position = 0
+		if err := applyMergePatch(&base, r.Body, &doc, false); err != nil {
+			http.Error(w, "Erreur lors de la décodage du patch", http.StatusBadRequest)
+			return
+		}
+		doc.ID = id
+	} else {
+		if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+			http.Error(w, "Erreur lors de la décodage du document", http.StatusBadRequest)
+			return
+		}
+		// Sur /v1/docs/{id}, l'ID du chemin fait foi
+		if idStr := r.PathValue("id"); idStr != "" {
+			doc.ID, err = strconv.Atoi(idStr)
+			if err != nil {
+				http.Error(w, "ID de document invalide", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	doc.ParentID = normalizeParentID(doc.ParentID) // 0 désigne la racine, comme à la création
+	if !checkDocStatus(w, &doc) {
 		return
 	}
 
-	_, err = h.DB.Exec("UPDATE docs SET title = ?, path = ?, doc_src = ?, version = ?, is_children = ?, parent_id = ? WHERE id = ?",
-		doc.Title, doc.Path, doc.DocSrc, doc.Version, doc.IsChildren, doc.ParentID, doc.ID)
+	// Avec If-Match, la mise à jour n'a lieu que sur la version lue par le client
+	ifMatchHeader := r.Header.Get("If-Match")
+	if ifMatchHeader != "" {
+		if current == nil {
+			if current, err = h.Docs.Get(r.Context(), doc.ID); err != nil {
+				docTreeError(w, err, "Erreur lors de la récupération du document")
+				return
+			}
+		}
+		if !ifMatch(ifMatchHeader, docETag(current)) {
+			preconditionFailed(w, docETag(current), current)
+			return
+		}
+	}
+	if current != nil {
+		doc.RowVersion = current.RowVersion
+	}
+
+	if err := h.putDocSrc(&doc); err != nil {
+		http.Error(w, "Erreur lors de l'envoi du document sur IPFS", http.StatusInternalServerError)
+		return
+	}
+	err = h.Docs.Update(r.Context(), &doc, key.ID)
+	if errors.Is(err, store.ErrStale) {
+		// Écriture concurrente entre la lecture et la mise à jour
+		if ifMatchHeader == "" {
+			http.Error(w, "Le document a été modifié pendant la mise à jour, réessayez", http.StatusConflict)
+			return
+		}
+		if current, err := h.Docs.Get(r.Context(), doc.ID); err == nil {
+			preconditionFailed(w, docETag(current), current)
+			return
+		}
+	}
 	if err != nil {
-		http.Error(w, "Erreur lors de la mise à jour du document", http.StatusInternalServerError)
+		docTreeError(w, err, "Erreur lors de la mise à jour du document")
 		return
 	}
+	h.syncDocIndex(r.Context(), doc.ID)
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", docETag(&doc))
 	w.Write([]byte(`{"message": "Document mis à jour avec succès"}`))
 }
 
 // DeleteDocHandler gère la suppression d'un document
 func (h *Handler) DeleteDocHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodDelete {
-		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
-		return
-	}
-
 	apiKey := r.Header.Get("X-API-Key")
-	hasPermission, err := checkAPIKeyWritePermission(h.DB, apiKey)
+	_, hasPermission, err := checkAPIKeyWritePermission(r.Context(), h.Keys, apiKey)
 	if err != nil || !hasPermission {
 		http.Error(w, "API key non autorisée ou permissions insuffisantes", http.StatusUnauthorized)
 		return
 	}
 
-	docIDStr := r.URL.Query().Get("id")
+	docIDStr := pathOrQuery(r, "id")
 	docID, err := strconv.Atoi(docIDStr)
 	if err != nil {
 		http.Error(w, "ID de document invalide", http.StatusBadRequest)
 		return
 	}
 
-	_, err = h.DB.Exec("DELETE FROM docs WHERE id = ?", docID)
+	// Sans mode, un document qui a des enfants n'est pas supprimé
+	mode := store.DeleteMode(r.URL.Query().Get("mode"))
+	switch mode {
+	case "":
+		mode = store.DeleteRefuse
+	case store.DeleteRefuse, store.DeleteCascade, store.DeleteReparent:
+	default:
+		http.Error(w, "Mode de suppression invalide (refuse, cascade ou reparent)", http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.Docs.Delete(r.Context(), docID, mode)
 	if err != nil {
-		http.Error(w, "Erreur lors de la suppression du document", http.StatusInternalServerError)
+		docTreeError(w, err, "Erreur lors de la suppression du document")
 		return
 	}
+	h.syncDocIndex(r.Context(), docID)
 
 	w.Header().Set("Content-Type", "application/json")
-	w.Write([]byte(`{"message": "Document supprimé avec succès"}`))
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":    "Document supprimé avec succès",
+		"deleted":    res.Deleted,
+		"reparented": res.Reparented,
+	})
 }
 
-// GetAllDocsHandler gère la récupération de tous les documents
+// GetAllDocsHandler gère la récupération de tous les documents visibles
+// (tous avec une clé en écriture)
 func (h *Handler) GetAllDocsHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
-		return
-	}
-
-	rows, err := h.DB.Query("SELECT id, title, path, doc_src, version, is_children, parent_id, created_at, updated_at FROM docs")
+	docs, err := h.readableDocs(r)
 	if err != nil {
 		http.Error(w, "Erreur lors de la récupération des documents", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var docs []Doc
-	for rows.Next() {
-		var doc Doc
-		err := rows.Scan(&doc.ID, &doc.Title, &doc.Path, &doc.DocSrc, &doc.Version, &doc.IsChildren, &doc.ParentID, &doc.CreatedAt, &doc.UpdatedAt)
-		if err != nil {
-			http.Error(w, "Erreur lors de la lecture des données du document", http.StatusInternalServerError)
-			return
-		}
-		docs = append(docs, doc)
-	}
-
-	if err = rows.Err(); err != nil {
-		http.Error(w, "Erreur lors de l'itération des documents", http.StatusInternalServerError)
-		return
-	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(docs)
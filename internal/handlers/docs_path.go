@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/store"
+)
+
+// DocLink désigne un document dans la navigation
+type DocLink struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+	Path  string `json:"path"`
+}
+
+func docLink(d *store.Doc) *DocLink {
+	return &DocLink{ID: d.ID, Title: d.Title, Path: d.Path}
+}
+
+// GetDocByPathHandler renvoie le document de chemin path avec ses ancêtres,
+// du premier niveau au parent, et ses frères précédent et suivant. Un même
+// chemin pouvant exister sous plusieurs parents, parent_id (0 : premier
+// niveau) lève l'ambiguïté.
+func (h *Handler) GetDocByPathHandler(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimSpace(r.URL.Query().Get("path"))
+	if len(path) > 1 {
+		path = strings.TrimSuffix(path, "/")
+	}
+	if path == "" {
+		http.Error(w, "Chemin manquant", http.StatusBadRequest)
+		return
+	}
+	format, ok := docFormat(w, r)
+	if !ok {
+		return
+	}
+
+	// Sans clé en écriture, les documents non publiés sont ignorés ;
+	// l'arborescence se construit sans le contenu des documents
+	docs, err := h.readableOutline(r)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération des documents", http.StatusInternalServerError)
+		return
+	}
+	found, err := h.Docs.FindByPath(r.Context(), path)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération du document", http.StatusInternalServerError)
+		return
+	}
+	tree := newDocTree(docs)
+	var matches []store.Doc
+	for _, d := range found {
+		if tree.docs[d.ID] != nil {
+			matches = append(matches, d)
+		}
+	}
+	if v := r.URL.Query().Get("parent_id"); v != "" {
+		parentID, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "ParentID invalide", http.StatusBadRequest)
+			return
+		}
+		var filtered []store.Doc
+		for _, d := range matches {
+			if sameParentID(d.ParentID, normalizeParentID(&parentID)) {
+				filtered = append(filtered, d)
+			}
+		}
+		matches = filtered
+	}
+	switch {
+	case len(matches) == 0:
+		http.Error(w, "Aucun document pour ce chemin", http.StatusNotFound)
+		return
+	case len(matches) > 1:
+		http.Error(w, "Plusieurs documents ont ce chemin : précisez parent_id", http.StatusConflict)
+		return
+	}
+
+	doc := &matches[0]
+
+	// Ancêtres, en remontant ; seen arrête un cycle hérité de données anciennes
+	breadcrumbs := []*DocLink{}
+	seen := map[int]bool{doc.ID: true}
+	parent := 0
+	for p := doc.ParentID; p != nil && tree.docs[*p] != nil && !seen[*p]; p = tree.docs[*p].ParentID {
+		if parent == 0 {
+			parent = *p
+		}
+		seen[*p] = true
+		breadcrumbs = append([]*DocLink{docLink(tree.docs[*p])}, breadcrumbs...)
+	}
+
+	var prev, next *DocLink
+	siblings := tree.children[parent]
+	for i, s := range siblings {
+		if s.ID != doc.ID {
+			continue
+		}
+		if i > 0 {
+			prev = docLink(siblings[i-1])
+		}
+		if i+1 < len(siblings) {
+			next = docLink(siblings[i+1])
+		}
+	}
+
+	var body interface{} = doc
+	if format == "html" {
+		rendered, err := renderDoc(doc, docs)
+		if err != nil {
+			log.Println("Erreur lors du rendu du document", doc.ID, ":", err)
+			http.Error(w, "Erreur lors du rendu du document", http.StatusInternalServerError)
+			return
+		}
+		body = rendered
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"doc":         body,
+		"breadcrumbs": breadcrumbs,
+		"prev":        prev,
+		"next":        next,
+	})
+}
+
+// sameParentID compare deux parents (nil : premier niveau)
+func sameParentID(a, b *int) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/service"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/store"
+)
+
+// publishedDoc décrit un document du répertoire publié
+type publishedDoc struct {
+	ID       int     `json:"id"`
+	Title    string  `json:"title"`
+	Path     string  `json:"path"`
+	Version  float64 `json:"version"`
+	CID      string  `json:"cid"`
+	File     string  `json:"file"`
+	ParentID *int    `json:"parent_id"`
+	Position int     `json:"position"`
+}
+
+// docPublication est la réponse de PublishDocsHandler et, sans CID, le
+// index.json du répertoire publié
+type docPublication struct {
+	CID  string         `json:"cid,omitempty"`
+	Root *int           `json:"root"`
+	Docs []publishedDoc `json:"docs"`
+}
+
+// publishedName dérive du chemin d'un document un nom de fichier relatif,
+// sans remontée possible hors du répertoire
+func publishedName(d *store.Doc, used map[string]bool) string {
+	name := strings.TrimPrefix(path.Clean("/"+strings.TrimSpace(d.Path)), "/")
+	name = strings.TrimSuffix(name, ".md")
+	if name == "" {
+		name = "index"
+	}
+	if used[name+".md"] {
+		name += "-" + strconv.Itoa(d.ID)
+	}
+	used[name+".md"] = true
+	return name + ".md"
+}
+
+// PublishDocsHandler publie l'arborescence des documents, ou le sous-arbre
+// du document root, dans un répertoire IPFS : un fichier Markdown par
+// document et un index.json qui décrit l'arborescence
+func (h *Handler) PublishDocsHandler(w http.ResponseWriter, r *http.Request) {
+	_, hasPermission, err := checkAPIKeyWritePermission(r.Context(), h.Keys, r.Header.Get("X-API-Key"))
+	if err != nil || !hasPermission {
+		http.Error(w, "API key non autorisée ou permissions insuffisantes", http.StatusUnauthorized)
+		return
+	}
+
+	docs, err := h.Docs.List(r.Context())
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération des documents", http.StatusInternalServerError)
+		return
+	}
+	// Le répertoire publié est public : seuls les documents visibles y entrent
+	tree := newDocTree(store.LiveDocs(docs, time.Now()))
+	roots, ok := tree.roots(w, r)
+	if !ok {
+		return
+	}
+
+	pub := docPublication{Docs: []publishedDoc{}}
+	if r.URL.Query().Get("root") != "" && len(roots) == 1 {
+		pub.Root = &roots[0].ID
+	}
+	files := map[string][]byte{}
+	used := map[string]bool{"index.json": true}
+	visited := map[int]bool{}
+	// Parcours préfixe : l'index suit l'ordre de l'arborescence
+	var walk func(d *store.Doc)
+	walk = func(d *store.Doc) {
+		if visited[d.ID] {
+			return
+		}
+		visited[d.ID] = true
+		name := publishedName(d, used)
+		files[name] = []byte(d.DocSrc)
+		pub.Docs = append(pub.Docs, publishedDoc{
+			ID: d.ID, Title: d.Title, Path: d.Path, Version: d.Version, CID: d.CID,
+			File: name, ParentID: d.ParentID, Position: d.Position,
+		})
+		for _, child := range tree.children[d.ID] {
+			walk(child)
+		}
+	}
+	for _, d := range roots {
+		walk(d)
+	}
+
+	index, err := json.MarshalIndent(pub, "", "  ")
+	if err != nil {
+		http.Error(w, "Erreur lors de la publication des documents", http.StatusInternalServerError)
+		return
+	}
+	files["index.json"] = index
+
+	pub.CID, err = service.AddFiles(h.IPFS, files)
+	if err != nil {
+		log.Println("Erreur lors de la publication des documents sur IPFS:", err)
+		http.Error(w, "Erreur lors de la publication des documents sur IPFS", http.StatusInternalServerError)
+		return
+	}
+	log.Println("Documents publiés sur IPFS, CID:", pub.CID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pub)
+}
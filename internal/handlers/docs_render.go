@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/markdown"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/store"
+)
+
+// RenderedDoc est un document accompagné de son rendu HTML (format=html)
+type RenderedDoc struct {
+	*store.Doc
+	HTML string             `json:"html"`
+	TOC  []markdown.Heading `json:"toc"`
+}
+
+// docFormat lit le paramètre format ("markdown" par défaut) ; sinon elle
+// répond à la requête et renvoie false
+func docFormat(w http.ResponseWriter, r *http.Request) (string, bool) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "markdown":
+		return "markdown", true
+	case "html":
+		return format, true
+	default:
+		http.Error(w, "Format invalide : markdown ou html", http.StatusBadRequest)
+		return "", false
+	}
+}
+
+// renderDoc rend le Markdown de doc. Les liens relatifs vers d'autres
+// documents, parmi docs, deviennent leur chemin absolu, les images ipfs:// ou
+// CID l'URL d'affichage du fichier.
+func renderDoc(doc *store.Doc, docs []store.Doc) (*RenderedDoc, error) {
+	paths := make(map[string]bool, len(docs))
+	for _, d := range docs {
+		paths["/"+strings.TrimPrefix(d.Path, "/")] = true
+	}
+
+	out, err := markdown.Render([]byte(doc.DocSrc), markdown.Options{
+		Link:    markdown.DocLinks(doc.Path, func(p string) (string, bool) { return p, paths[p] }),
+		FileURL: func(cid string) string { return "/v1/files/" + url.PathEscape(cid) + "/display" },
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &RenderedDoc{Doc: doc, HTML: out.HTML, TOC: out.TOC}, nil
+}
@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/store"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/textdiff"
+)
+
+// diffContext est le nombre de lignes inchangées autour d'une modification
+const diffContext = 3
+
+// docAndVersion lit l'ID du document et, si name n'est pas vide, un numéro
+// de révision dans le chemin ; sinon elle répond à la requête et renvoie false
+func docAndVersion(w http.ResponseWriter, r *http.Request, name string) (docID, version int, ok bool) {
+	docID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "ID de document invalide", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	if name != "" {
+		if version, err = strconv.Atoi(r.PathValue(name)); err != nil || version < 1 {
+			http.Error(w, "Numéro de révision invalide", http.StatusBadRequest)
+			return 0, 0, false
+		}
+	}
+	return docID, version, true
+}
+
+// revisionError répond à une erreur de lecture d'un document ou d'une
+// révision ; notFound est le message de l'absence
+func revisionError(w http.ResponseWriter, err error, notFound string) {
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, notFound, http.StatusNotFound)
+		return
+	}
+	http.Error(w, "Erreur lors de la récupération de l'historique", http.StatusInternalServerError)
+}
+
+// revisionReadable indique si la requête peut lire rev : toujours avec une
+// clé en écriture, sinon seulement une révision enregistrée alors que le
+// document était publié, dont la clé auteur est alors effacée
+func revisionReadable(rev *store.DocRevision, preview bool) bool {
+	if preview {
+		return true
+	}
+	rev.APIKeyID = 0
+	return rev.Status == store.DocPublished
+}
+
+// GetDocRevisionsHandler renvoie une page de l'historique d'un document, la
+// révision la plus récente d'abord
+func (h *Handler) GetDocRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	docID, _, ok := docAndVersion(w, r, "")
+	if !ok {
+		return
+	}
+	if !h.readableDoc(w, r, docID) {
+		return
+	}
+	page, limit := pagination(r)
+
+	preview := h.canPreview(r)
+	revisions, total, err := h.Docs.ListRevisions(r.Context(), docID, !preview, limit, (page-1)*limit)
+	if err != nil {
+		revisionError(w, err, "Document non trouvé")
+		return
+	}
+	for i := range revisions {
+		revisionReadable(&revisions[i], preview)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"revisions":  revisions,
+		"total":      total,
+		"page":       page,
+		"limit":      limit,
+		"totalPages": (total + limit - 1) / limit,
+	})
+}
+
+// GetDocRevisionHandler renvoie une révision complète d'un document
+func (h *Handler) GetDocRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	docID, version, ok := docAndVersion(w, r, "version")
+	if !ok || !h.readableDoc(w, r, docID) {
+		return
+	}
+	rev, err := h.Docs.GetRevision(r.Context(), docID, version)
+	if err == nil && !revisionReadable(rev, h.canPreview(r)) {
+		err = store.ErrNotFound
+	}
+	if err != nil {
+		revisionError(w, err, "Révision non trouvée")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rev)
+}
+
+// GetDocDiffHandler compare ligne à ligne le contenu de deux révisions ;
+// to vaut par défaut la version actuelle du document
+func (h *Handler) GetDocDiffHandler(w http.ResponseWriter, r *http.Request) {
+	docID, _, ok := docAndVersion(w, r, "")
+	if !ok {
+		return
+	}
+	from, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil || from < 1 {
+		http.Error(w, "Paramètre from invalide", http.StatusBadRequest)
+		return
+	}
+	doc, err := h.Docs.Get(r.Context(), docID)
+	if err != nil {
+		revisionError(w, err, "Document non trouvé")
+		return
+	}
+	if !h.readableDoc(w, r, docID) {
+		return
+	}
+	to := int(doc.Version)
+	if v := r.URL.Query().Get("to"); v != "" {
+		if to, err = strconv.Atoi(v); err != nil || to < 1 {
+			http.Error(w, "Paramètre to invalide", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var revs [2]*store.DocRevision
+	preview := h.canPreview(r)
+	for i, version := range []int{from, to} {
+		revs[i], err = h.Docs.GetRevision(r.Context(), docID, version)
+		if err == nil && !revisionReadable(revs[i], preview) {
+			err = store.ErrNotFound
+		}
+		if err != nil {
+			revisionError(w, err, "Révision non trouvée")
+			return
+		}
+	}
+
+	ops := textdiff.Lines(revs[0].DocSrc, revs[1].DocSrc)
+	added, removed := textdiff.Stats(ops)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"from":       from,
+		"to":         to,
+		"from_title": revs[0].Title,
+		"to_title":   revs[1].Title,
+		"added":      added,
+		"removed":    removed,
+		"diff":       textdiff.Unified(ops, fmt.Sprintf("v%d", from), fmt.Sprintf("v%d", to), diffContext),
+	})
+}
+
+// RestoreDocRevisionHandler rétablit le contenu d'une ancienne révision dans
+// une nouvelle révision
+func (h *Handler) RestoreDocRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	key, hasPermission, err := checkAPIKeyWritePermission(r.Context(), h.Keys, r.Header.Get("X-API-Key"))
+	if err != nil || !hasPermission {
+		http.Error(w, "API key non autorisée ou permissions insuffisantes", http.StatusUnauthorized)
+		return
+	}
+	docID, version, ok := docAndVersion(w, r, "version")
+	if !ok {
+		return
+	}
+
+	rev, err := h.Docs.GetRevision(r.Context(), docID, version)
+	if err != nil {
+		revisionError(w, err, "Révision non trouvée")
+		return
+	}
+	// Renvoyer le contenu sur IPFS le ré-épingle, et donne un CID aux
+	// révisions antérieures au stockage IPFS
+	restored := Doc{DocSrc: rev.DocSrc}
+	if err := h.putDocSrc(&restored); err != nil {
+		http.Error(w, "Erreur lors de l'envoi du document sur IPFS", http.StatusInternalServerError)
+		return
+	}
+
+	doc, err := h.Docs.RestoreRevision(r.Context(), docID, version, key.ID, restored.CID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Document ou révision non trouvés", http.StatusNotFound)
+		return
+	} else if err != nil {
+		docTreeError(w, err, "Erreur lors de la restauration de la révision")
+		return
+	}
+	h.syncDocIndex(r.Context(), doc.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", docETag(doc))
+	json.NewEncoder(w).Encode(doc)
+}
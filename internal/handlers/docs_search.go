@@ -0,0 +1,233 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/markdown"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/store"
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/highlight/highlighter/html"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// titleBoost donne au titre plus de poids qu'au corps dans le score
+const titleBoost = 3
+
+// DocEntry est l'entrée d'un document dans l'index des documents
+type DocEntry struct {
+	Title string `json:"title"`
+	Path  string `json:"path"`
+	// Body est le contenu débarrassé du Markdown
+	Body string `json:"body"`
+	// Subtree liste l'id du document et ceux de ses ancêtres, pour filtrer
+	// la recherche sur un sous-arbre
+	Subtree []string `json:"subtree"`
+}
+
+// entry construit l'entrée de d ; seen arrête un cycle hérité de données
+// anciennes
+func (t *docTree) entry(d *store.Doc) *DocEntry {
+	e := &DocEntry{Title: d.Title, Path: d.Path, Body: markdown.PlainText([]byte(d.DocSrc)), Subtree: []string{strconv.Itoa(d.ID)}}
+	seen := map[int]bool{d.ID: true}
+	for p := d.ParentID; p != nil && t.docs[*p] != nil && !seen[*p]; p = t.docs[*p].ParentID {
+		seen[*p] = true
+		e.Subtree = append(e.Subtree, strconv.Itoa(*p))
+	}
+	return e
+}
+
+// descendants renvoie les ids des descendants de id
+func (t *docTree) descendants(id int) []int {
+	var ids []int
+	seen := map[int]bool{id: true}
+	stack := []int{id}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, child := range t.children[current] {
+			if !seen[child.ID] {
+				seen[child.ID] = true
+				ids = append(ids, child.ID)
+				stack = append(stack, child.ID)
+			}
+		}
+	}
+	return ids
+}
+
+// IndexDocs reconstruit l'index des documents depuis la table docs : chaque
+// document est (ré)indexé et les entrées sans document sont supprimées
+func IndexDocs(ctx context.Context, docs store.DocRepository, index bleve.Index) error {
+	all, err := docs.List(ctx)
+	if err != nil {
+		return err
+	}
+	tree := newDocTree(all)
+
+	stale, err := indexedDocIDs(index, query.NewMatchAllQuery())
+	if err != nil {
+		return err
+	}
+	batch := index.NewBatch()
+	for _, id := range stale {
+		if tree.docs[id] == nil {
+			batch.Delete(strconv.Itoa(id))
+		}
+	}
+	for i := range all {
+		if err := batch.Index(strconv.Itoa(all[i].ID), tree.entry(&all[i])); err != nil {
+			return err
+		}
+	}
+	return index.Batch(batch)
+}
+
+// indexedDocIDs renvoie les ids des documents indexés satisfaisant q
+func indexedDocIDs(index bleve.Index, q query.Query) ([]int, error) {
+	count, err := index.DocCount()
+	if err != nil {
+		return nil, err
+	}
+	res, err := index.Search(bleve.NewSearchRequestOptions(q, int(count), 0, false))
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(res.Hits))
+	for _, hit := range res.Hits {
+		if id, err := strconv.Atoi(hit.ID); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// syncDocIndex met à jour l'index après une modification du document id :
+// lui et ses descendants, actuels ou d'avant un déplacement ou une
+// suppression, sont réindexés ou retirés. Une erreur est seulement
+// journalisée, IndexDocs permettant de reconstruire l'index.
+func (h *Handler) syncDocIndex(ctx context.Context, id int) {
+	if h.DocsIndex == nil {
+		return
+	}
+	err := func() error {
+		subtree := query.NewTermQuery(strconv.Itoa(id))
+		subtree.SetField("subtree")
+		ids, err := indexedDocIDs(h.DocsIndex, subtree)
+		if err != nil {
+			return err
+		}
+		docs, err := h.Docs.List(ctx)
+		if err != nil {
+			return err
+		}
+		tree := newDocTree(docs)
+		ids = append(append(ids, id), tree.descendants(id)...)
+
+		batch := h.DocsIndex.NewBatch()
+		done := map[int]bool{}
+		for _, docID := range ids {
+			if done[docID] {
+				continue
+			}
+			done[docID] = true
+			if d := tree.docs[docID]; d != nil {
+				if err := batch.Index(strconv.Itoa(docID), tree.entry(d)); err != nil {
+					return err
+				}
+			} else {
+				batch.Delete(strconv.Itoa(docID))
+			}
+		}
+		return h.DocsIndex.Batch(batch)
+	}()
+	if err != nil {
+		log.Println("Erreur lors de l'indexation du document", id, ":", err)
+	}
+}
+
+// DocSearchHit est un document trouvé, avec les extraits surlignés (<mark>)
+// par champ
+type DocSearchHit struct {
+	ID        int                 `json:"id"`
+	Title     string              `json:"title"`
+	Path      string              `json:"path"`
+	Score     float64             `json:"score"`
+	Fragments map[string][]string `json:"fragments"`
+}
+
+// SearchDocsHandler recherche q dans le titre et le contenu des documents
+// visibles (tous avec une clé en écriture), éventuellement dans le seul
+// sous-arbre de root
+func (h *Handler) SearchDocsHandler(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		http.Error(w, "Requête de recherche manquante", http.StatusBadRequest)
+		return
+	}
+	page, limit := pagination(r)
+
+	title := query.NewMatchQuery(q)
+	title.SetField("title")
+	title.SetBoost(titleBoost)
+	body := query.NewMatchQuery(q)
+	body.SetField("body")
+	var search query.Query = query.NewDisjunctionQuery([]query.Query{title, body})
+	if v := r.URL.Query().Get("root"); v != "" {
+		if _, err := strconv.Atoi(v); err != nil {
+			http.Error(w, "ID de document invalide", http.StatusBadRequest)
+			return
+		}
+		subtree := query.NewTermQuery(v)
+		subtree.SetField("subtree")
+		search = query.NewConjunctionQuery([]query.Query{search, subtree})
+	}
+	// Sans clé en écriture, seuls les documents visibles sont cherchés
+	readable, err := h.readableIDs(r)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération des documents", http.StatusInternalServerError)
+		return
+	}
+	if readable != nil {
+		ids := []string{}
+		for id := range readable {
+			ids = append(ids, strconv.Itoa(id))
+		}
+		search = query.NewConjunctionQuery([]query.Query{search, query.NewDocIDQuery(ids)})
+	}
+
+	req := bleve.NewSearchRequestOptions(search, limit, (page-1)*limit, false)
+	req.Fields = []string{"title", "path"}
+	req.Highlight = bleve.NewHighlightWithStyle(html.Name)
+	req.Highlight.AddField("title")
+	req.Highlight.AddField("body")
+	res, err := h.DocsIndex.Search(req)
+	if err != nil {
+		log.Println("Erreur lors de la recherche de documents :", err)
+		http.Error(w, "Erreur lors de la recherche", http.StatusInternalServerError)
+		return
+	}
+
+	hits := []DocSearchHit{}
+	for _, hit := range res.Hits {
+		id, _ := strconv.Atoi(hit.ID)
+		title, _ := hit.Fields["title"].(string)
+		path, _ := hit.Fields["path"].(string)
+		fragments := hit.Fragments
+		if fragments == nil {
+			fragments = map[string][]string{}
+		}
+		hits = append(hits, DocSearchHit{ID: id, Title: title, Path: path, Score: hit.Score, Fragments: fragments})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"results":    hits,
+		"total":      res.Total,
+		"totalPages": (int64(res.Total) + int64(limit) - 1) / int64(limit),
+	})
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/store"
+)
+
+// checkDocStatus vérifie le statut et la fenêtre de publication d'un document
+// reçu ; sinon elle répond à la requête et renvoie false
+func checkDocStatus(w http.ResponseWriter, doc *Doc) bool {
+	if doc.Status != "" && !doc.Status.Valid() {
+		http.Error(w, "Statut invalide (draft, review, published ou archived)", http.StatusBadRequest)
+		return false
+	}
+	if doc.PublishAt != nil && doc.UnpublishAt != nil && !doc.UnpublishAt.After(*doc.PublishAt) {
+		http.Error(w, "unpublish_at doit suivre publish_at", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// canPreview indique si la requête porte une clé en écriture, qui voit aussi
+// les documents non publiés
+func (h *Handler) canPreview(r *http.Request) bool {
+	apiKey := r.Header.Get("X-API-Key")
+	if apiKey == "" {
+		return false
+	}
+	_, hasPermission, err := checkAPIKeyWritePermission(r.Context(), h.Keys, apiKey)
+	return err == nil && hasPermission
+}
+
+// readableDocs renvoie les documents que la requête peut lire : tous avec
+// une clé en écriture, les seuls documents visibles sinon
+func (h *Handler) readableDocs(r *http.Request) ([]store.Doc, error) {
+	docs, err := h.Docs.List(r.Context())
+	if err != nil || h.canPreview(r) {
+		return docs, err
+	}
+	return store.LiveDocs(docs, time.Now()), nil
+}
+
+// readableOutline renvoie, comme readableDocs, les documents que la requête
+// peut lire, mais sans leur contenu
+func (h *Handler) readableOutline(r *http.Request) ([]store.Doc, error) {
+	docs, err := h.Docs.ListOutline(r.Context())
+	if err != nil || h.canPreview(r) {
+		return docs, err
+	}
+	return store.LiveDocs(docs, time.Now()), nil
+}
+
+// readableIDs renvoie les identifiants des documents visibles, calculés
+// sans lire leur contenu ; nil avec une clé en écriture, qui les lit tous
+func (h *Handler) readableIDs(r *http.Request) (map[int]bool, error) {
+	if h.canPreview(r) {
+		return nil, nil
+	}
+	docs, err := h.Docs.ListOutline(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	ids := map[int]bool{}
+	for _, d := range store.LiveDocs(docs, time.Now()) {
+		ids[d.ID] = true
+	}
+	return ids, nil
+}
+
+// canRead indique si le document id fait partie de ids, le résultat de
+// readableIDs
+func canRead(ids map[int]bool, id int) bool {
+	return ids == nil || ids[id]
+}
+
+// readableDoc vérifie que la requête peut lire le document id ; sinon elle
+// répond 404, comme pour un document inconnu, et renvoie false
+func (h *Handler) readableDoc(w http.ResponseWriter, r *http.Request, id int) bool {
+	ids, err := h.readableIDs(r)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération du document", http.StatusInternalServerError)
+		return false
+	}
+	if !canRead(ids, id) {
+		http.Error(w, "Document non trouvé", http.StatusNotFound)
+		return false
+	}
+	return true
+}
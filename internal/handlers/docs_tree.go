@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/store"
+)
+
+// DocNode est un document de l'arborescence, sans son contenu
+type DocNode struct {
+	ID        int             `json:"id"`
+	Title     string          `json:"title"`
+	Path      string          `json:"path"`
+	Version   float64         `json:"version"`
+	ParentID  *int            `json:"parent_id"`
+	Position  int             `json:"position"`
+	Status    store.DocStatus `json:"status"`
+	UpdatedAt string          `json:"updated_at"`
+	// HasChildren reste vrai quand la profondeur demandée coupe les enfants
+	HasChildren bool       `json:"has_children"`
+	Children    []*DocNode `json:"children,omitempty"`
+}
+
+// docTree indexe les documents par parent, frères triés par position puis id
+type docTree struct {
+	docs     map[int]*store.Doc
+	children map[int][]*store.Doc // clé 0 : documents de premier niveau
+}
+
+func newDocTree(docs []store.Doc) *docTree {
+	t := &docTree{docs: make(map[int]*store.Doc, len(docs)), children: map[int][]*store.Doc{}}
+	for i := range docs {
+		t.docs[docs[i].ID] = &docs[i]
+	}
+	for i := range docs {
+		d := &docs[i]
+		parent := 0
+		// Un parent disparu rattache le document au premier niveau
+		if d.ParentID != nil && t.docs[*d.ParentID] != nil {
+			parent = *d.ParentID
+		}
+		t.children[parent] = append(t.children[parent], d)
+	}
+	for _, siblings := range t.children {
+		sort.Slice(siblings, func(i, j int) bool {
+			if siblings[i].Position != siblings[j].Position {
+				return siblings[i].Position < siblings[j].Position
+			}
+			return siblings[i].ID < siblings[j].ID
+		})
+	}
+	return t
+}
+
+// node construit le sous-arbre de d sur depth niveaux (0 : sans limite).
+// visited protège des cycles que des données anciennes pourraient contenir.
+func (t *docTree) node(d *store.Doc, depth int, visited map[int]bool) *DocNode {
+	visited[d.ID] = true
+	n := &DocNode{
+		ID:          d.ID,
+		Title:       d.Title,
+		Path:        d.Path,
+		Version:     d.Version,
+		ParentID:    d.ParentID,
+		Position:    d.Position,
+		Status:      d.Status,
+		UpdatedAt:   d.UpdatedAt,
+		HasChildren: len(t.children[d.ID]) > 0,
+	}
+	if depth == 1 {
+		return n
+	}
+	for _, child := range t.children[d.ID] {
+		if !visited[child.ID] {
+			n.Children = append(n.Children, t.node(child, depth-1, visited))
+		}
+	}
+	return n
+}
+
+// roots renvoie les documents de premier niveau ou, avec le paramètre
+// root, le document racine du sous-arbre demandé ; sinon elle répond à la
+// requête et renvoie false
+func (t *docTree) roots(w http.ResponseWriter, r *http.Request) ([]*store.Doc, bool) {
+	v := r.URL.Query().Get("root")
+	if v == "" {
+		return t.children[0], true
+	}
+	rootID, err := strconv.Atoi(v)
+	if err != nil {
+		http.Error(w, "ID de document invalide", http.StatusBadRequest)
+		return nil, false
+	}
+	root := t.docs[rootID]
+	if root == nil {
+		http.Error(w, "Document non trouvé", http.StatusNotFound)
+		return nil, false
+	}
+	return []*store.Doc{root}, true
+}
+
+// GetDocsTreeHandler renvoie l'arborescence des documents visibles, ou le
+// sous-arbre du document root, sur depth niveaux
+func (h *Handler) GetDocsTreeHandler(w http.ResponseWriter, r *http.Request) {
+	depth := 0
+	if v := r.URL.Query().Get("depth"); v != "" {
+		var err error
+		if depth, err = strconv.Atoi(v); err != nil || depth < 0 {
+			http.Error(w, "Profondeur invalide", http.StatusBadRequest)
+			return
+		}
+	}
+
+	docs, err := h.readableOutline(r)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération des documents", http.StatusInternalServerError)
+		return
+	}
+	tree := newDocTree(docs)
+	roots, ok := tree.roots(w, r)
+	if !ok {
+		return
+	}
+
+	nodes := []*DocNode{}
+	visited := map[int]bool{}
+	for _, d := range roots {
+		nodes = append(nodes, tree.node(d, depth, visited))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"nodes": nodes})
+}
+
+// docTreeError traduit les erreurs de l'arborescence en réponse HTTP ;
+// message accompagne les erreurs internes
+func docTreeError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, "Document non trouvé", http.StatusNotFound)
+	case errors.Is(err, store.ErrInvalidParent):
+		http.Error(w, "ParentID invalide", http.StatusBadRequest)
+	case errors.Is(err, store.ErrCycle):
+		http.Error(w, "Un document ne peut pas devenir son propre ancêtre", http.StatusConflict)
+	case errors.Is(err, store.ErrInvalidOrder):
+		http.Error(w, "L'ordre doit lister chaque enfant du parent une seule fois", http.StatusBadRequest)
+	case errors.Is(err, store.ErrDuplicatePath):
+		http.Error(w, "Un document de même chemin existe déjà sous ce parent", http.StatusConflict)
+	case errors.Is(err, store.ErrStale):
+		http.Error(w, "Le document a été modifié pendant la mise à jour, réessayez", http.StatusConflict)
+	case errors.Is(err, store.ErrHasChildren):
+		http.Error(w, "Le document a des enfants : utilisez mode=cascade ou mode=reparent", http.StatusConflict)
+	default:
+		log.Println(message+":", err)
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+}
+
+// normalizeParentID ramène le parent 0 au premier niveau
+func normalizeParentID(parentID *int) *int {
+	if parentID != nil && *parentID == 0 {
+		return nil
+	}
+	return parentID
+}
+
+// MoveDocHandler rattache un document à un autre parent (null : premier
+// niveau) au rang position parmi ses frères (1 : en tête, 0 : à la fin)
+func (h *Handler) MoveDocHandler(w http.ResponseWriter, r *http.Request) {
+	_, hasPermission, err := checkAPIKeyWritePermission(r.Context(), h.Keys, r.Header.Get("X-API-Key"))
+	if err != nil || !hasPermission {
+		http.Error(w, "API key non autorisée ou permissions insuffisantes", http.StatusUnauthorized)
+		return
+	}
+
+	docID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "ID de document invalide", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		ParentID *int `json:"parent_id"`
+		Position int  `json:"position"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Erreur lors de la décodage de la requête", http.StatusBadRequest)
+		return
+	}
+	if req.Position < 0 {
+		http.Error(w, "Position invalide", http.StatusBadRequest)
+		return
+	}
+
+	doc, err := h.Docs.Move(r.Context(), docID, normalizeParentID(req.ParentID), req.Position)
+	if err != nil {
+		docTreeError(w, err, "Erreur lors du déplacement du document")
+		return
+	}
+	h.syncDocIndex(r.Context(), doc.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", docETag(doc))
+	json.NewEncoder(w).Encode(doc)
+}
+
+// ReorderDocsHandler fixe l'ordre de tous les enfants d'un parent
+func (h *Handler) ReorderDocsHandler(w http.ResponseWriter, r *http.Request) {
+	_, hasPermission, err := checkAPIKeyWritePermission(r.Context(), h.Keys, r.Header.Get("X-API-Key"))
+	if err != nil || !hasPermission {
+		http.Error(w, "API key non autorisée ou permissions insuffisantes", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		ParentID *int  `json:"parent_id"`
+		IDs      []int `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Erreur lors de la décodage de la requête", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Docs.Reorder(r.Context(), normalizeParentID(req.ParentID), req.IDs); err != nil {
+		docTreeError(w, err, "Erreur lors du réordonnancement des documents")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"message": "Documents réordonnés avec succès"}`))
+}
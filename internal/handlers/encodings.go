@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/compress"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/service"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/store"
+)
+
+// encodingKey est la clé de variante d'un encodage compressé
+func encodingKey(encoding string) string {
+	return "encoding=" + encoding
+}
+
+// serveEncoded envoie le contenu de f, compressé selon l'Accept-Encoding du
+// client. Les encodages sont calculés une fois par CID puis servis depuis le
+// cache ; load ne lit l'original qu'en l'absence de variante.
+func (h *Handler) serveEncoded(w http.ResponseWriter, r *http.Request, f *store.File, load func() ([]byte, error)) {
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	var body []byte
+	encoding := ""
+	if enc := compress.Negotiate(r.Header.Get("Accept-Encoding")); enc != "" {
+		encoded, err := h.encodedVariant(r.Context(), f, load, enc)
+		if err != nil {
+			// Le contenu reste servable sans compression
+			log.Println("Erreur lors de l'encodage", enc, "de", f.CID, ":", err)
+		} else {
+			body, encoding = encoded, enc
+		}
+	}
+	if encoding == "" {
+		content, err := load()
+		if err != nil {
+			http.Error(w, "Erreur lors de la récupération du fichier depuis IPFS", http.StatusInternalServerError)
+			return
+		}
+		body = content
+	}
+
+	w.Header().Set("Content-Type", f.MimeType)
+	if encoding != "" {
+		w.Header().Set("Content-Encoding", encoding)
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	if _, err := w.Write(body); err != nil {
+		log.Println("Erreur lors de l'envoi du fichier:", err)
+	}
+}
+
+// encodedVariant renvoie le contenu de f compressé avec encoding, depuis le
+// cache des variantes ou en l'y ajoutant ; load n'est appelée qu'en
+// l'absence de variante
+func (h *Handler) encodedVariant(ctx context.Context, f *store.File, load func() ([]byte, error), encoding string) ([]byte, error) {
+	key := encodingKey(encoding)
+	v, err := h.Variants.Find(ctx, f.CID, key)
+	if err == nil {
+		encoded, err := h.IPFS.DownloadFileFromIPFS(v.CID)
+		if err == nil {
+			return encoded, nil
+		}
+		log.Println("Variante", v.CID, "introuvable sur IPFS, régénération :", err)
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+
+	content, err := load()
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := compress.Encode(content, encoding)
+	if err != nil {
+		return nil, err
+	}
+	cid, err := service.AddBytes(h.IPFS, encoded)
+	if err != nil {
+		return nil, err
+	}
+	v = &store.Variant{
+		SourceCID:  f.CID,
+		VariantKey: key,
+		CID:        cid,
+		MimeType:   f.MimeType,
+		FileSize:   int64(len(encoded)),
+	}
+	if err := h.Variants.Create(ctx, v); err != nil {
+		log.Println("Erreur lors de l'enregistrement de la variante", key, "de", f.CID, ":", err)
+	}
+	return encoded, nil
+}
+
+// precompress prépare dès l'upload les encodages servis par serveEncoded ;
+// un échec n'empêche pas l'upload
+func (h *Handler) precompress(ctx context.Context, f *store.File, content []byte) {
+	load := func() ([]byte, error) { return content, nil }
+	for _, enc := range compress.Supported {
+		if _, err := h.encodedVariant(ctx, f, load, enc); err != nil {
+			log.Println("Erreur lors de l'encodage", enc, "de", f.CID, ":", err)
+			return
+		}
+	}
+}
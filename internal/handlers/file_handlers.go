@@ -1,19 +1,22 @@
 package handler
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/imaging"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/lottie"
 	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/service"
-	"github.com/TomPo62/bakiverse-ipfs-service-go/pkg/security"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/store"
 
 	bleve "github.com/blevesearch/bleve/v2"
 )
@@ -23,34 +26,75 @@ type FileDoc struct {
 	FileName  string `json:"file_name"`
 	MimeType  string `json:"mime_type"`
 	IsPrivate bool   `json:"is_private"`
+	// Champs des animations Lottie, pour les requêtes du type "+kind:lottie +duration:<3"
+	Kind       string  `json:"kind,omitempty"`
+	FrameRate  float64 `json:"frame_rate,omitempty"`
+	Duration   float64 `json:"duration,omitempty"`
+	Width      int     `json:"width,omitempty"`
+	Height     int     `json:"height,omitempty"`
+	LayerCount int     `json:"layer_count,omitempty"`
+	AssetCount int     `json:"asset_count,omitempty"`
+	// Nombre d'animations d'une archive dotLottie
+	AnimationCount int `json:"animation_count,omitempty"`
+	// Informations saisies par le propriétaire
+	DisplayName string   `json:"display_name,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// NewFileDoc construit le document d'index d'un fichier
+func NewFileDoc(f *store.File) FileDoc {
+	return FileDoc{
+		CID:        f.CID,
+		FileName:   f.FileName,
+		MimeType:   f.MimeType,
+		IsPrivate:  f.IsPrivate,
+		Kind:       f.Kind,
+		FrameRate:  f.FrameRate,
+		Duration:   f.Duration,
+		Width:      f.Width,
+		Height:     f.Height,
+		LayerCount: f.LayerCount,
+		AssetCount: f.AssetCount,
+
+		AnimationCount: f.AnimationCount,
+		DisplayName:    f.DisplayName,
+		Description:    f.Description,
+		Tags:           f.Tags,
+	}
 }
 
 // Handler struct to hold dependencies
 type Handler struct {
-	DB *sql.DB
-	Index bleve.Index
+	Files    store.FileRepository
+	Keys     store.KeyRepository
+	Docs     store.DocRepository
+	Themes   store.ThemeRepository
+	Variants store.VariantRepository
+	Index    bleve.Index
+	// DocsIndex indexe les documents, séparément des fichiers
+	DocsIndex bleve.Index
+	IPFS      service.IPFS
 }
 
-// UploadFileHandler handles the file upload process
-func (h *Handler) UploadFileHandler(w http.ResponseWriter, r *http.Request) {
-	// Vérifier que la requête est bien en méthode POST
-	if r.Method != http.MethodPost {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// Récupérer l'API key depuis les headers
-	apiKey := r.Header.Get("X-API-Key")
-	if apiKey == "" {
-		http.Error(w, "Missing API Key", http.StatusUnauthorized)
-		return
+// New construit un Handler à partir des dépôts du store
+func New(s *store.Store, index, docsIndex bleve.Index, ipfs service.IPFS) *Handler {
+	return &Handler{
+		Files:     s.Files,
+		Keys:      s.Keys,
+		Docs:      s.Docs,
+		Themes:    s.Themes,
+		Variants:  s.Variants,
+		Index:     index,
+		DocsIndex: docsIndex,
+		IPFS:      ipfs,
 	}
+}
 
-	// Vérifier si l'API key existe et récupérer son ID
-	var apiKeyID int
-	err := h.DB.QueryRow("SELECT id FROM api_keys WHERE api_key = ?", apiKey).Scan(&apiKeyID)
-	if err != nil {
-		http.Error(w, "Invalid API Key", http.StatusUnauthorized)
+// UploadFileHandler handles the file upload process
+func (h *Handler) UploadFileHandler(w http.ResponseWriter, r *http.Request) {
+	key, ok := h.requireAPIKey(w, r)
+	if !ok {
 		return
 	}
 
@@ -75,13 +119,53 @@ func (h *Handler) UploadFileHandler(w http.ResponseWriter, r *http.Request) {
 	mimeType := header.Header.Get("Content-Type")
 	fileSize := header.Size
 
-	// Sauvegarder temporairement le fichier
-	tempFilePath := filepath.Join("/tmp", filepath.Base(fileName))
-	tempFile, err := os.Create(tempFilePath)
+	// kind=lottie exige une animation Lottie valide
+	kind := r.FormValue("kind")
+	if kind != "" && kind != store.KindLottie && kind != store.KindDotLottie {
+		http.Error(w, "Invalid kind value", http.StatusBadRequest)
+		return
+	}
+
+	// Suppression des métadonnées EXIF/XMP : valeur de la clé, sauf si le
+	// formulaire la précise
+	stripMetadata := key.StripMetadata
+	if v := r.FormValue("strip_metadata"); v != "" {
+		stripMetadata, err = strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "Invalid strip_metadata value", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Optimisation des animations Lottie : arrondi des nombres, retrait des
+	// clés d'éditeur et fusion des assets dupliqués
+	optimize := false
+	if v := r.FormValue("optimize"); v != "" {
+		optimize, err = strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "Invalid optimize value", http.StatusBadRequest)
+			return
+		}
+	}
+	minifyOpts := lottie.MinifyOptions{Precision: lottie.DefaultPrecision, StripEditorKeys: true, DedupeAssets: true}
+	if v := r.FormValue("precision"); v != "" {
+		minifyOpts.Precision, err = strconv.Atoi(v)
+		if err != nil || minifyOpts.Precision < 0 || minifyOpts.Precision > lottie.MaxPrecision {
+			http.Error(w, "Invalid precision value", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Sauvegarder temporairement le fichier, sous un nom propre à la requête :
+	// deux uploads du même nom ne partagent pas le même fichier
+	tempFile, err := os.CreateTemp("", "upload-*")
 	if err != nil {
 		http.Error(w, "Unable to create temp file", http.StatusInternalServerError)
 		return
 	}
+	tempFilePath := tempFile.Name()
+	// Le fichier temporaire est supprimé quelle que soit l'issue de l'upload
+	defer os.Remove(tempFilePath)
 	defer tempFile.Close()
 
 	// Copier le contenu du fichier uploadé vers le fichier temporaire
@@ -91,128 +175,228 @@ func (h *Handler) UploadFileHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Le type d'image se lit dans le contenu : le Content-Type du formulaire
+	// est celui qu'annonce le client
+	head := make([]byte, 512)
+	n, _ := tempFile.ReadAt(head, 0)
+	isImage := false
+	if detected := imaging.DetectMimeType(head[:n]); detected != "" {
+		mimeType, isImage = detected, true
+	} else if stripMetadata && (strings.HasPrefix(mimeType, "image/") || strings.HasPrefix(http.DetectContentType(head[:n]), "image/")) {
+		// Une image qu'on ne sait pas décoder garderait ses métadonnées
+		http.Error(w, "Unable to strip image metadata", http.StatusBadRequest)
+		return
+	}
+
+	// Lire les en-têtes des images et, si demandé, retirer leurs métadonnées
+	record := &store.File{
+		APIKeyID:  key.ID,
+		IsPrivate: isPrivate,
+		FileName:  fileName,
+		MimeType:  mimeType,
+	}
+	stripped := false
+	if isImage {
+		stripped, err = prepareImage(tempFilePath, record, stripMetadata)
+		if err != nil && stripMetadata {
+			// Impossible de garantir le retrait des métadonnées : on refuse
+			log.Println("Erreur lors du nettoyage des métadonnées de", fileName, ":", err)
+			http.Error(w, "Unable to strip image metadata", http.StatusBadRequest)
+			return
+		} else if err != nil {
+			log.Println("Métadonnées illisibles pour", fileName, ":", err)
+		} else {
+			fileSize = record.FileSize
+		}
+	}
+
+	// Valider les animations Lottie et dotLottie et en extraire les métadonnées
+	lowerName := strings.ToLower(fileName)
+	switch {
+	case kind == store.KindDotLottie || strings.HasSuffix(lowerName, ".lottie"):
+		if err := prepareDotLottie(tempFilePath, record); err != nil {
+			http.Error(w, "Invalid dotLottie file: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	case kind == store.KindLottie || mimeType == "application/json" || strings.HasSuffix(lowerName, ".json"):
+		if err := prepareLottie(tempFilePath, record, kind == store.KindLottie); err != nil {
+			http.Error(w, "Invalid Lottie file: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if optimize {
+		if record.Kind != store.KindLottie {
+			http.Error(w, "optimize only applies to Lottie JSON files", http.StatusBadRequest)
+			return
+		}
+		if err := optimizeLottie(tempFilePath, record, minifyOpts); err != nil {
+			http.Error(w, "Unable to optimize Lottie file: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		fileSize = record.FileSize
+	}
+
+	// Les animations sont servies compressées : on garde leur contenu pour
+	// précalculer les encodages
+	var lottieContent []byte
+	if record.Kind == store.KindLottie {
+		if lottieContent, err = os.ReadFile(tempFilePath); err != nil {
+			http.Error(w, "Failed to save file", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	// Uploader le fichier vers IPFS
-	cid, err := service.UploadFileToIPFS(tempFilePath)
+	cid, err := h.IPFS.UploadFileToIPFS(tempFilePath)
 	if err != nil {
 		http.Error(w, "Failed to upload to IPFS", http.StatusInternalServerError)
 		return
 	}
 
-	// Supprimer le fichier temporaire
-	os.Remove(tempFilePath)
-
 	// Insérer les informations du fichier dans la base de données
-	_, err = h.DB.Exec(
-		"INSERT INTO files (api_key_id, cid, is_private, file_name, mime_type, file_size) VALUES (?, ?, ?, ?, ?, ?)",
-		apiKeyID, cid, isPrivate, fileName, mimeType, fileSize,
-	)
+	record.CID = cid
+	record.FileSize = fileSize
+	err = h.Files.Create(r.Context(), record)
 	if err != nil {
 		http.Error(w, "Failed to save file metadata", http.StatusInternalServerError)
 		return
 	}
 
+	// Les miniatures sont générées dès l'upload ; un échec n'empêche pas l'upload
+	if isImage {
+		h.generateThumbnails(r.Context(), cid, mimeType)
+	}
+	if lottieContent != nil {
+		h.precompress(r.Context(), record, lottieContent)
+	}
+
 	if !isPrivate { // Seulement si le fichier est public
-		doc := FileDoc{
-				CID:       cid,
-				FileName:  fileName,
-				MimeType:  mimeType,
-				IsPrivate: isPrivate,
-		}
-		err = h.Index.Index(cid, doc)
+		err = h.Index.Index(cid, NewFileDoc(record))
 		if err != nil {
-				http.Error(w, "Erreur d'indexation du fichier", http.StatusInternalServerError)
-				return
+			http.Error(w, "Erreur d'indexation du fichier", http.StatusInternalServerError)
+			return
 		}
-}
+	}
 
 	// Réponse HTTP
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(fmt.Sprintf(`{"cid":"%s", "message":"File uploaded successfully"}`, cid)))
-}
-
-// GetPublicFilesHandler handles fetching all public files
-func (h *Handler) GetPublicFilesHandler(w http.ResponseWriter, r *http.Request) {
-	// Vérifier que la requête est bien en méthode GET
-	if r.Method != http.MethodGet {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
-		return
+	response := map[string]interface{}{
+		"cid":     cid,
+		"message": "File uploaded successfully",
 	}
-	var err error
-
-	// Récupérer l'API key depuis les headers
-	apiKey := r.Header.Get("X-API-Key")
-	if apiKey == "" {
-		http.Error(w, "Missing API Key", http.StatusUnauthorized)
-		return
+	mediaFields(response, record)
+	if record.ColorModel != "" {
+		response["metadata_stripped"] = stripped
 	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
 
-	// Vérifier si l'API key existe et récupérer son ID
-	var apiKeyID int
-	err = h.DB.QueryRow("SELECT id FROM api_keys WHERE api_key = ?", apiKey).Scan(&apiKeyID)
+// prepareImage renseigne les dimensions de l'image dans f et, si strip est
+// vrai, réécrit le fichier sans ses métadonnées EXIF/XMP
+func prepareImage(path string, f *store.File, strip bool) (stripped bool, err error) {
+	content, err := os.ReadFile(path)
 	if err != nil {
-		http.Error(w, "Invalid API Key", http.StatusUnauthorized)
-		return
+		return false, err
 	}
+	meta, err := imaging.ReadMetadata(content)
+	if err != nil {
+		return false, err
+	}
+	f.Width, f.Height = meta.Width, meta.Height
+	f.Orientation, f.ColorModel = meta.Orientation, meta.ColorModel
 
-	pageStr := r.URL.Query().Get("page")
-	limitStr := r.URL.Query().Get("limit")
-
-	page := 1
-	limit := 10
-
-	if pageStr != "" {
-		page, err = strconv.Atoi(pageStr)
-		if err != nil || page < 1 {
-			page = 1
+	if strip {
+		if content, err = imaging.StripMetadata(content); err != nil {
+			return false, err
+		}
+		if err := os.WriteFile(path, content, 0o600); err != nil {
+			return false, err
 		}
 	}
+	f.FileSize = int64(len(content))
+	return strip, nil
+}
 
-	if limitStr != "" {
-		limit, err = strconv.Atoi(limitStr)
-		if err != nil || limit < 1 {
-			limit = 10
-		}
+// mediaFields ajoute à une réponse JSON les métadonnées connues d'une image
+// ou d'une animation
+func mediaFields(m map[string]interface{}, f *store.File) {
+	if f.Width > 0 {
+		m["width"] = f.Width
+		m["height"] = f.Height
+	}
+	if f.ColorModel != "" {
+		m["orientation"] = f.Orientation
+		m["color_model"] = f.ColorModel
 	}
+	if f.Kind != "" {
+		m["kind"] = f.Kind
+	}
+	if f.Kind == store.KindLottie || f.Kind == store.KindDotLottie {
+		m["frame_rate"] = f.FrameRate
+		m["duration"] = f.Duration
+		m["layer_count"] = f.LayerCount
+		m["asset_count"] = f.AssetCount
+	}
+	if f.Kind == store.KindDotLottie {
+		m["animation_count"] = f.AnimationCount
+		m["theme_count"] = f.ThemeCount
+	}
+}
 
-	offset := (page - 1) * limit
+// detailFields ajoute à une réponse JSON les informations saisies par le
+// propriétaire du fichier
+func detailFields(m map[string]interface{}, f *store.File) {
+	if f.DisplayName != "" {
+		m["display_name"] = f.DisplayName
+	}
+	if f.Description != "" {
+		m["description"] = f.Description
+	}
+	if len(f.Tags) > 0 {
+		m["tags"] = f.Tags
+	}
+}
 
-	var totalFiles int
-	err = h.DB.QueryRow("SELECT COUNT(*) FROM files WHERE is_private = false").Scan(&totalFiles)
-	if err != nil {
-		http.Error(w, "Failed to query total files", http.StatusInternalServerError)
+// pagination lit les paramètres page et limit (1 et 10 par défaut)
+func pagination(r *http.Request) (page, limit int) {
+	page, limit = 1, 10
+	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 {
+		page = p
+	}
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	return page, limit
+}
+
+// GetPublicFilesHandler handles fetching all public files
+func (h *Handler) GetPublicFilesHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.requireAPIKey(w, r); !ok {
 		return
 	}
 
-	// Récupérer tous les fichiers publics (is_private = false) depuis la base de données
-	rows, err := h.DB.Query("SELECT cid, file_name, mime_type, file_size FROM files WHERE is_private = false LIMIT ? OFFSET ?", limit, offset)
+	page, limit := pagination(r)
+	offset := (page - 1) * limit
+
+	// Récupérer les fichiers publics (is_private = false) depuis la base de données
+	files, totalFiles, err := h.Files.ListPublic(r.Context(), limit, offset)
 	if err != nil {
 		http.Error(w, "Failed to query public files", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
 	// Créer une slice pour stocker les informations des fichiers publics
 	var publicFiles []map[string]interface{}
-
-	// Itérer sur les résultats de la requête
-	for rows.Next() {
-		var cid, fileName, mimeType string
-		var fileSize int64
-		if err := rows.Scan(&cid, &fileName, &mimeType, &fileSize); err != nil {
-			http.Error(w, "Failed to scan file metadata", http.StatusInternalServerError)
-			return
+	for _, f := range files {
+		info := map[string]interface{}{
+			"cid":       f.CID,
+			"file_name": f.FileName,
+			"mime_type": f.MimeType,
+			"file_size": f.FileSize,
 		}
-		publicFiles = append(publicFiles, map[string]interface{}{
-			"cid":       cid,
-			"file_name": fileName,
-			"mime_type": mimeType,
-			"file_size": fileSize,
-		})
-	}
-
-	if err := rows.Err(); err != nil {
-		http.Error(w, "Erreur lors de l'itération des résultats", http.StatusInternalServerError)
-		return
+		mediaFields(info, &f)
+		detailFields(info, &f)
+		publicFiles = append(publicFiles, info)
 	}
 
 	// Construire la réponse avec les informations de pagination
@@ -234,33 +418,12 @@ func (h *Handler) GetPublicFilesHandler(w http.ResponseWriter, r *http.Request)
 func (h *Handler) GetFileByCIDHandler(w http.ResponseWriter, r *http.Request) {
 	log.Println("Handling request to get file by CID")
 
-	if r.Method != http.MethodGet {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
-		return
-	}
-
-	apiKey := r.Header.Get("X-API-Key")
-	if apiKey == "" {
-		http.Error(w, "Missing API Key", http.StatusUnauthorized)
-		return
-	}
-
-	log.Println("Verifying API key:", apiKey)
-	exists, apiErr := security.APIKeyExists(h.DB, apiKey)
-	if apiErr != nil {
-		log.Println("Error checking API key:", apiErr)
-		http.Error(w, "Invalid API Key", http.StatusUnauthorized)
-		return
-	}
-
-	if !exists {
-		log.Println("Invalid API Key provided")
-		http.Error(w, "Invalid API Key", http.StatusUnauthorized)
+	if _, ok := h.requireAPIKey(w, r); !ok {
 		return
 	}
 
 	// Récupérer le CID à partir des paramètres de l'URL
-	cid := r.URL.Query().Get("cid")
+	cid := pathOrQuery(r, "cid")
 	if cid == "" {
 		log.Println("CID missing in request")
 		http.Error(w, "CID manquant", http.StatusBadRequest)
@@ -269,10 +432,8 @@ func (h *Handler) GetFileByCIDHandler(w http.ResponseWriter, r *http.Request) {
 	log.Println("Request for CID:", cid)
 
 	// Vérifier si le fichier existe dans la base de données
-	var fileName, mimeType string
-	var fileSize int64
-	err := h.DB.QueryRow("SELECT file_name, mime_type, file_size FROM files WHERE cid = ?", cid).Scan(&fileName, &mimeType, &fileSize)
-	if err == sql.ErrNoRows {
+	f, err := h.Files.Find(r.Context(), cid, store.FileQuery{})
+	if errors.Is(err, store.ErrNotFound) {
 		log.Println("File not found for CID:", cid)
 		http.Error(w, "Fichier non trouvé", http.StatusNotFound)
 		return
@@ -281,10 +442,10 @@ func (h *Handler) GetFileByCIDHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Erreur lors de la récupération des métadonnées", http.StatusInternalServerError)
 		return
 	}
-	log.Println("Found file:", fileName, "of type:", mimeType, "and size:", fileSize)
+	log.Println("Found file:", f.FileName, "of type:", f.MimeType, "and size:", f.FileSize)
 
 	// Télécharger le contenu du fichier depuis IPFS
-	content, err := service.DownloadFileFromIPFS(cid)
+	content, err := h.IPFS.DownloadFileFromIPFS(cid)
 	if err != nil {
 		log.Println("Error downloading file from IPFS:", err)
 		http.Error(w, "Erreur lors de la récupération du fichier depuis IPFS", http.StatusInternalServerError)
@@ -299,43 +460,33 @@ func (h *Handler) GetFileByCIDHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Définir les en-têtes HTTP pour le type MIME et la taille du fichier
-	w.Header().Set("Content-Type", mimeType)
-	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%s", fileName)) // inline pour affichage direct
-	w.Header().Set("Content-Length", strconv.FormatInt(fileSize, 10))
+	w.Header().Set("Content-Type", f.MimeType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%s", f.FileName)) // inline pour affichage direct
+	w.Header().Set("Content-Length", strconv.FormatInt(f.FileSize, 10))
 
 	// Envoyer le contenu du fichier dans la réponse HTTP
 	_, err = w.Write(content)
 	if err != nil {
 		log.Println("Error sending file content:", err)
-		http.Error(w, "Erreur lors de l'envoi du fichier", http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully served file:", fileName)
+	log.Println("Successfully served file:", f.FileName)
 }
 
 func (h *Handler) GetImageByCIDHandler(w http.ResponseWriter, r *http.Request) {
 	log.Println("Handling request to get image by CID")
 
-	// Vérifier que la requête est bien en méthode GET
-	if r.Method != http.MethodGet {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
-		return
-	}
-	var err error
-
 	// Extraire le CID depuis l'URL
-	cid := r.URL.Query().Get("cid")
+	cid := pathOrQuery(r, "cid")
 	if cid == "" {
 		http.Error(w, "CID manquant", http.StatusBadRequest)
 		return
 	}
 
 	// Vérifier si le fichier est une image dans la base de données
-	var fileName, mimeType string
-	var fileSize int64
-	err = h.DB.QueryRow("SELECT file_name, mime_type, file_size FROM files WHERE cid = ? AND is_private = false", cid).Scan(&fileName, &mimeType, &fileSize)
-	if err == sql.ErrNoRows {
+	f, err := h.Files.Find(r.Context(), cid, store.FileQuery{PublicOnly: true})
+	if errors.Is(err, store.ErrNotFound) {
 		http.Error(w, "Image non trouvée ou accès non autorisé", http.StatusNotFound)
 		return
 	} else if err != nil {
@@ -344,69 +495,62 @@ func (h *Handler) GetImageByCIDHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Vérifier que le type MIME est bien une image
-	if !strings.HasPrefix(mimeType, "image/") {
+	if !strings.HasPrefix(f.MimeType, "image/") {
 		http.Error(w, "Le fichier demandé n'est pas une image", http.StatusBadRequest)
 		return
 	}
 
+	// Variante redimensionnée si w, h, fit ou format sont fournis
+	opts, resize, err := imaging.ParseOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if resize {
+		// Sans API key, seules les tailles déjà en cache sont servies
+		h.serveImageVariant(w, r, f, opts, h.hasAPIKey(r))
+		return
+	}
+
 	// Récupérer le contenu de l'image depuis IPFS
-	content, err := service.DownloadFileFromIPFS(cid)
+	content, err := h.IPFS.DownloadFileFromIPFS(cid)
 	if err != nil {
 		http.Error(w, "Erreur lors de la récupération de l'image depuis IPFS", http.StatusInternalServerError)
 		return
 	}
 
 	// Définir les en-têtes HTTP pour le type MIME et la taille du fichier
-	w.Header().Set("Content-Type", mimeType)
-	w.Header().Set("Content-Length", strconv.FormatInt(fileSize, 10))
+	w.Header().Set("Content-Type", f.MimeType)
+	w.Header().Set("Content-Length", strconv.FormatInt(f.FileSize, 10))
 
 	// Envoyer le contenu de l'image dans la réponse HTTP
 	_, err = w.Write(content)
 	if err != nil {
-		http.Error(w, "Erreur lors de l'envoi de l'image", http.StatusInternalServerError)
+		log.Println("Erreur lors de l'envoi de l'image:", err)
 		return
 	}
 
-	log.Println("Successfully served image:", fileName)
+	log.Println("Successfully served image:", f.FileName)
 }
 
 func (h *Handler) GetPrivateImageByCIDHandler(w http.ResponseWriter, r *http.Request) {
 	log.Println("Handling request to get private image by CID")
 
-	// Vérifier que la requête est bien en méthode GET
-	if r.Method != http.MethodGet {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// Récupérer l'API key depuis les headers
-	apiKey := r.Header.Get("X-API-Key")
-	if apiKey == "" {
-		http.Error(w, "Missing API Key", http.StatusUnauthorized)
-		return
-	}
-
-	// Vérifier si l'API key existe et récupérer son ID
-	var apiKeyID int
-	err := h.DB.QueryRow("SELECT id FROM api_keys WHERE api_key = ?", apiKey).Scan(&apiKeyID)
-	if err != nil {
-		http.Error(w, "Invalid API Key", http.StatusUnauthorized)
+	key, ok := h.requireAPIKey(w, r)
+	if !ok {
 		return
 	}
 
 	// Extraire le CID depuis l'URL
-	cid := r.URL.Query().Get("cid")
+	cid := pathOrQuery(r, "cid")
 	if cid == "" {
 		http.Error(w, "CID manquant", http.StatusBadRequest)
 		return
 	}
 
 	// Vérifier si le fichier est une image privée appartenant à l'utilisateur
-	var fileName, mimeType string
-	var fileSize int64
-	var fileApiKeyID int
-	err = h.DB.QueryRow("SELECT file_name, mime_type, file_size, api_key_id FROM files WHERE cid = ? AND is_private = true", cid).Scan(&fileName, &mimeType, &fileSize, &fileApiKeyID)
-	if err == sql.ErrNoRows {
+	f, err := h.Files.Find(r.Context(), cid, store.FileQuery{PrivateOnly: true})
+	if errors.Is(err, store.ErrNotFound) {
 		http.Error(w, "Image non trouvée ou accès non autorisé", http.StatusNotFound)
 		return
 	} else if err != nil {
@@ -415,19 +559,30 @@ func (h *Handler) GetPrivateImageByCIDHandler(w http.ResponseWriter, r *http.Req
 	}
 
 	// Vérifier que l'utilisateur est bien le propriétaire du fichier
-	if apiKeyID != fileApiKeyID {
+	if key.ID != f.APIKeyID {
 		http.Error(w, "Accès non autorisé", http.StatusUnauthorized)
 		return
 	}
 
 	// Vérifier que le type MIME est bien une image
-	if !strings.HasPrefix(mimeType, "image/") {
+	if !strings.HasPrefix(f.MimeType, "image/") {
 		http.Error(w, "Le fichier demandé n'est pas une image", http.StatusBadRequest)
 		return
 	}
+
+	// Variante redimensionnée si w, h, fit ou format sont fournis
+	opts, resize, err := imaging.ParseOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if resize {
+		h.serveImageVariant(w, r, f, opts, true)
+		return
+	}
 	log.Println("Téléchargement du fichier depuis IPFS avec le CID:", cid)
 	// Récupérer le contenu de l'image depuis IPFS
-	content, err := service.DownloadFileFromIPFS(cid)
+	content, err := h.IPFS.DownloadFileFromIPFS(cid)
 	if err != nil {
 		log.Println("Erreur lors du téléchargement depuis IPFS:", err)
 		http.Error(w, "Erreur lors de la récupération de l'image depuis IPFS", http.StatusInternalServerError)
@@ -440,104 +595,49 @@ func (h *Handler) GetPrivateImageByCIDHandler(w http.ResponseWriter, r *http.Req
 	}
 
 	// Définir les en-têtes HTTP pour le type MIME et la taille du fichier
-	w.Header().Set("Content-Type", mimeType)
-	w.Header().Set("Content-Length", strconv.FormatInt(fileSize, 10))
+	w.Header().Set("Content-Type", f.MimeType)
+	w.Header().Set("Content-Length", strconv.FormatInt(f.FileSize, 10))
 
 	// Envoyer le contenu de l'image dans la réponse HTTP
 	_, err = w.Write(content)
 	if err != nil {
-		http.Error(w, "Erreur lors de l'envoi de l'image", http.StatusInternalServerError)
+		log.Println("Erreur lors de l'envoi de l'image:", err)
 		return
 	}
 
-	log.Println("Successfully served private image:", fileName)
+	log.Println("Successfully served private image:", f.FileName)
 }
 
 func (h *Handler) GetAllFilesForAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
-	// Vérifier que la requête est bien en méthode GET
-	if r.Method != http.MethodGet {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// Récupérer l'API key depuis les headers
-	apiKey := r.Header.Get("X-API-Key")
-	if apiKey == "" {
-		http.Error(w, "Missing API Key", http.StatusUnauthorized)
-		return
-	}
-
-	// Vérifier si l'API key existe et récupérer son ID
-	var apiKeyID int
-	err := h.DB.QueryRow("SELECT id FROM api_keys WHERE api_key = ?", apiKey).Scan(&apiKeyID)
-	if err != nil {
-		http.Error(w, "Invalid API Key", http.StatusUnauthorized)
+	key, ok := h.requireAPIKey(w, r)
+	if !ok {
 		return
 	}
 
 	// Récuperer les parametres de pagination
-	pageStr := r.URL.Query().Get("page")
-	limitStr := r.URL.Query().Get("limit")
-	page := 1
-	limit := 10
-	if pageStr != "" {
-		page, err = strconv.Atoi(pageStr)
-		if err != nil || page < 1 {
-			page = 1
-		}
-	}
-
-	if limitStr != "" {
-		limit, err = strconv.Atoi(limitStr)
-		if err != nil || limit < 1 {
-			limit = 10
-		}
-	}
-
+	page, limit := pagination(r)
 	offset := (page - 1) * limit
 
-	// Compter le nombre total de fichiers privés
-	var totalFiles int
-	err = h.DB.QueryRow("SELECT COUNT(*) FROM files WHERE api_key_id = ?", apiKeyID).Scan(&totalFiles)
-	if err != nil {
-		http.Error(w, "Erreur lors du comptage des fichiers", http.StatusInternalServerError)
-		return
-	}
-
-	// Récupérer tous les fichiers associés à l'API Key
-	rows, err := h.DB.Query("SELECT cid, is_private, file_name, mime_type, file_size FROM files WHERE api_key_id = ? LIMIT ? OFFSET ?", apiKeyID, limit, offset)
+	// Récupérer les fichiers associés à l'API Key et leur nombre total
+	files, totalFiles, err := h.Files.ListByKey(r.Context(), key.ID, limit, offset)
 	if err != nil {
 		http.Error(w, "Failed to query private files", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
 	// Créer une slice pour stocker les informations des fichiers privés
 	var privateFiles []map[string]interface{}
-
-	// Itérer sur les résultats de la requête
-	for rows.Next() {
-		var cid, fileName, mimeType string
-		var isPrivate bool
-		var fileSize int64
-		if err := rows.Scan(&cid, &isPrivate, &fileName, &mimeType, &fileSize); err != nil {
-			http.Error(w, "Failed to scan file metadata", http.StatusInternalServerError)
-			return
-		}
-		privateFile := map[string]interface{}{
-			"cid":       cid,
-			"is_private": isPrivate,
-			"file_name": fileName,
-			"mime_type": mimeType,
-			"file_size": fileSize,
+	for _, f := range files {
+		info := map[string]interface{}{
+			"cid":        f.CID,
+			"is_private": f.IsPrivate,
+			"file_name":  f.FileName,
+			"mime_type":  f.MimeType,
+			"file_size":  f.FileSize,
 		}
-		privateFiles = append(privateFiles, privateFile)
-	}
-
-	// Vérifier les erreurs après l'itération
-	if err := rows.Err(); err != nil {
-		http.Error(w, "Error iterating over rows", http.StatusInternalServerError)
-		return
+		mediaFields(info, &f)
+		detailFields(info, &f)
+		privateFiles = append(privateFiles, info)
 	}
 
 	response := map[string]interface{}{
@@ -558,38 +658,20 @@ func (h *Handler) GetAllFilesForAPIKeyHandler(w http.ResponseWriter, r *http.Req
 func (h *Handler) GetLottieFileByCIDHandler(w http.ResponseWriter, r *http.Request) {
 	log.Println("Handling request to get Lottie file by CID")
 
-	// Vérifier que la requête est bien en méthode GET
-	if r.Method != http.MethodGet {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// Récupérer l'API key depuis les headers
-	apiKey := r.Header.Get("X-API-Key")
-	if apiKey == "" {
-		http.Error(w, "Missing API Key", http.StatusUnauthorized)
-		return
-	}
-
-	// Vérifier si l'API key existe
-	exists, err := security.APIKeyExists(h.DB, apiKey)
-	if err != nil || !exists {
-		http.Error(w, "Invalid API Key", http.StatusUnauthorized)
+	if _, ok := h.requireAPIKey(w, r); !ok {
 		return
 	}
 
 	// Extraire le CID depuis l'URL
-	cid := r.URL.Query().Get("cid")
+	cid := pathOrQuery(r, "cid")
 	if cid == "" {
 		http.Error(w, "CID manquant", http.StatusBadRequest)
 		return
 	}
 
 	// Vérifier si le fichier est un Lottie file dans la base de données
-	var fileName, mimeType string
-	var fileSize int64
-	err = h.DB.QueryRow("SELECT file_name, mime_type, file_size FROM files WHERE cid = ? AND is_private = false", cid).Scan(&fileName, &mimeType, &fileSize)
-	if err == sql.ErrNoRows {
+	f, err := h.Files.Find(r.Context(), cid, store.FileQuery{PublicOnly: true})
+	if errors.Is(err, store.ErrNotFound) {
 		http.Error(w, "Lottie file non trouvé ou accès non autorisé", http.StatusNotFound)
 		return
 	} else if err != nil {
@@ -597,101 +679,105 @@ func (h *Handler) GetLottieFileByCIDHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Vérifier que le type MIME est bien 'application/json'
-	if mimeType != "application/json" {
-		http.Error(w, "Le fichier demandé n'est pas un Lottie file", http.StatusBadRequest)
+	// Archive dotLottie : entière, ou une seule de ses animations
+	if f.Kind == store.KindDotLottie {
+		h.serveDotLottie(w, r, f)
+		return
+	}
+	if r.URL.Query().Has("animation") {
+		http.Error(w, "Le paramètre animation ne s'applique qu'aux archives dotLottie", http.StatusBadRequest)
 		return
 	}
 
-	// Récupérer le contenu du fichier depuis IPFS
-	content, err := service.DownloadFileFromIPFS(cid)
-	if err != nil {
-		http.Error(w, "Erreur lors de la récupération du fichier depuis IPFS", http.StatusInternalServerError)
+	// Seules les animations validées à l'upload, ou les JSON plus anciens que
+	// cette validation, peuvent être servis
+	if f.Kind != store.KindLottie && f.MimeType != "application/json" {
+		http.Error(w, "Le fichier demandé n'est pas un Lottie file", http.StatusBadRequest)
 		return
 	}
 
-	// Définir les en-têtes HTTP pour le type MIME et la taille du fichier
-	w.Header().Set("Content-Type", mimeType)
-	w.Header().Set("Content-Length", strconv.FormatInt(fileSize, 10))
-	w.Header().Set("Cache-Control", "public, max-age=86400") // Cache pendant 1 jour
+	// Le contenu n'est récupéré depuis IPFS qu'une fois, et seulement si
+	// aucune variante compressée ne peut être servie
+	var content []byte
+	load := func() ([]byte, error) {
+		if content == nil {
+			var err error
+			if content, err = h.IPFS.DownloadFileFromIPFS(cid); err != nil {
+				return nil, err
+			}
+		}
+		return content, nil
+	}
 
-	// Envoyer le contenu du fichier dans la réponse HTTP
-	_, err = w.Write(content)
-	if err != nil {
-		http.Error(w, "Erreur lors de l'envoi du fichier", http.StatusInternalServerError)
-		return
+	// Fichier non marqué : on vérifie le contenu avant de le servir
+	if f.Kind != store.KindLottie {
+		content, err := load()
+		if err != nil {
+			http.Error(w, "Erreur lors de la récupération du fichier depuis IPFS", http.StatusInternalServerError)
+			return
+		}
+		if _, err := lottie.Parse(content); err != nil {
+			http.Error(w, "Le fichier demandé n'est pas un Lottie file", http.StatusBadRequest)
+			return
+		}
 	}
 
-	log.Println("Successfully served Lottie file:", fileName)
+	// Envoyer le contenu, compressé si le client l'accepte
+	w.Header().Set("Cache-Control", "public, max-age=86400") // Cache pendant 1 jour
+	h.serveEncoded(w, r, f, load)
+
+	log.Println("Successfully served Lottie file:", f.FileName)
 }
 
 func (h *Handler) DisplayFileByCIDHandler(w http.ResponseWriter, r *http.Request) {
 	log.Println("Handling request to display file by CID")
 
-	// Vérification de la méthode de requête
-	if r.Method != http.MethodGet {
-			http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
-			return
-	}
-
 	// Récupération du CID à partir des paramètres de l'URL
-	cid := r.URL.Query().Get("cid")
+	cid := pathOrQuery(r, "cid")
 	if cid == "" {
-			http.Error(w, "CID manquant", http.StatusBadRequest)
-			return
+		http.Error(w, "CID manquant", http.StatusBadRequest)
+		return
 	}
 
 	// Récupération des métadonnées du fichier avec vérification de is_private = false
-	var fileName, mimeType string
-	var fileSize int64
-	err := h.DB.QueryRow(
-			"SELECT file_name, mime_type, file_size FROM files WHERE cid = ? AND is_private = false",
-			cid,
-	).Scan(&fileName, &mimeType, &fileSize)
-
-	if err == sql.ErrNoRows {
-			http.Error(w, "Fichier non trouvé ou accès non autorisé", http.StatusNotFound)
-			return
+	f, err := h.Files.Find(r.Context(), cid, store.FileQuery{PublicOnly: true})
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Fichier non trouvé ou accès non autorisé", http.StatusNotFound)
+		return
 	} else if err != nil {
-			http.Error(w, "Erreur lors de la récupération des métadonnées", http.StatusInternalServerError)
-			return
+		http.Error(w, "Erreur lors de la récupération des métadonnées", http.StatusInternalServerError)
+		return
 	}
 
 	// Téléchargement du contenu du fichier depuis IPFS
-	content, err := service.DownloadFileFromIPFS(cid)
+	content, err := h.IPFS.DownloadFileFromIPFS(cid)
 	if err != nil {
-			http.Error(w, "Erreur lors de la récupération du fichier depuis IPFS", http.StatusInternalServerError)
-			return
+		http.Error(w, "Erreur lors de la récupération du fichier depuis IPFS", http.StatusInternalServerError)
+		return
 	}
 
 	// Vérification que le contenu n'est pas vide
 	if len(content) == 0 {
-			http.Error(w, "Contenu du fichier vide ou non récupéré", http.StatusInternalServerError)
-			return
+		http.Error(w, "Contenu du fichier vide ou non récupéré", http.StatusInternalServerError)
+		return
 	}
 
 	// Définition des en-têtes HTTP pour afficher le fichier directement dans le navigateur
-	w.Header().Set("Content-Type", mimeType)
-	w.Header().Set("Content-Disposition", "inline; filename="+fileName)
-	w.Header().Set("Content-Length", strconv.FormatInt(fileSize, 10))
+	w.Header().Set("Content-Type", f.MimeType)
+	w.Header().Set("Content-Disposition", "inline; filename="+f.FileName)
+	w.Header().Set("Content-Length", strconv.FormatInt(f.FileSize, 10))
 
 	// Écriture du contenu du fichier dans la réponse HTTP
 	_, err = w.Write(content)
 	if err != nil {
-			log.Println("Erreur lors de l'envoi du fichier :", err)
-			http.Error(w, "Erreur lors de l'envoi du fichier", http.StatusInternalServerError)
-			return
+		log.Println("Erreur lors de l'envoi du fichier :", err)
+		return
 	}
 
-	log.Printf("Fichier %s servi avec succès pour affichage", fileName)
+	log.Printf("Fichier %s servi avec succès pour affichage", f.FileName)
 }
 
 func (h *Handler) SearchPublicFilesHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
-		return
-	}
-
 	query := r.URL.Query().Get("query")
 	if query == "" {
 		http.Error(w, "Missing search query", http.StatusBadRequest)
@@ -699,28 +785,14 @@ func (h *Handler) SearchPublicFilesHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	// Récupérer les paramètres de pagination
-	pageStr := r.URL.Query().Get("page")
-	limitStr := r.URL.Query().Get("limit")
-	page := 1
-	limit := 10
-
-	if pageStr != "" {
-		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
-			page = p
-		}
-	}
-	if limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-			limit = l
-		}
-	}
+	page, limit := pagination(r)
 
 	// Calculer l'offset pour la pagination
 	from := (page - 1) * limit
 
 	// Configurer la requête de recherche avec la pagination
 	searchRequest := bleve.NewSearchRequestOptions(bleve.NewQueryStringQuery(query), limit, from, false)
-	searchRequest.Fields = []string{"cid", "file_name", "mime_type"} // Champs à récupérer
+	searchRequest.Fields = []string{"cid", "file_name", "mime_type", "kind", "duration", "frame_rate", "display_name"} // Champs à récupérer
 
 	searchResult, err := h.Index.Search(searchRequest)
 	if err != nil {
@@ -736,6 +808,14 @@ func (h *Handler) SearchPublicFilesHandler(w http.ResponseWriter, r *http.Reques
 			"file_name": hit.Fields["file_name"],
 			"mime_type": hit.Fields["mime_type"],
 		}
+		if name, _ := hit.Fields["display_name"].(string); name != "" {
+			result["display_name"] = name
+		}
+		if kind, _ := hit.Fields["kind"].(string); kind != "" {
+			result["kind"] = kind
+			result["duration"] = hit.Fields["duration"]
+			result["frame_rate"] = hit.Fields["frame_rate"]
+		}
 		results = append(results, result)
 	}
 
@@ -752,63 +832,258 @@ func (h *Handler) SearchPublicFilesHandler(w http.ResponseWriter, r *http.Reques
 }
 
 func (h *Handler) ToggleFilePrivacyHandler(w http.ResponseWriter, r *http.Request) {
-	// Vérifier que la requête est bien en méthode POST
-	if r.Method != http.MethodPost {
-			http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
-			return
-	}
-
-	// Récupérer l'API Key depuis les headers
-	apiKey := r.Header.Get("X-API-Key")
-	if apiKey == "" {
-			http.Error(w, "Missing API Key", http.StatusUnauthorized)
-			return
-	}
-
-	// Vérifier si l'API Key existe et récupérer son ID
-	var apiKeyID int
-	err := h.DB.QueryRow("SELECT id FROM api_keys WHERE api_key = ?", apiKey).Scan(&apiKeyID)
-	if err != nil {
-			http.Error(w, "Invalid API Key", http.StatusUnauthorized)
-			return
+	key, ok := h.requireAPIKey(w, r)
+	if !ok {
+		return
 	}
 
 	// Récupérer le CID du fichier dans les paramètres de la requête
-	cid := r.URL.Query().Get("cid")
+	cid := pathOrQuery(r, "cid")
 	if cid == "" {
-			http.Error(w, "Missing CID", http.StatusBadRequest)
-			return
+		http.Error(w, "Missing CID", http.StatusBadRequest)
+		return
 	}
 
 	// Vérifier que le fichier est associé à l'API Key
-	var isPrivate bool
-	err = h.DB.QueryRow("SELECT is_private FROM files WHERE cid = ? AND api_key_id = ?", cid, apiKeyID).Scan(&isPrivate)
-	if err == sql.ErrNoRows {
-			http.Error(w, "File not found or unauthorized access", http.StatusNotFound)
-			return
+	f, err := h.Files.Find(r.Context(), cid, store.FileQuery{APIKeyID: key.ID})
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "File not found or unauthorized access", http.StatusNotFound)
+		return
 	} else if err != nil {
-			http.Error(w, "Error retrieving file information", http.StatusInternalServerError)
-			return
+		http.Error(w, "Error retrieving file information", http.StatusInternalServerError)
+		return
 	}
 
 	// Toggle de `is_private` et mise à jour dans la base de données
-	newPrivacyStatus := !isPrivate
-	_, err = h.DB.Exec("UPDATE files SET is_private = ? WHERE cid = ? AND api_key_id = ?", newPrivacyStatus, cid, apiKeyID)
-	if err != nil {
-			http.Error(w, "Failed to update file privacy status", http.StatusInternalServerError)
-			return
+	newPrivacyStatus := !f.IsPrivate
+	if err := h.Files.SetPrivate(r.Context(), cid, key.ID, newPrivacyStatus); err != nil {
+		http.Error(w, "Failed to update file privacy status", http.StatusInternalServerError)
+		return
 	}
 
 	// Réponse en JSON
 	response := map[string]interface{}{
-			"cid":       cid,
-			"is_private": newPrivacyStatus,
-			"message":   "File privacy status updated successfully",
+		"cid":        cid,
+		"is_private": newPrivacyStatus,
+		"message":    "File privacy status updated successfully",
 	}
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 	}
 }
 
+// Limites des informations modifiables d'un fichier, à la mesure des colonnes
+const (
+	maxDisplayNameLength = 255
+	maxDescriptionLength = 2048
+	maxTags              = 20
+	maxTagLength         = 32
+)
+
+// fileDetails est le corps JSON des informations modifiables d'un fichier
+type fileDetails struct {
+	DisplayName string   `json:"display_name"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+}
 
+// normalizeTags met les tags en minuscules et écarte les doublons et les
+// tags vides
+func normalizeTags(tags []string) ([]string, error) {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if strings.Contains(tag, ",") || utf8.RuneCountInString(tag) > maxTagLength {
+			return nil, fmt.Errorf("invalid tag %q: at most %d characters, without commas", tag, maxTagLength)
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+	if len(out) > maxTags {
+		return nil, fmt.Errorf("too many tags: at most %d", maxTags)
+	}
+	return out, nil
+}
+
+// UpdateFileDetailsHandler modifie par JSON Merge Patch le nom d'affichage,
+// la description et les tags d'un fichier de l'API key, sans nouvel envoi
+func (h *Handler) UpdateFileDetailsHandler(w http.ResponseWriter, r *http.Request) {
+	key, ok := h.requireAPIKey(w, r)
+	if !ok {
+		return
+	}
+	if !isMergePatch(r) {
+		http.Error(w, "Body must be a JSON Merge Patch (application/merge-patch+json)", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	cid := r.PathValue("cid")
+	f, err := h.Files.Find(r.Context(), cid, store.FileQuery{APIKeyID: key.ID})
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "File not found or unauthorized access", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Error retrieving file information", http.StatusInternalServerError)
+		return
+	}
+
+	current := fileDetails{DisplayName: f.DisplayName, Description: f.Description, Tags: f.Tags}
+	var details fileDetails
+	if err := applyMergePatch(current, r.Body, &details, true); err != nil {
+		http.Error(w, "Invalid patch: only display_name, description and tags can be changed", http.StatusBadRequest)
+		return
+	}
+	details.DisplayName = strings.TrimSpace(details.DisplayName)
+	details.Description = strings.TrimSpace(details.Description)
+	if utf8.RuneCountInString(details.DisplayName) > maxDisplayNameLength {
+		http.Error(w, fmt.Sprintf("display_name is limited to %d characters", maxDisplayNameLength), http.StatusBadRequest)
+		return
+	}
+	if utf8.RuneCountInString(details.Description) > maxDescriptionLength {
+		http.Error(w, fmt.Sprintf("description is limited to %d characters", maxDescriptionLength), http.StatusBadRequest)
+		return
+	}
+	if details.Tags, err = normalizeTags(details.Tags); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.FileDetails = store.FileDetails{DisplayName: details.DisplayName, Description: details.Description, Tags: details.Tags}
+	if err := h.Files.SetDetails(r.Context(), cid, key.ID, f.FileDetails); err != nil {
+		log.Println("Error updating file details:", err)
+		http.Error(w, "Failed to update file details", http.StatusInternalServerError)
+		return
+	}
+	// Les fichiers publics sont recherchables sur leurs informations
+	if !f.IsPrivate {
+		if err := h.Index.Index(cid, NewFileDoc(f)); err != nil {
+			log.Println("Error reindexing file", cid, ":", err)
+		}
+	}
+
+	response := map[string]interface{}{
+		"cid":          f.CID,
+		"is_private":   f.IsPrivate,
+		"file_name":    f.FileName,
+		"mime_type":    f.MimeType,
+		"file_size":    f.FileSize,
+		"display_name": details.DisplayName,
+		"description":  details.Description,
+		"tags":         details.Tags,
+	}
+	mediaFields(response, f)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// DeleteFileHandler supprime un fichier appartenant à l'API key : métadonnées,
+// entrée d'index et épinglage IPFS
+func (h *Handler) DeleteFileHandler(w http.ResponseWriter, r *http.Request) {
+	key, ok := h.requireAPIKey(w, r)
+	if !ok {
+		return
+	}
+
+	cid := pathOrQuery(r, "cid")
+	if cid == "" {
+		http.Error(w, "Missing CID", http.StatusBadRequest)
+		return
+	}
+
+	force := false
+	if v := r.URL.Query().Get("force"); v != "" {
+		var err error
+		if force, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "Invalid force value", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Les thèmes exigent une animation publique : on refuse de supprimer la
+	// dernière copie publique d'un CID référencé, sauf avec force=true
+	themes, err := h.themesLosingFile(r.Context(), cid, key.ID)
+	if err != nil {
+		http.Error(w, "Error retrieving file information", http.StatusInternalServerError)
+		return
+	}
+	if len(themes) > 0 && !force {
+		http.Error(w, fmt.Sprintf("File is used by cidThemes %s; delete them first or retry with force=true", themeIDs(themes)), http.StatusConflict)
+		return
+	}
+
+	// Seul le propriétaire du fichier peut le supprimer
+	err = h.Files.Delete(r.Context(), cid, key.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "File not found or unauthorized access", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to delete file", http.StatusInternalServerError)
+		return
+	}
+
+	// Une copie publique d'une autre clé reste recherchable : elle remplace
+	// l'entrée d'index, qui n'est supprimée qu'en l'absence de copie publique
+	if other, err := h.Files.Find(r.Context(), cid, store.FileQuery{PublicOnly: true}); err == nil {
+		if err := h.Index.Index(cid, NewFileDoc(other)); err != nil {
+			log.Println("Erreur lors de la réindexation de", cid, ":", err)
+		}
+	} else if errors.Is(err, store.ErrNotFound) {
+		if err := h.Index.Delete(cid); err != nil {
+			log.Println("Erreur lors de la suppression de l'index pour", cid, ":", err)
+		}
+	} else {
+		log.Println("Erreur lors de la recherche des copies publiques de", cid, ":", err)
+	}
+
+	// Le même contenu peut avoir été uploadé par une autre clé : on ne désépingle
+	// que s'il n'est plus référencé
+	if remaining, err := h.Files.CountByCID(r.Context(), cid); err == nil && remaining == 0 {
+		if err := h.IPFS.UnpinFileFromIPFS(cid); err != nil {
+			log.Println("Erreur lors du désépinglage IPFS de", cid, ":", err)
+		}
+		h.dropVariants(r.Context(), cid)
+	}
+
+	response := map[string]interface{}{
+		"cid":     cid,
+		"message": "File deleted successfully",
+	}
+	if len(themes) > 0 {
+		response["orphaned_themes"] = themes
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// themesLosingFile renvoie les thèmes qui n'auraient plus d'animation
+// publique si la clé keyID supprimait sa copie de cid
+func (h *Handler) themesLosingFile(ctx context.Context, cid string, keyID int) ([]store.Theme, error) {
+	_, err := h.Files.Find(ctx, cid, store.FileQuery{PublicOnly: true, APIKeyID: keyID})
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	_, err = h.Files.Find(ctx, cid, store.FileQuery{PublicOnly: true, ExcludeAPIKeyID: keyID})
+	if err == nil {
+		return nil, nil // une autre clé publie le même contenu
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+	return h.Themes.ListByCID(ctx, cid)
+}
+
+func themeIDs(themes []store.Theme) string {
+	ids := make([]string, len(themes))
+	for i, t := range themes {
+		ids[i] = strconv.Itoa(t.ID)
+	}
+	return strings.Join(ids, ", ")
+}
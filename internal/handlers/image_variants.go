@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/imaging"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/service"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/store"
+)
+
+// maxImageVariants borne le nombre de variantes redimensionnées en cache
+// par image, miniatures comprises
+const maxImageVariants = 16
+
+var (
+	// errVariantKeyRequired : seule une requête avec API key génère une
+	// variante absente du cache
+	errVariantKeyRequired = errors.New("API key required to create a variant")
+	// errTooManyVariants : l'image a déjà maxImageVariants variantes
+	errTooManyVariants = errors.New("too many variants for this image")
+)
+
+// serveImageVariant envoie la variante opts de l'image f, en la générant au
+// premier appel puis depuis le cache. create autorise la génération d'une
+// variante absente du cache.
+func (h *Handler) serveImageVariant(w http.ResponseWriter, r *http.Request, f *store.File, opts imaging.Options, create bool) {
+	v, content, err := h.imageVariant(r.Context(), f.CID, opts.Resolve(f.MimeType), create)
+	switch {
+	case errors.Is(err, errVariantKeyRequired):
+		http.Error(w, "API key requise pour générer une nouvelle taille d'image", http.StatusUnauthorized)
+		return
+	case errors.Is(err, errTooManyVariants):
+		http.Error(w, "Nombre maximal de tailles atteint pour cette image", http.StatusForbidden)
+		return
+	case errors.Is(err, imaging.ErrUnsupportedFormat):
+		http.Error(w, "Format d'image non pris en charge pour le redimensionnement", http.StatusUnsupportedMediaType)
+		return
+	case errors.Is(err, imaging.ErrTooLarge):
+		http.Error(w, "Image source trop grande pour être redimensionnée", http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Println("Erreur lors de la génération de la variante de", f.CID, ":", err)
+		http.Error(w, "Erreur lors de la génération de l'image redimensionnée", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", v.MimeType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	if _, err := w.Write(content); err != nil {
+		log.Println("Erreur lors de l'envoi de l'image:", err)
+	}
+}
+
+// imageVariant renvoie la variante et son contenu, en la générant et en
+// l'ajoutant à IPFS si elle n'est pas encore en cache et que create le
+// permet. opts doit être résolue.
+func (h *Handler) imageVariant(ctx context.Context, sourceCID string, opts imaging.Options, create bool) (*store.Variant, []byte, error) {
+	key := opts.Key()
+	v, err := h.Variants.Find(ctx, sourceCID, key)
+	switch {
+	case err == nil:
+		content, err := h.IPFS.DownloadFileFromIPFS(v.CID)
+		if err == nil {
+			return v, content, nil
+		}
+		// Variante perdue côté IPFS : on la régénère
+		log.Println("Variante", v.CID, "introuvable sur IPFS, régénération :", err)
+	case !errors.Is(err, store.ErrNotFound):
+		return nil, nil, err
+	// Chaque nouvelle variante est épinglée et enregistrée : on en borne la
+	// création
+	case !create:
+		return nil, nil, errVariantKeyRequired
+	default:
+		count, err := h.imageVariantCount(ctx, sourceCID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if count >= maxImageVariants {
+			return nil, nil, errTooManyVariants
+		}
+	}
+
+	src, err := h.IPFS.DownloadFileFromIPFS(sourceCID)
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := imaging.Resize(src, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	cid, err := service.AddBytes(h.IPFS, res.Data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	v = &store.Variant{
+		SourceCID:  sourceCID,
+		VariantKey: key,
+		CID:        cid,
+		MimeType:   res.MimeType,
+		FileSize:   int64(len(res.Data)),
+		Width:      res.Width,
+		Height:     res.Height,
+	}
+	if err := h.Variants.Create(ctx, v); err != nil {
+		// Requête concurrente ou ligne orpheline : la variante reste servable
+		log.Println("Erreur lors de l'enregistrement de la variante", key, "de", sourceCID, ":", err)
+	}
+	return v, res.Data, nil
+}
+
+// imageVariantCount compte les variantes redimensionnées en cache de
+// sourceCID, sans les encodages compressés
+func (h *Handler) imageVariantCount(ctx context.Context, sourceCID string) (int, error) {
+	variants, err := h.Variants.ListBySource(ctx, sourceCID)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, v := range variants {
+		if !strings.HasPrefix(v.VariantKey, encodingKey("")) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// generateThumbnails prépare les miniatures standard d'une image uploadée
+func (h *Handler) generateThumbnails(ctx context.Context, cid, mimeType string) {
+	for _, opts := range imaging.Thumbnails {
+		if _, _, err := h.imageVariant(ctx, cid, opts.Resolve(mimeType), true); err != nil {
+			log.Println("Erreur lors de la génération de la miniature", opts.Key(), "de", cid, ":", err)
+			return
+		}
+	}
+}
+
+// dropVariants désépingle et oublie les variantes d'un CID supprimé
+func (h *Handler) dropVariants(ctx context.Context, sourceCID string) {
+	variants, err := h.Variants.ListBySource(ctx, sourceCID)
+	if err != nil {
+		log.Println("Erreur lors de la lecture des variantes de", sourceCID, ":", err)
+		return
+	}
+	for _, v := range variants {
+		if err := h.IPFS.UnpinFileFromIPFS(v.CID); err != nil {
+			log.Println("Erreur lors du désépinglage IPFS de la variante", v.CID, ":", err)
+		}
+	}
+	if err := h.Variants.DeleteBySource(ctx, sourceCID); err != nil {
+		log.Println("Erreur lors de la suppression des variantes de", sourceCID, ":", err)
+	}
+}
@@ -0,0 +1,284 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/lottie"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/service"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/store"
+)
+
+// prepareLottie valide une animation et renseigne ses métadonnées dans f.
+// Un fichier non conforme n'est une erreur que si required est vrai : il est
+// alors enregistré comme un JSON ordinaire.
+func prepareLottie(path string, f *store.File, required bool) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	info, err := lottie.Parse(content)
+	if err != nil {
+		if required {
+			return err
+		}
+		return nil
+	}
+	f.Kind = store.KindLottie
+	f.MimeType = "application/json"
+	setLottieInfo(f, info)
+	return nil
+}
+
+// optimizeLottie réécrit l'animation déjà validée de path sous sa forme
+// minifiée et met à jour la taille de f
+func optimizeLottie(path string, f *store.File, opts lottie.MinifyOptions) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if content, err = lottie.Minify(content, opts); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		return err
+	}
+	f.FileSize = int64(len(content))
+	return nil
+}
+
+// prepareDotLottie valide une archive dotLottie et renseigne dans f ses
+// métadonnées et celles de son animation active
+func prepareDotLottie(path string, f *store.File) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	archive, err := lottie.OpenArchive(content)
+	if err != nil {
+		return err
+	}
+	describeArchive(f, archive)
+	return nil
+}
+
+func describeArchive(f *store.File, archive *lottie.Archive) {
+	f.Kind = store.KindDotLottie
+	f.MimeType = "application/zip"
+	setLottieInfo(f, archive.Active().Info)
+	f.AssetCount += archive.ImageCount
+	f.AnimationCount = len(archive.Animations)
+	f.ThemeCount = len(archive.Manifest.Themes)
+}
+
+func setLottieInfo(f *store.File, info *lottie.Info) {
+	f.Width, f.Height = info.Width, info.Height
+	f.FrameRate, f.Duration = info.FrameRate, info.Duration
+	f.LayerCount, f.AssetCount = info.LayerCount, info.AssetCount
+}
+
+// serveDotLottie envoie l'archive f, ou l'animation désignée par ?animation=
+func (h *Handler) serveDotLottie(w http.ResponseWriter, r *http.Request, f *store.File) {
+	content, err := h.IPFS.DownloadFileFromIPFS(f.CID)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération du fichier depuis IPFS", http.StatusInternalServerError)
+		return
+	}
+
+	contentType := "application/zip"
+	if id := r.URL.Query().Get("animation"); id != "" {
+		archive, err := lottie.OpenArchive(content)
+		if err != nil {
+			log.Println("Archive dotLottie illisible", f.CID, ":", err)
+			http.Error(w, "Archive dotLottie invalide", http.StatusInternalServerError)
+			return
+		}
+		content, err = archive.Animation(id)
+		if errors.Is(err, lottie.ErrNoAnimation) {
+			http.Error(w, "Animation non trouvée dans l'archive", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Erreur lors de la lecture de l'archive", http.StatusInternalServerError)
+			return
+		}
+		contentType = "application/json"
+	} else {
+		w.Header().Set("Content-Disposition", "inline; filename="+f.FileName)
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.Header().Set("Cache-Control", "public, max-age=86400") // Cache pendant 1 jour
+	if _, err := w.Write(content); err != nil {
+		log.Println("Erreur lors de l'envoi du fichier:", err)
+	}
+}
+
+// GetDotLottieManifestHandler liste les animations et les thèmes d'une
+// archive dotLottie publique
+func (h *Handler) GetDotLottieManifestHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.requireAPIKey(w, r); !ok {
+		return
+	}
+
+	cid := pathOrQuery(r, "cid")
+	f, err := h.Files.Find(r.Context(), cid, store.FileQuery{PublicOnly: true})
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Lottie file non trouvé ou accès non autorisé", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Erreur lors de la récupération des métadonnées", http.StatusInternalServerError)
+		return
+	}
+	if f.Kind != store.KindDotLottie {
+		http.Error(w, "Le fichier demandé n'est pas une archive dotLottie", http.StatusBadRequest)
+		return
+	}
+
+	content, err := h.IPFS.DownloadFileFromIPFS(cid)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération du fichier depuis IPFS", http.StatusInternalServerError)
+		return
+	}
+	archive, err := lottie.OpenArchive(content)
+	if err != nil {
+		log.Println("Archive dotLottie illisible", cid, ":", err)
+		http.Error(w, "Archive dotLottie invalide", http.StatusInternalServerError)
+		return
+	}
+
+	animations := []map[string]interface{}{}
+	for _, a := range archive.Animations {
+		animations = append(animations, map[string]interface{}{
+			"id":          a.ID,
+			"frame_rate":  a.FrameRate,
+			"duration":    a.Duration,
+			"width":       a.Width,
+			"height":      a.Height,
+			"layer_count": a.LayerCount,
+			"asset_count": a.AssetCount,
+		})
+	}
+	themes := archive.Manifest.Themes
+	if themes == nil {
+		themes = []lottie.ManifestTheme{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"cid":                 cid,
+		"version":             archive.Manifest.Version,
+		"generator":           archive.Manifest.Generator,
+		"active_animation_id": archive.Active().ID,
+		"animations":          animations,
+		"themes":              themes,
+	})
+}
+
+var animationIDChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// ConvertToDotLottieHandler crée, pour l'API key, une archive dotLottie à
+// partir d'une animation Lottie JSON publique ou lui appartenant
+func (h *Handler) ConvertToDotLottieHandler(w http.ResponseWriter, r *http.Request) {
+	key, ok := h.requireAPIKey(w, r)
+	if !ok {
+		return
+	}
+
+	cid := pathOrQuery(r, "cid")
+	src, err := h.Files.Find(r.Context(), cid, store.FileQuery{PublicOnly: true})
+	if errors.Is(err, store.ErrNotFound) {
+		src, err = h.Files.Find(r.Context(), cid, store.FileQuery{APIKeyID: key.ID})
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "File not found or unauthorized access", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Error retrieving file information", http.StatusInternalServerError)
+		return
+	}
+	if src.Kind != store.KindLottie && src.MimeType != "application/json" {
+		http.Error(w, "Only Lottie JSON files can be converted", http.StatusBadRequest)
+		return
+	}
+
+	// Visibilité de la source par défaut
+	isPrivate := src.IsPrivate
+	if v := r.URL.Query().Get("is_private"); v != "" {
+		if isPrivate, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "Invalid is_private value", http.StatusBadRequest)
+			return
+		}
+	}
+
+	baseName := strings.TrimSuffix(src.FileName, filepath.Ext(src.FileName))
+	animationID := r.URL.Query().Get("animation_id")
+	if animationID == "" {
+		animationID = strings.Trim(animationIDChars.ReplaceAllString(baseName, "-"), "-")
+	}
+	if animationID == "" {
+		animationID = "animation"
+	}
+
+	content, err := h.IPFS.DownloadFileFromIPFS(cid)
+	if err != nil {
+		http.Error(w, "Failed to download from IPFS", http.StatusInternalServerError)
+		return
+	}
+	packed, err := lottie.Pack(animationID, content)
+	if errors.Is(err, lottie.ErrInvalid) {
+		http.Error(w, "Invalid Lottie file: "+err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to build dotLottie archive", http.StatusInternalServerError)
+		return
+	}
+	archive, err := lottie.OpenArchive(packed)
+	if err != nil {
+		http.Error(w, "Failed to build dotLottie archive", http.StatusInternalServerError)
+		return
+	}
+
+	newCID, err := service.AddBytes(h.IPFS, packed)
+	if err != nil {
+		http.Error(w, "Failed to upload to IPFS", http.StatusInternalServerError)
+		return
+	}
+	record := &store.File{
+		APIKeyID:  key.ID,
+		CID:       newCID,
+		IsPrivate: isPrivate,
+		FileName:  baseName + ".lottie",
+		FileSize:  int64(len(packed)),
+	}
+	describeArchive(record, archive)
+	if err := h.Files.Create(r.Context(), record); err != nil {
+		http.Error(w, "Failed to save file metadata", http.StatusInternalServerError)
+		return
+	}
+	if !isPrivate {
+		if err := h.Index.Index(newCID, NewFileDoc(record)); err != nil {
+			http.Error(w, "Erreur d'indexation du fichier", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	response := map[string]interface{}{
+		"cid":        newCID,
+		"source_cid": cid,
+		"file_name":  record.FileName,
+		"is_private": isPrivate,
+		"message":    "dotLottie archive created successfully",
+	}
+	mediaFields(response, record)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// mergePatch applique un JSON Merge Patch (RFC 7396) : les membres du patch
+// remplacent ceux de target, null les supprime et les objets sont fusionnés
+// récursivement ; tout autre patch remplace target
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = mergePatch(t[k], v)
+		}
+	}
+	return t
+}
+
+// applyMergePatch applique le patch lu dans body à current et décode le
+// résultat dans dst ; strict refuse les membres que dst ne connaît pas
+func applyMergePatch(current interface{}, body io.Reader, dst interface{}, strict bool) error {
+	var patch interface{}
+	if err := json.NewDecoder(body).Decode(&patch); err != nil {
+		return err
+	}
+	raw, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	var target interface{}
+	if err := json.Unmarshal(raw, &target); err != nil {
+		return err
+	}
+	merged, err := json.Marshal(mergePatch(target, patch))
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(merged))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	return dec.Decode(dst)
+}
+
+// isMergePatch vérifie que le corps d'un PATCH est un Merge Patch ; le
+// type application/json reste accepté
+func isMergePatch(r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/merge-patch+json" || mediaType == "application/json")
+}
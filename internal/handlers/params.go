@@ -0,0 +1,12 @@
+package handler
+
+import "net/http"
+
+// pathOrQuery renvoie le paramètre de chemin name (routes /v1) ou, à défaut,
+// le paramètre de requête du même nom (anciennes routes plates).
+func pathOrQuery(r *http.Request, name string) string {
+	if v := r.PathValue(name); v != "" {
+		return v
+	}
+	return r.URL.Query().Get(name)
+}
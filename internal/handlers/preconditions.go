@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// rowETag dérive l'ETag fort d'une ressource de son compteur de modifications
+func rowETag(kind string, id, rowVersion int) string {
+	return fmt.Sprintf(`"%s-%d-%d"`, kind, id, rowVersion)
+}
+
+func docETag(d *Doc) string {
+	return rowETag("doc", d.ID, d.RowVersion)
+}
+
+func themeETag(t *CidTheme) string {
+	return rowETag("theme", t.ID, t.RowVersion)
+}
+
+// ifMatch applique la comparaison forte d'If-Match : un ETag faible ne
+// correspond jamais
+func ifMatch(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// preconditionFailed répond 412 avec la version actuelle de la ressource,
+// que le client peut fusionner avant de réessayer
+func preconditionFailed(w http.ResponseWriter, etag string, current interface{}) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusPreconditionFailed)
+	json.NewEncoder(w).Encode(current)
+}
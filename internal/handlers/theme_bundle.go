@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/store"
+)
+
+// bundleFile décrit un fichier d'un bundle de thème. Le contenu est inliné
+// dans Animation (JSON) ou Data (base64, archives dotLottie), ou rangé sous
+// Path dans le zip.
+type bundleFile struct {
+	Kind      string          `json:"kind"`
+	MimeType  string          `json:"mime_type"`
+	FileSize  int64           `json:"file_size"`
+	Animation json.RawMessage `json:"animation,omitempty"`
+	Data      []byte          `json:"data,omitempty"`
+	Path      string          `json:"path,omitempty"`
+}
+
+// themeBundle est la réponse de GetThemeBundleHandler, et le bundle.json du zip
+type themeBundle struct {
+	Theme *CidTheme              `json:"theme"`
+	Files map[string]*bundleFile `json:"files"`
+	// Missing liste les CID référencés qui ne sont plus publics
+	Missing []string `json:"missing"`
+}
+
+// GetThemeBundleHandler renvoie un thème avec le contenu de toutes ses
+// animations, en JSON ou, avec format=zip, dans une archive
+func (h *Handler) GetThemeBundleHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.requireAPIKey(w, r); !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID parameter", http.StatusBadRequest)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "zip" {
+		http.Error(w, "format must be json or zip", http.StatusBadRequest)
+		return
+	}
+
+	theme, err := h.Themes.Get(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "CidTheme not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to query cidTheme", http.StatusInternalServerError)
+		return
+	}
+
+	// Fichiers membres, sans doublon : animation principale puis rôles
+	bundle := &themeBundle{Theme: theme, Files: map[string]*bundleFile{}, Missing: []string{}}
+	var members []*store.File
+	for _, cid := range themeMembers(theme) {
+		f, err := h.Files.Find(r.Context(), cid, store.FileQuery{PublicOnly: true})
+		if errors.Is(err, store.ErrNotFound) {
+			bundle.Missing = append(bundle.Missing, cid)
+			continue
+		} else if err != nil {
+			http.Error(w, "Error retrieving file information", http.StatusInternalServerError)
+			return
+		}
+		members = append(members, f)
+	}
+
+	// L'ETag ne dépend que du thème et des CID membres : le contenu d'un CID
+	// ne change jamais, inutile de le télécharger pour revalider
+	etag := bundleETag(theme, members, format)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "public, no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	contents := make(map[string][]byte, len(members))
+	for _, f := range members {
+		content, err := h.IPFS.DownloadFileFromIPFS(f.CID)
+		if err != nil {
+			log.Println("Erreur lors de la récupération de", f.CID, "pour le thème", id, ":", err)
+			http.Error(w, "Failed to download from IPFS", http.StatusInternalServerError)
+			return
+		}
+		contents[f.CID] = content
+		kind := f.Kind
+		if kind == "" {
+			kind = store.KindLottie // JSON antérieur au marquage des animations
+		}
+		bundle.Files[f.CID] = &bundleFile{Kind: kind, MimeType: f.MimeType, FileSize: int64(len(content))}
+	}
+
+	if format == "zip" {
+		h.writeBundleZip(w, bundle, contents)
+		return
+	}
+
+	for cid, bf := range bundle.Files {
+		if bf.Kind == store.KindDotLottie || !json.Valid(contents[cid]) {
+			bf.Data = contents[cid]
+		} else {
+			bf.Animation = contents[cid]
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bundle)
+}
+
+func (h *Handler) writeBundleZip(w http.ResponseWriter, bundle *themeBundle, contents map[string][]byte) {
+	// Ordre stable : l'ETag fort promet un contenu identique à l'octet près
+	cids := make([]string, 0, len(bundle.Files))
+	for cid := range bundle.Files {
+		cids = append(cids, cid)
+	}
+	sort.Strings(cids)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, cid := range cids {
+		bf := bundle.Files[cid]
+		ext := ".json"
+		if bf.Kind == store.KindDotLottie {
+			ext = ".lottie"
+		}
+		bf.Path = "animations/" + cid + ext
+		if err := writeZipEntry(zw, bf.Path, contents[cid]); err != nil {
+			http.Error(w, "Failed to build theme bundle", http.StatusInternalServerError)
+			return
+		}
+	}
+	manifest, err := json.MarshalIndent(bundle, "", "  ")
+	if err == nil {
+		err = writeZipEntry(zw, "bundle.json", manifest)
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		http.Error(w, "Failed to build theme bundle", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", "attachment; filename=theme-"+strconv.Itoa(bundle.Theme.ID)+".zip")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Println("Erreur lors de l'envoi du bundle:", err)
+	}
+}
+
+func writeZipEntry(zw *zip.Writer, name string, content []byte) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(content)
+	return err
+}
+
+// themeMembers renvoie les CID d'animation d'un thème, sans doublon
+func themeMembers(theme *CidTheme) []string {
+	cids := []string{theme.CID}
+	seen := map[string]bool{theme.CID: true}
+	for _, a := range theme.Animations {
+		if !seen[a.CID] {
+			seen[a.CID] = true
+			cids = append(cids, a.CID)
+		}
+	}
+	return cids
+}
+
+// bundleETag dérive un ETag fort du thème, des CID membres disponibles et
+// du format de la réponse
+func bundleETag(theme *CidTheme, members []*store.File, format string) string {
+	sum := sha256.New()
+	encoded, _ := json.Marshal(theme)
+	sum.Write(encoded)
+	for _, f := range members {
+		sum.Write([]byte("\n" + f.CID + " " + f.Kind + " " + f.MimeType))
+	}
+	sum.Write([]byte("\n" + format))
+	return `"` + hex.EncodeToString(sum.Sum(nil))[:32] + `"`
+}
+
+// etagMatches applique la comparaison faible d'If-None-Match
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
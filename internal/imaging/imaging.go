@@ -0,0 +1,281 @@
+// Package imaging redimensionne les images servies par le service. Tout est
+// en Go pur : JPEG, PNG, GIF et WebP sont lus, seuls JPEG et PNG sont écrits
+// (la bibliothèque standard n'a pas d'encodeur WebP).
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Limites appliquées aux variantes et aux images sources
+const (
+	MaxDimension    = 2048
+	MaxSourcePixels = 50_000_000
+)
+
+// Modes de redimensionnement (paramètre fit)
+const (
+	// FitContain tient dans la boîte en conservant les proportions, sans agrandir
+	FitContain = "contain"
+	// FitCover remplit la boîte en conservant les proportions, puis recadre au centre
+	FitCover = "cover"
+	// FitFill étire l'image aux dimensions demandées
+	FitFill = "fill"
+)
+
+// Formats de sortie (paramètre format)
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+)
+
+var (
+	ErrInvalidOptions    = errors.New("imaging: invalid options")
+	ErrUnsupportedFormat = errors.New("imaging: unsupported format")
+	ErrTooLarge          = errors.New("imaging: source image too large")
+)
+
+// Options décrit une variante demandée
+type Options struct {
+	Width  int
+	Height int
+	Fit    string
+	Format string
+}
+
+// Thumbnails sont les variantes générées dès l'upload
+var Thumbnails = []Options{
+	{Width: 200, Height: 200, Fit: FitCover},
+	{Width: 640, Fit: FitContain},
+}
+
+// ParseOptions lit w, h, fit et format. ok vaut false si aucun n'est présent :
+// l'original doit alors être servi tel quel.
+func ParseOptions(q url.Values) (o Options, ok bool, err error) {
+	for _, name := range []string{"w", "h", "fit", "format"} {
+		if q.Has(name) {
+			ok = true
+		}
+	}
+	if !ok {
+		return o, false, nil
+	}
+
+	if o.Width, err = dimension(q.Get("w")); err != nil {
+		return o, true, fmt.Errorf("%w: w %v", ErrInvalidOptions, err)
+	}
+	if o.Height, err = dimension(q.Get("h")); err != nil {
+		return o, true, fmt.Errorf("%w: h %v", ErrInvalidOptions, err)
+	}
+
+	o.Fit = strings.ToLower(q.Get("fit"))
+	switch o.Fit {
+	case "", FitContain, FitCover, FitFill:
+	default:
+		return o, true, fmt.Errorf("%w: fit must be contain, cover or fill", ErrInvalidOptions)
+	}
+	if o.Fit == FitCover || o.Fit == FitFill {
+		if o.Width == 0 || o.Height == 0 {
+			return o, true, fmt.Errorf("%w: fit=%s requires w and h", ErrInvalidOptions, o.Fit)
+		}
+	}
+
+	o.Format = strings.ToLower(q.Get("format"))
+	switch o.Format {
+	case "", FormatJPEG, FormatPNG:
+	case "jpg":
+		o.Format = FormatJPEG
+	case "webp":
+		return o, true, fmt.Errorf("%w: webp output is not available", ErrUnsupportedFormat)
+	default:
+		return o, true, fmt.Errorf("%w: %s", ErrUnsupportedFormat, o.Format)
+	}
+	return o, true, nil
+}
+
+func dimension(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, errors.New("must be a positive integer")
+	}
+	if n > MaxDimension {
+		return 0, fmt.Errorf("must not exceed %d", MaxDimension)
+	}
+	return n, nil
+}
+
+// Resolve complète les valeurs par défaut pour une source de type mimeType :
+// fit contain et format identique à la source (PNG si elle n'est pas encodable)
+func (o Options) Resolve(mimeType string) Options {
+	if o.Fit == "" {
+		o.Fit = FitContain
+	}
+	if o.Format == "" {
+		o.Format = FormatPNG
+		if mimeType == "image/jpeg" {
+			o.Format = FormatJPEG
+		}
+	}
+	return o
+}
+
+// Key identifie la variante de façon canonique (clé de cache)
+func (o Options) Key() string {
+	return fmt.Sprintf("w=%d,h=%d,fit=%s,format=%s", o.Width, o.Height, o.Fit, o.Format)
+}
+
+// MimeType renvoie le type MIME du format de sortie
+func (o Options) MimeType() string {
+	if o.Format == FormatJPEG {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
+
+// CanDecode indique si une source de ce type peut être redimensionnée
+func CanDecode(mimeType string) bool {
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return true
+	}
+	return false
+}
+
+// Result est une variante encodée
+type Result struct {
+	Data     []byte
+	MimeType string
+	Width    int
+	Height   int
+}
+
+// Resize décode src et produit la variante décrite par o (déjà résolue).
+// L'orientation EXIF de la source est appliquée : la variante, sans
+// métadonnées, s'affiche dans le bon sens.
+func Resize(src []byte, o Options) (*Result, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(src))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+	if cfg.Width*cfg.Height > MaxSourcePixels {
+		return nil, ErrTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(src))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+	if tiff := exifPayload(src); tiff != nil {
+		img = orient(img, exifOrientation(tiff))
+	}
+
+	srcRect, width, height := geometry(img.Bounds(), o)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	if o.Format == FormatJPEG {
+		// JPEG n'a pas de transparence : fond blanc plutôt que noir
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	}
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, srcRect, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if o.Format == FormatJPEG {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&buf, dst)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Data: buf.Bytes(), MimeType: o.MimeType(), Width: width, Height: height}, nil
+}
+
+// orient redresse img selon son orientation EXIF (2 à 8 ; sinon img est
+// renvoyée telle quelle)
+func orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	w, h := b.Dx(), b.Dy()
+
+	dw, dh := w, h
+	if orientation >= 5 { // rotation d'un quart de tour : dimensions échangées
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			// (sx, sy) : pixel source affiché en (x, y)
+			var sx, sy int
+			switch orientation {
+			case 2: // miroir horizontal
+				sx, sy = w-1-x, y
+			case 3: // demi-tour
+				sx, sy = w-1-x, h-1-y
+			case 4: // miroir vertical
+				sx, sy = x, h-1-y
+			case 5: // transposition
+				sx, sy = y, x
+			case 6: // quart de tour horaire
+				sx, sy = y, h-1-x
+			case 7: // transposition inverse
+				sx, sy = w-1-y, h-1-x
+			case 8: // quart de tour antihoraire
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+	return dst
+}
+
+// geometry calcule la zone source à lire et les dimensions de sortie
+func geometry(b image.Rectangle, o Options) (image.Rectangle, int, int) {
+	sw, sh := float64(b.Dx()), float64(b.Dy())
+	w, h := float64(o.Width), float64(o.Height)
+
+	switch {
+	case o.Fit == FitFill:
+		return b, o.Width, o.Height
+
+	case o.Fit == FitCover:
+		// Recadrage centré au ratio de la boîte, d'au moins un pixel même
+		// pour un ratio extrême
+		cw, ch := sw, sw*h/w
+		if ch > sh {
+			cw, ch = sh*w/h, sh
+		}
+		x0 := b.Min.X + int((sw-cw)/2)
+		y0 := b.Min.Y + int((sh-ch)/2)
+		return image.Rect(x0, y0, x0+max(1, int(cw)), y0+max(1, int(ch))), o.Width, o.Height
+	}
+
+	// contain : la plus petite échelle respectant les dimensions données et
+	// MaxDimension, même sans dimension demandée
+	scale := math.Min(1, math.Min(MaxDimension/sw, MaxDimension/sh))
+	if w > 0 {
+		scale = math.Min(scale, w/sw)
+	}
+	if h > 0 {
+		scale = math.Min(scale, h/sh)
+	}
+	return b, max(1, int(math.Round(sw*scale))), max(1, int(math.Round(sh*scale)))
+}
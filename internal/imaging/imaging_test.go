@@ -0,0 +1,138 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/url"
+	"testing"
+)
+
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		img.Set(x, 0, color.RGBA{R: 255, A: 255})
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseOptions(t *testing.T) {
+	cases := []struct {
+		query string
+		ok    bool
+		err   error
+	}{
+		{"", false, nil},
+		{"w=100", true, nil},
+		{"w=100&h=50&fit=cover&format=jpg", true, nil},
+		{"w=0", true, ErrInvalidOptions},
+		{"w=5000", true, ErrInvalidOptions},
+		{"w=100&fit=cover", true, ErrInvalidOptions},
+		{"fit=stretch", true, ErrInvalidOptions},
+		{"w=100&format=webp", true, ErrUnsupportedFormat},
+		{"w=100&format=tiff", true, ErrUnsupportedFormat},
+	}
+	for _, c := range cases {
+		q, _ := url.ParseQuery(c.query)
+		_, ok, err := ParseOptions(q)
+		if ok != c.ok || !errors.Is(err, c.err) {
+			t.Errorf("%q : ok=%v err=%v, attendu ok=%v err=%v", c.query, ok, err, c.ok, c.err)
+		}
+	}
+}
+
+func TestResolveAndKey(t *testing.T) {
+	o := Options{Width: 100}.Resolve("image/jpeg")
+	if o.Key() != "w=100,h=0,fit=contain,format=jpeg" {
+		t.Errorf("Key = %q", o.Key())
+	}
+	if o := (Options{Width: 100}).Resolve("image/gif"); o.Format != FormatPNG {
+		t.Errorf("format par défaut pour un GIF = %q", o.Format)
+	}
+}
+
+func TestResize(t *testing.T) {
+	src := testPNG(t, 400, 200)
+	cases := []struct {
+		opts   Options
+		w, h   int
+		format string
+	}{
+		{Options{Width: 100}, 100, 50, "png"},
+		{Options{Height: 100}, 200, 100, "png"},
+		{Options{Width: 100, Height: 100}, 100, 50, "png"},
+		{Options{Width: 1000}, 400, 200, "png"}, // contain n'agrandit pas
+		{Options{Width: 100, Height: 100, Fit: FitCover}, 100, 100, "png"},
+		{Options{Width: 50, Height: 70, Fit: FitFill, Format: FormatJPEG}, 50, 70, "jpeg"},
+	}
+	for _, c := range cases {
+		o := c.opts.Resolve("image/png")
+		res, err := Resize(src, o)
+		if err != nil {
+			t.Fatalf("%s : %v", o.Key(), err)
+		}
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(res.Data))
+		if err != nil {
+			t.Fatalf("%s : sortie illisible : %v", o.Key(), err)
+		}
+		if cfg.Width != c.w || cfg.Height != c.h || res.Width != c.w || res.Height != c.h || format != c.format {
+			t.Errorf("%s : %dx%d %s, attendu %dx%d %s", o.Key(), cfg.Width, cfg.Height, format, c.w, c.h, c.format)
+		}
+	}
+
+	if _, err := Resize([]byte("<svg/>"), Options{Width: 10}.Resolve("image/svg+xml")); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("source SVG : err = %v", err)
+	}
+}
+
+func TestGeometryBounds(t *testing.T) {
+	// Sans dimension, contain reste borné par MaxDimension
+	_, w, h := geometry(image.Rect(0, 0, 6000, 3000), Options{Fit: FitContain})
+	if w != MaxDimension || h != MaxDimension/2 {
+		t.Errorf("contain sans dimension : %dx%d", w, h)
+	}
+	// Un ratio extrême garde une zone source non vide
+	src, _, _ := geometry(image.Rect(0, 0, 1, 5000), Options{Width: MaxDimension, Height: 1, Fit: FitCover})
+	if src.Dx() < 1 || src.Dy() < 1 {
+		t.Errorf("cover d'une source 1x5000 : zone %v", src)
+	}
+}
+
+// Une photo orientée par EXIF (6 : quart de tour horaire) est redressée
+func TestResizeAppliesOrientation(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 40, 20))
+	for y := 0; y < 20; y++ {
+		for x := 20; x < 40; x++ {
+			img.SetGray(x, y, color.Gray{Y: 255}) // moitié droite blanche
+		}
+	}
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, img, nil)
+	src := withSegments(buf.Bytes(), orientationExif(6))
+
+	res, err := Resize(src, Options{Width: 20}.Resolve("image/jpeg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, _, err := image.Decode(bytes.NewReader(res.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := out.Bounds(); b.Dx() != 20 || b.Dy() != 40 {
+		t.Fatalf("dimensions %dx%d, attendu 20x40", b.Dx(), b.Dy())
+	}
+	// La gauche de la source passe en haut
+	top, _, _, _ := out.At(10, 5).RGBA()
+	bottom, _, _, _ := out.At(10, 35).RGBA()
+	if top > 0x4000 || bottom < 0xC000 {
+		t.Errorf("haut %#x, bas %#x : orientation non appliquée", top, bottom)
+	}
+}
@@ -0,0 +1,413 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+)
+
+// Metadata décrit une image à partir de ses en-têtes, sans la décoder
+type Metadata struct {
+	Width  int
+	Height int
+	// Orientation EXIF (1 à 8, 1 : normale)
+	Orientation int
+	ColorModel  string
+}
+
+// ReadMetadata lit les dimensions, le modèle de couleur et l'orientation EXIF
+func ReadMetadata(data []byte) (*Metadata, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	m := &Metadata{
+		Width:       cfg.Width,
+		Height:      cfg.Height,
+		Orientation: 1,
+		ColorModel:  colorModelName(cfg.ColorModel),
+	}
+	if tiff := exifPayload(data); tiff != nil {
+		if o := exifOrientation(tiff); o >= 1 && o <= 8 {
+			m.Orientation = o
+		}
+	}
+	return m, nil
+}
+
+func colorModelName(m color.Model) string {
+	switch m {
+	case color.RGBAModel, color.NRGBAModel:
+		return "rgba"
+	case color.RGBA64Model, color.NRGBA64Model:
+		return "rgba64"
+	case color.GrayModel:
+		return "gray"
+	case color.Gray16Model:
+		return "gray16"
+	case color.AlphaModel, color.Alpha16Model:
+		return "alpha"
+	case color.YCbCrModel:
+		return "ycbcr"
+	case color.NYCbCrAModel:
+		return "ycbcra"
+	case color.CMYKModel:
+		return "cmyk"
+	}
+	if _, ok := m.(color.Palette); ok {
+		return "paletted"
+	}
+	return "unknown"
+}
+
+var (
+	jpegExifPrefix = []byte("Exif\x00\x00")
+	pngSignature   = []byte("\x89PNG\r\n\x1a\n")
+)
+
+// exifPayload renvoie le bloc TIFF des données EXIF (JPEG APP1, PNG eXIf ou
+// WebP EXIF), ou nil
+func exifPayload(data []byte) []byte {
+	var payload []byte
+	switch {
+	case isJPEG(data):
+		walkJPEG(data, func(marker byte, segment []byte) bool {
+			if marker == 0xE1 && len(segment) >= 4+len(jpegExifPrefix) && bytes.HasPrefix(segment[4:], jpegExifPrefix) {
+				payload = segment[4+len(jpegExifPrefix):]
+				return false
+			}
+			return true
+		})
+	case bytes.HasPrefix(data, pngSignature):
+		walkPNG(data, func(typ string, chunk []byte) bool {
+			if typ == "eXIf" {
+				// walkPNG garantit au moins 12 octets (longueur, type, CRC)
+				payload = chunk[8 : len(chunk)-4]
+				return false
+			}
+			return true
+		})
+	case isWebP(data):
+		walkWebP(data, func(fourcc string, chunk []byte) bool {
+			if fourcc == "EXIF" {
+				payload = bytes.TrimPrefix(chunk[8:], jpegExifPrefix)
+				return false
+			}
+			return true
+		})
+	}
+	return payload
+}
+
+// exifOrientation lit le tag 0x0112 de l'IFD0 d'un bloc TIFF (0 si absent)
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+	return 0
+}
+
+// orientationExif construit un bloc APP1 EXIF ne contenant que l'orientation
+func orientationExif(orientation int) []byte {
+	tiff := []byte{
+		'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08, // en-tête, IFD0 à l'offset 8
+		0x00, 0x01, // une entrée
+		0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, // Orientation, SHORT, 1 valeur
+		0x00, byte(orientation), 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, // pas d'IFD suivant
+	}
+	payload := append(append([]byte{}, jpegExifPrefix...), tiff...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// ErrMalformed signale une image dont la structure ne peut pas être parcourue
+var ErrMalformed = errors.New("imaging: malformed image")
+
+// StripMetadata retire sans perte les métadonnées EXIF, XMP, IPTC et les
+// commentaires d'une image JPEG, PNG, WebP ou GIF. L'orientation d'un JPEG
+// est conservée pour que l'image reste affichée dans le bon sens. Les autres
+// formats sont renvoyés tels quels.
+func StripMetadata(data []byte) ([]byte, error) {
+	switch {
+	case isJPEG(data):
+		return stripJPEG(data)
+	case bytes.HasPrefix(data, pngSignature):
+		return stripPNG(data)
+	case isWebP(data):
+		return stripWebP(data)
+	case isGIF(data):
+		return stripGIF(data)
+	}
+	return data, nil
+}
+
+// DetectMimeType reconnaît une image décodable à sa signature, quel que
+// soit le type annoncé par le client ; "" sinon
+func DetectMimeType(data []byte) string {
+	switch {
+	case isJPEG(data):
+		return "image/jpeg"
+	case bytes.HasPrefix(data, pngSignature):
+		return "image/png"
+	case isGIF(data):
+		return "image/gif"
+	case isWebP(data):
+		return "image/webp"
+	}
+	return ""
+}
+
+func isJPEG(data []byte) bool {
+	return len(data) > 4 && data[0] == 0xFF && data[1] == 0xD8
+}
+
+func isGIF(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("GIF87a")) || bytes.HasPrefix(data, []byte("GIF89a"))
+}
+
+func isWebP(data []byte) bool {
+	return len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP"
+}
+
+// walkJPEG parcourt les segments d'en-tête (jusqu'à SOS exclu). segment
+// contient le marqueur et la longueur. Renvoie l'offset du premier octet non
+// parcouru.
+func walkJPEG(data []byte, fn func(marker byte, segment []byte) bool) (int, error) {
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return pos, ErrMalformed
+		}
+		marker := data[pos+1]
+		if marker == 0xFF { // octet de remplissage
+			pos++
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 { // début des données ou fin d'image
+			return pos, nil
+		}
+		// La longueur compte ses deux octets : moins de 2 est invalide
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return pos, ErrMalformed
+		}
+		if !fn(marker, data[pos:end]) {
+			return end, nil
+		}
+		pos = end
+	}
+	return pos, ErrMalformed
+}
+
+func stripJPEG(data []byte) ([]byte, error) {
+	orientation := 0
+	if tiff := exifPayload(data); tiff != nil {
+		orientation = exifOrientation(tiff)
+	}
+
+	out := append(make([]byte, 0, len(data)), data[:2]...)
+	inserted := false
+	rest, err := walkJPEG(data, func(marker byte, segment []byte) bool {
+		switch marker {
+		case 0xE1, 0xED, 0xFE: // APP1 (EXIF, XMP), APP13 (IPTC), COM
+			if !inserted && orientation > 1 && orientation <= 8 {
+				out = append(out, orientationExif(orientation)...)
+				inserted = true
+			}
+		default:
+			out = append(out, segment...)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(out, data[rest:]...), nil
+}
+
+// walkPNG parcourt les chunks ; chunk contient longueur, type, données et CRC
+func walkPNG(data []byte, fn func(typ string, chunk []byte) bool) error {
+	pos := len(pngSignature)
+	for pos < len(data) {
+		if pos+12 > len(data) {
+			return ErrMalformed
+		}
+		end := pos + 12 + int(binary.BigEndian.Uint32(data[pos:]))
+		if end > len(data) {
+			return ErrMalformed
+		}
+		if !fn(string(data[pos+4:pos+8]), data[pos:end]) {
+			return nil
+		}
+		pos = end
+	}
+	return nil
+}
+
+func stripPNG(data []byte) ([]byte, error) {
+	out := append(make([]byte, 0, len(data)), pngSignature...)
+	err := walkPNG(data, func(typ string, chunk []byte) bool {
+		switch typ {
+		case "eXIf", "tEXt", "zTXt", "iTXt", "tIME": // l'XMP est stocké dans iTXt
+		default:
+			out = append(out, chunk...)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// walkWebP parcourt les chunks RIFF ; chunk contient l'en-tête, les données
+// et l'éventuel octet de bourrage
+func walkWebP(data []byte, fn func(fourcc string, chunk []byte) bool) error {
+	pos := 12
+	for pos < len(data) {
+		if pos+8 > len(data) {
+			return ErrMalformed
+		}
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		end := pos + 8 + size + size%2
+		if end > len(data) {
+			return ErrMalformed
+		}
+		if !fn(string(data[pos:pos+4]), data[pos:end]) {
+			return nil
+		}
+		pos = end
+	}
+	return nil
+}
+
+func stripWebP(data []byte) ([]byte, error) {
+	out := append(make([]byte, 0, len(data)), data[:12]...)
+	malformed := false
+	err := walkWebP(data, func(fourcc string, chunk []byte) bool {
+		switch fourcc {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			if len(chunk) < 9 {
+				malformed = true
+				return false
+			}
+			// Retirer les drapeaux EXIF (0x08) et XMP (0x04)
+			vp8x := append([]byte{}, chunk...)
+			vp8x[8] &^= 0x08 | 0x04
+			out = append(out, vp8x...)
+		default:
+			out = append(out, chunk...)
+		}
+		return true
+	})
+	if err == nil && malformed {
+		err = ErrMalformed
+	}
+	if err != nil {
+		return nil, err
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, nil
+}
+
+// stripGIF retire les extensions de commentaire et d'application (l'XMP en
+// est une), sauf celle qui porte le nombre de boucles d'une animation
+func stripGIF(data []byte) ([]byte, error) {
+	if len(data) < 13 {
+		return nil, ErrMalformed
+	}
+	// En-tête, descripteur d'écran et palette globale éventuelle
+	pos := 13
+	if data[10]&0x80 != 0 {
+		pos += 3 << (data[10]&0x07 + 1)
+	}
+	if pos > len(data) {
+		return nil, ErrMalformed
+	}
+	out := append(make([]byte, 0, len(data)), data[:pos]...)
+	for pos < len(data) {
+		start := pos
+		switch data[pos] {
+		case 0x3B: // fin du fichier
+			return append(out, 0x3B), nil
+		case 0x21: // extension : étiquette puis sous-blocs
+			if pos+2 > len(data) {
+				return nil, ErrMalformed
+			}
+			end, err := gifSubBlocks(data, pos+2)
+			if err != nil {
+				return nil, err
+			}
+			switch label := data[pos+1]; {
+			case label == 0xFE:
+			case label == 0xFF && !bytes.HasPrefix(data[pos+2:end], []byte("\x0bNETSCAPE2.0")) &&
+				!bytes.HasPrefix(data[pos+2:end], []byte("\x0bANIMEXTS1.0")):
+			default:
+				out = append(out, data[start:end]...)
+			}
+			pos = end
+		case 0x2C: // image : descripteur, palette locale, données LZW
+			if pos+10 > len(data) {
+				return nil, ErrMalformed
+			}
+			flags := data[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << (flags&0x07 + 1)
+			}
+			// Octet de taille minimale du code LZW
+			end, err := gifSubBlocks(data, pos+1)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, data[start:end]...)
+			pos = end
+		default:
+			return nil, ErrMalformed
+		}
+	}
+	// Fichier sans bloc de fin : les décodeurs le tolèrent
+	return out, nil
+}
+
+// gifSubBlocks renvoie l'offset qui suit la suite de sous-blocs commençant
+// à pos, terminée par un bloc vide
+func gifSubBlocks(data []byte, pos int) (int, error) {
+	for pos < len(data) {
+		n := int(data[pos])
+		pos += 1 + n
+		if n == 0 {
+			return pos, nil
+		}
+	}
+	return 0, ErrMalformed
+}
@@ -0,0 +1,147 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// withSegments insère des segments juste après le SOI d'un JPEG
+func withSegments(jpg []byte, segments ...[]byte) []byte {
+	out := append([]byte{}, jpg[:2]...)
+	for _, s := range segments {
+		out = append(out, s...)
+	}
+	return append(out, jpg[2:]...)
+}
+
+func appSegment(marker byte, payload string) []byte {
+	seg := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+	return append(seg, payload...)
+}
+
+func TestJPEGMetadataAndStrip(t *testing.T) {
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 30, 20)), nil)
+	xmp := appSegment(0xE1, "http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta>GPS</x:xmpmeta>")
+	src := withSegments(buf.Bytes(), orientationExif(6), xmp, appSegment(0xFE, "commentaire"))
+
+	m, err := ReadMetadata(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *m != (Metadata{Width: 30, Height: 20, Orientation: 6, ColorModel: "gray"}) {
+		t.Errorf("ReadMetadata = %+v", m)
+	}
+
+	stripped, err := StripMetadata(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(stripped, []byte("xmpmeta")) || bytes.Contains(stripped, []byte("commentaire")) {
+		t.Error("XMP ou commentaire encore présent")
+	}
+	if m, err := ReadMetadata(stripped); err != nil || m.Orientation != 6 {
+		t.Errorf("après nettoyage : %+v, %v (l'orientation doit être conservée)", m, err)
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(stripped)); err != nil {
+		t.Errorf("JPEG nettoyé illisible : %v", err)
+	}
+}
+
+func TestPNGStrip(t *testing.T) {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 4, 4)))
+	src := buf.Bytes()
+
+	// Chunk tEXt inséré après IHDR (8 + 25 octets)
+	text := []byte("tEXtAuthor\x00secret")
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(text)-4))
+	chunk = append(chunk, text...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(text))
+	src = append(append(append([]byte{}, src[:33]...), chunk...), src[33:]...)
+
+	stripped, err := StripMetadata(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(stripped, []byte("secret")) {
+		t.Error("chunk tEXt encore présent")
+	}
+	m, err := ReadMetadata(stripped)
+	if err != nil || m.Width != 4 || m.ColorModel != "rgba" {
+		t.Errorf("PNG nettoyé : %+v, %v", m, err)
+	}
+}
+
+// Un segment de longueur inférieure à 2 ou un chunk VP8X tronqué est rejeté
+// sans panique
+func TestGIFStrip(t *testing.T) {
+	palette := color.Palette{color.Black, color.White}
+	var buf bytes.Buffer
+	err := gif.EncodeAll(&buf, &gif.GIF{
+		Image:     []*image.Paletted{image.NewPaletted(image.Rect(0, 0, 4, 4), palette), image.NewPaletted(image.Rect(0, 0, 4, 4), palette)},
+		Delay:     []int{10, 10},
+		LoopCount: 3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Commentaire et XMP insérés avant le premier bloc d'image
+	encoded := buf.Bytes()
+	frame := bytes.IndexByte(encoded[13+3*2:], 0x2C) + 13 + 3*2
+	comment := append([]byte("\x21\xFE\x06secret"), 0)
+	xmp := append([]byte("\x21\xFF\x0bXMP DataXMP\x08GPS 48N "), 0)
+	src := append(append(append(append([]byte{}, encoded[:frame]...), comment...), xmp...), encoded[frame:]...)
+	if _, err := gif.DecodeAll(bytes.NewReader(src)); err != nil {
+		t.Fatalf("GIF de test illisible : %v", err)
+	}
+
+	out, err := StripMetadata(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(out, []byte("secret")) || bytes.Contains(out, []byte("GPS")) {
+		t.Error("commentaire ou XMP conservés")
+	}
+	g, err := gif.DecodeAll(bytes.NewReader(out))
+	if err != nil || len(g.Image) != 2 || g.LoopCount != 3 {
+		t.Errorf("GIF nettoyé : %d images, boucles %d, %v", len(g.Image), g.LoopCount, err)
+	}
+	if DetectMimeType(out) != "image/gif" {
+		t.Errorf("DetectMimeType = %q", DetectMimeType(out))
+	}
+}
+
+func TestMalformedSegments(t *testing.T) {
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8)), nil)
+	jpg := buf.Bytes()
+	// image/jpeg écrit SOI, DQT puis SOF0 ; l'APP1 de longueur 0 suit SOF0
+	// pour que DecodeConfig aboutisse
+	sof := 2 + 2 + int(binary.BigEndian.Uint16(jpg[4:]))
+	sofEnd := sof + 2 + int(binary.BigEndian.Uint16(jpg[sof+2:]))
+	src := withSegments(jpg[:sofEnd], appSegment(0xE0, "JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00"))
+	src = append(append(src, 0xFF, 0xE1, 0x00, 0x00), jpg[sofEnd:]...)
+
+	m, err := ReadMetadata(src)
+	if err != nil || m.Orientation != 1 {
+		t.Errorf("ReadMetadata = %+v, %v", m, err)
+	}
+	if _, err := StripMetadata(src); err != ErrMalformed {
+		t.Errorf("StripMetadata(JPEG) : err = %v, attendu ErrMalformed", err)
+	}
+
+	webp := []byte("RIFF\x00\x00\x00\x00WEBPVP8X\x00\x00\x00\x00")
+	binary.LittleEndian.PutUint32(webp[4:], uint32(len(webp)-8))
+	if _, err := StripMetadata(webp); err != ErrMalformed {
+		t.Errorf("StripMetadata(WebP) : err = %v, attendu ErrMalformed", err)
+	}
+}
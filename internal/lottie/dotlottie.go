@@ -0,0 +1,218 @@
+package lottie
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// MaxEntrySize borne la taille décompressée d'un fichier de l'archive
+const MaxEntrySize = 64 << 20
+
+// MaxArchiveSize borne la taille décompressée totale des animations
+// validées par OpenArchive
+const MaxArchiveSize = 256 << 20
+
+// ErrNoAnimation est renvoyée pour un identifiant absent de l'archive
+var ErrNoAnimation = errors.New("lottie: animation not found in archive")
+
+// Manifest est le manifest.json d'une archive dotLottie (versions 1 et 2)
+type Manifest struct {
+	Version           string              `json:"version,omitempty"`
+	Generator         string              `json:"generator,omitempty"`
+	Author            string              `json:"author,omitempty"`
+	ActiveAnimationID string              `json:"activeAnimationId,omitempty"`
+	Animations        []ManifestAnimation `json:"animations"`
+	Themes            []ManifestTheme     `json:"themes,omitempty"`
+}
+
+// ManifestAnimation référence une animation de l'archive
+type ManifestAnimation struct {
+	ID string `json:"id"`
+}
+
+// ManifestTheme référence un thème de l'archive (dotLottie 2)
+type ManifestTheme struct {
+	ID         string   `json:"id"`
+	Animations []string `json:"animations,omitempty"`
+}
+
+// Animation est une animation de l'archive et ses métadonnées
+type Animation struct {
+	ID string
+	*Info
+}
+
+// Archive est une archive dotLottie validée
+type Archive struct {
+	Manifest   Manifest
+	Animations []Animation
+	// ImageCount compte les images embarquées dans l'archive
+	ImageCount int
+	files      map[string]*zip.File
+}
+
+// IsArchive indique si data commence comme une archive zip
+func IsArchive(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("PK\x03\x04"))
+}
+
+// OpenArchive lit et valide une archive dotLottie : manifest présent, au
+// moins une animation, sans doublon, et chaque animation est un Lottie valide
+func OpenArchive(data []byte) (*Archive, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	a := &Archive{files: map[string]*zip.File{}}
+	for _, f := range zr.File {
+		a.files[f.Name] = f
+		dir := path.Dir(f.Name)
+		if (dir == "images" || dir == "i") && !f.FileInfo().IsDir() {
+			a.ImageCount++
+		}
+	}
+
+	manifest, err := a.read("manifest.json")
+	if err != nil {
+		return nil, fmt.Errorf("%w: manifest.json: %v", ErrInvalid, err)
+	}
+	if err := json.Unmarshal(manifest, &a.Manifest); err != nil {
+		return nil, fmt.Errorf("%w: manifest.json: %v", ErrInvalid, err)
+	}
+	if len(a.Manifest.Animations) == 0 {
+		return nil, fmt.Errorf("%w: manifest lists no animation", ErrInvalid)
+	}
+
+	seen := map[string]bool{}
+	var total uint64
+	for _, m := range a.Manifest.Animations {
+		if seen[m.ID] {
+			return nil, fmt.Errorf("%w: animation %q listed twice", ErrInvalid, m.ID)
+		}
+		seen[m.ID] = true
+		if f := a.animationFile(m.ID); f != nil {
+			total += f.UncompressedSize64
+		}
+	}
+	// archive/zip refuse de lire au-delà de la taille déclarée d'un fichier :
+	// la somme des tailles déclarées borne donc ce que la validation décompresse
+	if total > MaxArchiveSize {
+		return nil, fmt.Errorf("%w: animations exceed %d bytes", ErrInvalid, MaxArchiveSize)
+	}
+
+	for _, m := range a.Manifest.Animations {
+		content, err := a.Animation(m.ID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: animation %q: %v", ErrInvalid, m.ID, err)
+		}
+		info, err := Parse(content)
+		if err != nil {
+			return nil, fmt.Errorf("animation %q: %w", m.ID, err)
+		}
+		a.Animations = append(a.Animations, Animation{ID: m.ID, Info: info})
+	}
+	return a, nil
+}
+
+// Active renvoie l'animation affichée par défaut
+func (a *Archive) Active() Animation {
+	for _, anim := range a.Animations {
+		if anim.ID == a.Manifest.ActiveAnimationID {
+			return anim
+		}
+	}
+	return a.Animations[0]
+}
+
+// Animation renvoie le JSON de l'animation id (animations/ en version 1,
+// a/ en version 2)
+func (a *Archive) Animation(id string) ([]byte, error) {
+	f := a.animationFile(id)
+	if f == nil {
+		return nil, ErrNoAnimation
+	}
+	return a.read(f.Name)
+}
+
+// animationFile renvoie le fichier de l'animation id, ou nil
+func (a *Archive) animationFile(id string) *zip.File {
+	if id == "" || strings.ContainsAny(id, "/\\") {
+		return nil
+	}
+	for _, dir := range []string{"animations", "a"} {
+		if f, ok := a.files[dir+"/"+id+".json"]; ok {
+			return f
+		}
+	}
+	return nil
+}
+
+func (a *Archive) read(name string) ([]byte, error) {
+	f, ok := a.files[name]
+	if !ok {
+		return nil, errors.New("missing")
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	content, err := io.ReadAll(io.LimitReader(rc, MaxEntrySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > MaxEntrySize {
+		return nil, errors.New("entry too large")
+	}
+	return content, nil
+}
+
+// Pack convertit une animation Lottie JSON en archive dotLottie (version 1)
+// contenant cette seule animation
+func Pack(id string, animation []byte) ([]byte, error) {
+	if _, err := Parse(animation); err != nil {
+		return nil, err
+	}
+	if id == "" || strings.ContainsAny(id, "/\\") {
+		return nil, fmt.Errorf("%w: invalid animation id %q", ErrInvalid, id)
+	}
+
+	manifest, err := json.Marshal(Manifest{
+		Version:           "1",
+		Generator:         "bakiverse-ipfs-service",
+		ActiveAnimationID: id,
+		Animations:        []ManifestAnimation{{ID: id}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, entry := range []struct {
+		name    string
+		content []byte
+	}{
+		{"manifest.json", manifest},
+		{"animations/" + id + ".json", animation},
+	} {
+		w, err := zw.Create(entry.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(entry.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
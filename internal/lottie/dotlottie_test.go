@@ -0,0 +1,108 @@
+package lottie
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func zipOf(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, _ := zw.Create(name)
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestPackAndOpen(t *testing.T) {
+	packed, err := Pack("loader", []byte(valid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsArchive(packed) {
+		t.Fatal("IsArchive = false")
+	}
+	a, err := OpenArchive(packed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Animations) != 1 || a.Active().ID != "loader" || a.Active().Duration != 3 {
+		t.Errorf("archive = %+v", a)
+	}
+	content, err := a.Animation("loader")
+	if err != nil || string(content) != valid {
+		t.Errorf("Animation(loader) = %s, %v", content, err)
+	}
+	if _, err := a.Animation("../manifest"); !errors.Is(err, ErrNoAnimation) {
+		t.Errorf("Animation(../manifest) : err = %v", err)
+	}
+	if _, err := Pack("x", []byte(`{}`)); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Pack d'un JSON non Lottie : err = %v", err)
+	}
+}
+
+func TestOpenArchiveV2(t *testing.T) {
+	a, err := OpenArchive(zipOf(t, map[string]string{
+		"manifest.json": `{"version":"2","activeAnimationId":"night","animations":[{"id":"day"},{"id":"night"}],` +
+			`"themes":[{"id":"dark","animations":["night"]}]}`,
+		"a/day.json":   valid,
+		"a/night.json": valid,
+		"t/dark.json":  `{"rules":[]}`,
+		"i/img_0.png":  "png",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Animations) != 2 || a.Active().ID != "night" || a.ImageCount != 1 || a.Manifest.Themes[0].ID != "dark" {
+		t.Errorf("archive = %+v", a)
+	}
+}
+
+// oversizedArchive déclare cinq animations de MaxEntrySize octets chacune,
+// sans les contenir
+func oversizedArchive(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("manifest.json")
+	w.Write([]byte(`{"animations":[{"id":"a"},{"id":"b"},{"id":"c"},{"id":"d"},{"id":"e"}]}`))
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		w, err := zw.CreateRaw(&zip.FileHeader{Name: "a/" + id + ".json", Method: zip.Store, CompressedSize64: 2, UncompressedSize64: MaxEntrySize})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte("{}"))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestOpenArchiveRejects(t *testing.T) {
+	cases := map[string][]byte{
+		"pas un zip":          []byte(valid),
+		"sans manifest":       zipOf(t, map[string]string{"animations/a.json": valid}),
+		"sans animation":      zipOf(t, map[string]string{"manifest.json": `{"animations":[]}`}),
+		"animation absente":   zipOf(t, map[string]string{"manifest.json": `{"animations":[{"id":"a"}]}`}),
+		"animation invalide":  zipOf(t, map[string]string{"manifest.json": `{"animations":[{"id":"a"}]}`, "animations/a.json": `{}`}),
+		"animation en double": zipOf(t, map[string]string{"manifest.json": `{"animations":[{"id":"a"},{"id":"a"}]}`, "animations/a.json": valid}),
+	}
+	for name, data := range cases {
+		if _, err := OpenArchive(data); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s : err = %v, attendu ErrInvalid", name, err)
+		}
+	}
+	// Refusée sur les tailles déclarées, avant toute décompression
+	if _, err := OpenArchive(oversizedArchive(t)); !errors.Is(err, ErrInvalid) || !strings.Contains(err.Error(), "exceed") {
+		t.Errorf("archive trop lourde : err = %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+// Package lottie valide les animations Lottie (format JSON Bodymovin) et en
+// extrait les métadonnées.
+package lottie
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrInvalid est renvoyée pour un contenu qui n'est pas une animation Lottie
+var ErrInvalid = errors.New("lottie: invalid animation")
+
+// Info résume une animation
+type Info struct {
+	Version   string
+	FrameRate float64
+	InPoint   float64
+	OutPoint  float64
+	Width     int
+	Height    int
+	// Duration est la durée en secondes, (op - ip) / fr
+	Duration   float64
+	LayerCount int
+	// AssetCount compte les assets embarqués dans le JSON (images en data URI)
+	AssetCount int
+}
+
+// document reprend les champs de premier niveau d'une animation Bodymovin ;
+// les pointeurs distinguent un champ absent d'une valeur nulle
+type document struct {
+	V      *string            `json:"v"`
+	Fr     *float64           `json:"fr"`
+	Ip     *float64           `json:"ip"`
+	Op     *float64           `json:"op"`
+	W      *float64           `json:"w"`
+	H      *float64           `json:"h"`
+	Layers *[]json.RawMessage `json:"layers"`
+	Assets []struct {
+		P string `json:"p"`
+		E int    `json:"e"`
+	} `json:"assets"`
+}
+
+// Parse valide la structure d'une animation et renvoie ses métadonnées
+func Parse(data []byte) (*Info, error) {
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	var missing []string
+	for name, present := range map[string]bool{
+		"v": doc.V != nil, "fr": doc.Fr != nil, "ip": doc.Ip != nil, "op": doc.Op != nil,
+		"w": doc.W != nil, "h": doc.H != nil, "layers": doc.Layers != nil,
+	} {
+		if !present {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("%w: missing %s", ErrInvalid, strings.Join(missing, ", "))
+	}
+
+	switch {
+	case *doc.Fr <= 0:
+		return nil, fmt.Errorf("%w: fr must be positive", ErrInvalid)
+	case *doc.Op <= *doc.Ip:
+		return nil, fmt.Errorf("%w: op must be greater than ip", ErrInvalid)
+	case *doc.W <= 0 || *doc.H <= 0:
+		return nil, fmt.Errorf("%w: w and h must be positive", ErrInvalid)
+	}
+	for i, raw := range *doc.Layers {
+		var layer struct {
+			Ty *int `json:"ty"`
+		}
+		if err := json.Unmarshal(raw, &layer); err != nil || layer.Ty == nil {
+			return nil, fmt.Errorf("%w: layer %d has no type", ErrInvalid, i)
+		}
+	}
+
+	info := &Info{
+		Version:    *doc.V,
+		FrameRate:  *doc.Fr,
+		InPoint:    *doc.Ip,
+		OutPoint:   *doc.Op,
+		Width:      int(*doc.W),
+		Height:     int(*doc.H),
+		Duration:   (*doc.Op - *doc.Ip) / *doc.Fr,
+		LayerCount: len(*doc.Layers),
+	}
+	for _, a := range doc.Assets {
+		if a.E == 1 || strings.HasPrefix(a.P, "data:") {
+			info.AssetCount++
+		}
+	}
+	return info, nil
+}
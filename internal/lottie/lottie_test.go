@@ -0,0 +1,39 @@
+package lottie
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const valid = `{"v":"5.7.4","fr":30,"ip":0,"op":90,"w":512,"h":256,"nm":"demo",
+	"assets":[{"id":"img_0","p":"data:image/png;base64,AAAA","e":1},{"id":"comp_0","layers":[]},{"id":"img_1","p":"img.png","u":"images/"}],
+	"layers":[{"ty":4,"nm":"shape"},{"ty":0,"refId":"comp_0"}]}`
+
+func TestParse(t *testing.T) {
+	info, err := Parse([]byte(valid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Info{Version: "5.7.4", FrameRate: 30, OutPoint: 90, Width: 512, Height: 256, Duration: 3, LayerCount: 2, AssetCount: 1}
+	if *info != want {
+		t.Errorf("Parse = %+v, attendu %+v", *info, want)
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	cases := map[string]string{
+		`[1,2]`:                                  "",
+		`{"v":"5","fr":30,"ip":0,"op":90,"w":1}`: "missing h, layers",
+		`{"v":"5","fr":0,"ip":0,"op":90,"w":1,"h":1,"layers":[]}`:            "fr must be positive",
+		`{"v":"5","fr":30,"ip":10,"op":10,"w":1,"h":1,"layers":[]}`:          "op must be greater",
+		`{"v":"5","fr":30,"ip":0,"op":90,"w":1,"h":1,"layers":[{"nm":"x"}]}`: "layer 0 has no type",
+		`{"v":"5","fr":30,"ip":0,"op":90,"w":-1,"h":1,"layers":[{"ty":4}]}`:  "w and h",
+	}
+	for input, msg := range cases {
+		_, err := Parse([]byte(input))
+		if !errors.Is(err, ErrInvalid) || !strings.Contains(err.Error(), msg) {
+			t.Errorf("Parse(%s) : err = %v, attendu %q", input, err, msg)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package lottie
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Bornes de la précision des nombres décimaux conservée par Minify
+const (
+	DefaultPrecision = 3
+	MaxPrecision     = 6
+)
+
+// MinifyOptions règle la passe d'optimisation d'une animation
+type MinifyOptions struct {
+	// Precision est le nombre de décimales conservées (0 à MaxPrecision)
+	Precision int
+	// StripEditorKeys retire les clés propres aux éditeurs (meta, mn) et,
+	// en l'absence d'expressions qui pourraient les référencer, nm et ix
+	StripEditorKeys bool
+	// DedupeAssets fusionne les assets identiques et met à jour les refId
+	DedupeAssets bool
+}
+
+// Minify réécrit une animation valide sous une forme plus compacte, sans
+// modifier son rendu
+func Minify(data []byte, opts MinifyOptions) ([]byte, error) {
+	if opts.Precision < 0 || opts.Precision > MaxPrecision {
+		return nil, fmt.Errorf("lottie: precision must be between 0 and %d", MaxPrecision)
+	}
+	if _, err := Parse(data); err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc map[string]interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	scale := math.Pow10(opts.Precision)
+	roundNumbers(doc, scale)
+	if opts.StripEditorKeys {
+		delete(doc, "meta")
+		keys := []string{"mn"}
+		if !hasExpressions(doc) {
+			keys = append(keys, "nm", "ix")
+		}
+		stripKeys(doc, keys)
+	}
+	if opts.DedupeAssets {
+		if err := dedupeAssets(doc); err != nil {
+			return nil, err
+		}
+	}
+
+	out, err := marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := Parse(out); err != nil {
+		return nil, fmt.Errorf("lottie: minified animation is invalid: %v", err)
+	}
+	return out, nil
+}
+
+// marshal encode sans échapper <, > et & ni ajouter de fin de ligne
+func marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// timingKeys sont les clés de cadence et de bornes temporelles (animation et
+// calques), jamais arrondies pour ne pas changer la lecture
+var timingKeys = map[string]bool{"fr": true, "ip": true, "op": true, "st": true}
+
+// roundNumbers arrondit sur place les nombres décimaux ; les entiers et les
+// valeurs des timingKeys sont laissés tels quels
+func roundNumbers(v interface{}, scale float64) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if _, ok := child.(json.Number); ok && timingKeys[k] {
+				continue
+			}
+			v[k] = roundNumbers(child, scale)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = roundNumbers(child, scale)
+		}
+	case json.Number:
+		if !strings.ContainsAny(string(v), ".eE") {
+			return v
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return v
+		}
+		f = math.Round(f*scale) / scale
+		if f == 0 {
+			f = 0 // pas de "-0"
+		}
+		return json.Number(strconv.FormatFloat(f, 'f', -1, 64))
+	}
+	return v
+}
+
+// hasExpressions indique si une propriété porte une expression (clé x de
+// type chaîne), qui peut désigner calques et effets par nom ou par index
+func hasExpressions(v interface{}) bool {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if _, ok := v["x"].(string); ok {
+			return true
+		}
+		for _, child := range v {
+			if hasExpressions(child) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			if hasExpressions(child) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func stripKeys(v interface{}, keys []string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, k := range keys {
+			delete(v, k)
+		}
+		for _, child := range v {
+			stripKeys(child, keys)
+		}
+	case []interface{}:
+		for _, child := range v {
+			stripKeys(child, keys)
+		}
+	}
+}
+
+// dedupeAssets ne garde que le premier de chaque groupe d'assets identiques
+// à l'identifiant près, puis redirige les refId vers lui
+func dedupeAssets(doc map[string]interface{}) error {
+	assets, ok := doc["assets"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	seen := map[string]string{}    // contenu canonique -> id conservé
+	renamed := map[string]string{} // id supprimé -> id conservé
+	kept := make([]interface{}, 0, len(assets))
+	for _, a := range assets {
+		asset, ok := a.(map[string]interface{})
+		id, _ := asset["id"].(string)
+		if !ok || id == "" {
+			kept = append(kept, a)
+			continue
+		}
+		delete(asset, "id")
+		canonical, err := marshal(asset)
+		asset["id"] = id
+		if err != nil {
+			return err
+		}
+		if first, dup := seen[string(canonical)]; dup {
+			renamed[id] = first
+			continue
+		}
+		seen[string(canonical)] = id
+		kept = append(kept, asset)
+	}
+	if len(renamed) == 0 {
+		return nil
+	}
+	doc["assets"] = kept
+	rewriteRefs(doc, renamed)
+	return nil
+}
+
+func rewriteRefs(v interface{}, renamed map[string]string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if ref, ok := v["refId"].(string); ok {
+			if to, ok := renamed[ref]; ok {
+				v["refId"] = to
+			}
+		}
+		for _, child := range v {
+			rewriteRefs(child, renamed)
+		}
+	case []interface{}:
+		for _, child := range v {
+			rewriteRefs(child, renamed)
+		}
+	}
+}
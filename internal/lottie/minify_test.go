@@ -0,0 +1,81 @@
+package lottie
+
+import (
+	"strings"
+	"testing"
+)
+
+const verbose = `{"v":"5.7.4","fr":29.97002997,"ip":0,"op":90,"w":512,"h":256,"nm":"demo",
+	"meta":{"g":"LottieFiles AE 3.0","a":"","k":"","d":"","tc":""},
+	"assets":[{"id":"comp_0","nm":"a","layers":[{"ty":4,"nm":"s","ix":1}]},{"id":"comp_1","nm":"b","layers":[{"ty":4,"nm":"s","ix":1}]}],
+	"layers":[{"ty":4,"nm":"shape","mn":"ADBE Vector","ks":{"o":{"a":0,"k":100,"ix":11},"p":{"a":0,"k":[256.123456,-0.0001,0]}}},
+		{"ty":0,"refId":"comp_0","ind":1},{"ty":0,"refId":"comp_1","ind":2}]}`
+
+func TestMinify(t *testing.T) {
+	out, err := Minify([]byte(verbose), MinifyOptions{Precision: 3, StripEditorKeys: true, DedupeAssets: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(out)
+	for _, want := range []string{`"fr":29.97002997`, `[256.123,0,0]`, `"k":100`, `"refId":"comp_0","ty":0}]`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%q absent de %s", want, got)
+		}
+	}
+	for _, gone := range []string{`"meta"`, `"mn"`, `"nm"`, `"ix"`, `comp_1`, `-0`} {
+		if strings.Contains(got, gone) {
+			t.Errorf("%q toujours présent dans %s", gone, got)
+		}
+	}
+	if len(out) >= len(verbose) {
+		t.Errorf("taille %d, attendu moins de %d", len(out), len(verbose))
+	}
+
+	info, err := Parse(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.LayerCount != 3 || info.Width != 512 {
+		t.Errorf("métadonnées modifiées : %+v", *info)
+	}
+}
+
+// Les clés de cadence et de bornes temporelles ne sont pas arrondies
+func TestMinifyKeepsTiming(t *testing.T) {
+	input := `{"v":"5","fr":23.976,"ip":0.5,"op":90.5,"w":1,"h":1,"layers":[{"ty":4,"ip":0.5,"op":90.5,"st":-0.5,"ks":{"r":{"a":0,"k":12.6}}}]}`
+	out, err := Minify([]byte(input), MinifyOptions{Precision: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(out)
+	for _, want := range []string{`"fr":23.976`, `"ip":0.5`, `"op":90.5`, `"st":-0.5`, `"k":13`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%q absent de %s", want, got)
+		}
+	}
+}
+
+func TestMinifyKeepsNamesUsedByExpressions(t *testing.T) {
+	input := `{"v":"5","fr":30,"ip":0,"op":90,"w":1,"h":1,"layers":[
+		{"ty":4,"nm":"target","mn":"m","ks":{"o":{"a":0,"k":50,"ix":11,"x":"time > 1 && thisComp.layer(\"target\").opacity"}}}]}`
+	out, err := Minify([]byte(input), MinifyOptions{Precision: 2, StripEditorKeys: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(out)
+	if !strings.Contains(got, `"nm":"target"`) || !strings.Contains(got, `"ix":11`) || strings.Contains(got, `"mn"`) {
+		t.Errorf("Minify = %s", got)
+	}
+	if !strings.Contains(got, `time > 1 && thisComp`) {
+		t.Errorf("expression échappée : %s", got)
+	}
+}
+
+func TestMinifyRejects(t *testing.T) {
+	if _, err := Minify([]byte(valid), MinifyOptions{Precision: MaxPrecision + 1}); err == nil {
+		t.Error("précision hors bornes acceptée")
+	}
+	if _, err := Minify([]byte(`{"v":"5"}`), MinifyOptions{Precision: 3}); err == nil {
+		t.Error("animation invalide acceptée")
+	}
+}
@@ -0,0 +1,255 @@
+// Package markdown rend le Markdown des documents (CommonMark et GFM) en HTML
+// assaini, avec des ancres sur les titres, une table des matières et la
+// réécriture des liens vers les documents et les fichiers IPFS.
+package markdown
+
+import (
+	"bytes"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+)
+
+// Heading est une entrée de la table des matières
+type Heading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	ID    string `json:"id"`
+}
+
+// Rendered est le rendu d'un document
+type Rendered struct {
+	HTML string
+	TOC  []Heading
+}
+
+// Options précise la réécriture des liens et des images
+type Options struct {
+	// Link réécrit une destination sans schéma (chemin relatif ou absolu) ;
+	// false la laisse inchangée
+	Link func(dest string) (string, bool)
+	// FileURL renvoie l'URL d'affichage du fichier IPFS cid
+	FileURL func(cid string) string
+}
+
+// Le HTML brut du source est conservé puis assaini avec le reste du rendu.
+// L'alignement des colonnes passe par l'attribut align, que l'assainisseur
+// sait filtrer (contrairement à style).
+var md = goldmark.New(
+	goldmark.WithExtensions(
+		extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+		extension.Strikethrough,
+		extension.Linkify,
+		extension.TaskList,
+	),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	goldmark.WithRendererOptions(html.WithUnsafe()),
+)
+
+var policy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("id").OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	p.AllowAttrs("align").Matching(regexp.MustCompile(`^(left|center|right)$`)).OnElements("th", "td")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#-]+$`)).OnElements("code")
+	// Listes de tâches GFM
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	return p
+}()
+
+// cidPattern reconnaît une référence réduite à un CID (v0 ou v1 en base32)
+var cidPattern = regexp.MustCompile(`^(Qm[1-9A-HJ-NP-Za-km-z]{44}|baf[a-z0-9]{40,})$`)
+
+// Render rend src en HTML assaini
+func Render(src []byte, opts Options) (*Rendered, error) {
+	ctx := parser.NewContext(parser.WithIDs(&headingIDs{seen: map[string]bool{}}))
+	doc := md.Parser().Parse(text.NewReader(src), parser.WithContext(ctx))
+
+	out := &Rendered{TOC: []Heading{}}
+	err := ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Heading:
+			id, _ := n.AttributeString("id")
+			idBytes, _ := id.([]byte)
+			out.TOC = append(out.TOC, Heading{Level: n.Level, Text: plainText(n, src), ID: string(idBytes)})
+		case *ast.Link:
+			n.Destination = opts.rewrite(n.Destination)
+		case *ast.Image:
+			n.Destination = opts.rewrite(n.Destination)
+		}
+		return ast.WalkContinue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := md.Renderer().Render(&buf, src, doc); err != nil {
+		return nil, err
+	}
+	out.HTML = policy.Sanitize(buf.String())
+	return out, nil
+}
+
+// PlainText extrait le texte de src pour l'indexation : sans balisage ni
+// HTML brut, un bloc par ligne, blocs de code compris
+func PlainText(src []byte) string {
+	var sb strings.Builder
+	doc := md.Parser().Parse(text.NewReader(src))
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			if n.Type() == ast.TypeBlock && sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+				sb.WriteByte('\n')
+			}
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.HTMLBlock, *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		case *ast.CodeBlock, *ast.FencedCodeBlock:
+			lines := n.Lines()
+			for i := 0; i < lines.Len(); i++ {
+				seg := lines.At(i)
+				sb.Write(seg.Value(src))
+			}
+		case *ast.Text:
+			sb.Write(n.Segment.Value(src))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				sb.WriteByte(' ')
+			}
+		case *ast.String:
+			sb.Write(n.Value)
+		case *ast.AutoLink:
+			sb.Write(n.Label(src))
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(sb.String())
+}
+
+// rewrite résout les références IPFS puis les liens sans schéma ; les
+// ancres internes (#...) restent telles quelles
+func (o Options) rewrite(dest []byte) []byte {
+	s := string(dest)
+	if cid, ok := ipfsRef(s); ok && o.FileURL != nil {
+		return []byte(o.FileURL(cid))
+	}
+	if o.Link == nil || s == "" || strings.HasPrefix(s, "#") {
+		return dest
+	}
+	if u, err := url.Parse(s); err != nil || u.Scheme != "" || u.Host != "" {
+		return dest
+	}
+	if rewritten, ok := o.Link(s); ok {
+		return []byte(rewritten)
+	}
+	return dest
+}
+
+// DocLinks renvoie une fonction Options.Link qui résout un lien relatif au
+// dossier du document de chemin current, ou absolu, en chemin de document,
+// avec ou sans extension .md ; target donne l'URL du document de ce chemin,
+// ou false s'il n'existe pas. La requête et l'ancre sont conservées.
+func DocLinks(current string, target func(path string) (string, bool)) func(string) (string, bool) {
+	return func(dest string) (string, bool) {
+		u, err := url.Parse(dest)
+		if err != nil || u.Path == "" {
+			return "", false
+		}
+		p := u.Path
+		if !strings.HasPrefix(p, "/") {
+			p = path.Join(path.Dir("/"+strings.TrimPrefix(current, "/")), p)
+		}
+		p = path.Clean(p)
+		for _, candidate := range []string{p, strings.TrimSuffix(p, ".md")} {
+			if link, ok := target(candidate); ok {
+				if u.RawQuery != "" {
+					link += "?" + u.RawQuery
+				}
+				if u.Fragment != "" {
+					link += "#" + u.EscapedFragment()
+				}
+				return link, true
+			}
+		}
+		return "", false
+	}
+}
+
+// ipfsRef extrait le CID d'une référence ipfs://<cid> ou réduite à un CID
+func ipfsRef(s string) (string, bool) {
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "ipfs://"), "/")
+	return s, cidPattern.MatchString(s)
+}
+
+// headingIDs génère les ancres des titres : minuscules, lettres accentuées
+// conservées, mots séparés par des tirets, suffixe -1, -2... en cas de doublon
+type headingIDs struct {
+	seen map[string]bool
+}
+
+func (g *headingIDs) Generate(value []byte, kind ast.NodeKind) []byte {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(string(value)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			if dash && sb.Len() > 0 {
+				sb.WriteByte('-')
+			}
+			dash = false
+			sb.WriteRune(r)
+		case unicode.IsSpace(r) || r == '-':
+			dash = true
+		}
+	}
+	base := sb.String()
+	if base == "" {
+		base = "section"
+	}
+	id := base
+	for i := 1; g.seen[id]; i++ {
+		id = base + "-" + strconv.Itoa(i)
+	}
+	g.seen[id] = true
+	return []byte(id)
+}
+
+func (g *headingIDs) Put(value []byte) {
+	g.seen[string(value)] = true
+}
+
+// plainText concatène le texte d'un nœud, sans balisage
+func plainText(n ast.Node, src []byte) string {
+	var sb strings.Builder
+	ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch c := c.(type) {
+		case *ast.Text:
+			sb.Write(c.Segment.Value(src))
+			if c.SoftLineBreak() {
+				sb.WriteByte(' ')
+			}
+		case *ast.String:
+			sb.Write(c.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return sb.String()
+}
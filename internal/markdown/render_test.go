@@ -0,0 +1,110 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	src := "# Guide\n\n## Installation\n\nVoir [la FAQ](faq.md#top), [le site](https://example.com) et [plus bas](#usage).\n\n" +
+		"![logo](ipfs://bafy0123456789abcdef0123456789abcdef01234567)\n\n" +
+		"## Usage\n\n<script>alert(1)</script><em onclick=\"x()\">ok</em>\n\n" +
+		"| a | b |\n|---|---|\n| 1 | 2 |\n\n- [x] fait\n\n```go\nfunc main() {}\n```\n"
+	opts := Options{
+		Link: func(dest string) (string, bool) {
+			if strings.HasPrefix(dest, "faq.md") {
+				return "/guides/faq" + strings.TrimPrefix(dest, "faq.md"), true
+			}
+			return "", false
+		},
+		FileURL: func(cid string) string { return "/v1/files/" + cid + "/display" },
+	}
+	out, err := Render([]byte(src), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`<h2 id="installation">Installation</h2>`,
+		`href="/guides/faq#top"`,
+		`href="https://example.com"`,
+		`href="#usage"`,
+		`src="/v1/files/bafy0123456789abcdef0123456789abcdef01234567/display"`,
+		`<em>ok</em>`,
+		`<table>`,
+		`type="checkbox"`,
+		`class="language-go"`,
+	} {
+		if !strings.Contains(out.HTML, want) {
+			t.Errorf("HTML sans %q :\n%s", want, out.HTML)
+		}
+	}
+	for _, unwanted := range []string{"<script", "onclick"} {
+		if strings.Contains(out.HTML, unwanted) {
+			t.Errorf("HTML non assaini (%q) :\n%s", unwanted, out.HTML)
+		}
+	}
+
+	want := []Heading{{1, "Guide", "guide"}, {2, "Installation", "installation"}, {2, "Usage", "usage"}}
+	if len(out.TOC) != len(want) {
+		t.Fatalf("TOC = %+v", out.TOC)
+	}
+	for i := range want {
+		if out.TOC[i] != want[i] {
+			t.Errorf("TOC[%d] = %+v, attendu %+v", i, out.TOC[i], want[i])
+		}
+	}
+}
+
+func TestHeadingIDs(t *testing.T) {
+	out, err := Render([]byte("## Notes\n\n## Notes\n\n## Été & co\n\n## ???\n"), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, h := range out.TOC {
+		ids = append(ids, h.ID)
+	}
+	if got := strings.Join(ids, " "); got != "notes notes-1 été-co section" {
+		t.Errorf("ancres = %s", got)
+	}
+}
+
+func TestTableAlignment(t *testing.T) {
+	out, err := Render([]byte("| a | b |\n|:--|--:|\n| 1 | 2 |\n"), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.HTML, `<th align="left">a</th>`) || !strings.Contains(out.HTML, `<td align="right">2</td>`) {
+		t.Errorf("alignement perdu :\n%s", out.HTML)
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	src := "# Titre\n\nDu **gras** et un [lien](https://example.com).\n<div>brut</div>\n\n```sh\nmake install\n```\n\n- un\n- deux\n"
+	want := "Titre\nDu gras et un lien.\nmake install\nun\ndeux"
+	if got := PlainText([]byte(src)); got != want {
+		t.Errorf("PlainText = %q, attendu %q", got, want)
+	}
+}
+
+func TestDocLinks(t *testing.T) {
+	docs := map[string]string{"/guides/faq": "faq.html", "/index": "home.html"}
+	link := DocLinks("/guides/setup", func(p string) (string, bool) {
+		url, ok := docs[p]
+		return url, ok
+	})
+	for dest, want := range map[string]string{
+		"faq.md#compte":     "faq.html#compte",
+		"./faq?v=2":         "faq.html?v=2",
+		"../index":          "home.html",
+		"/guides/faq.md":    "faq.html",
+		"setup/install.md":  "",
+		"../../../index.md": "home.html",
+	} {
+		got, ok := link(dest)
+		if ok != (want != "") || got != want {
+			t.Errorf("lien %q = %q, %v ; attendu %q", dest, got, ok, want)
+		}
+	}
+}
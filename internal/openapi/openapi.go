@@ -0,0 +1,29 @@
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// Spec est le document OpenAPI 3 décrivant toutes les routes du service
+//
+//go:embed openapi.json
+var Spec []byte
+
+// uiPage affiche la spec sans ressource externe : la documentation reste
+// lisible hors ligne
+//
+//go:embed ui.html
+var uiPage []byte
+
+// SpecHandler sert le document OpenAPI
+func SpecHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(Spec)
+}
+
+// UIHandler sert la page qui affiche /openapi.json
+func UIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(uiPage)
+}
@@ -0,0 +1,90 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/handlers"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/openapi"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/router"
+)
+
+type operation struct {
+	OperationID string `json:"operationId"`
+	Deprecated  bool   `json:"deprecated"`
+}
+
+type document struct {
+	OpenAPI string                          `json:"openapi"`
+	Paths   map[string]map[string]operation `json:"paths"`
+}
+
+func loadSpec(t *testing.T) document {
+	t.Helper()
+	var doc document
+	if err := json.Unmarshal(openapi.Spec, &doc); err != nil {
+		t.Fatalf("openapi.json invalide : %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Fatalf("version OpenAPI inattendue : %q", doc.OpenAPI)
+	}
+	return doc
+}
+
+// specPath convertit un motif du mux en chemin OpenAPI ("/{$}" devient "/")
+func specPath(path string) string {
+	if p := strings.TrimSuffix(path, "{$}"); p != path {
+		return p
+	}
+	return path
+}
+
+func TestEveryRouteIsDescribed(t *testing.T) {
+	doc := loadSpec(t)
+	for _, rt := range router.Routes(&handler.Handler{}) {
+		op, ok := doc.Paths[specPath(rt.Path)][strings.ToLower(rt.Method)]
+		if !ok {
+			t.Errorf("%s n'est pas décrite dans openapi.json", rt.Pattern())
+			continue
+		}
+		if op.Deprecated != rt.Deprecated() {
+			t.Errorf("%s : deprecated=%v dans la spec, attendu %v", rt.Pattern(), op.Deprecated, rt.Deprecated())
+		}
+	}
+}
+
+func TestEveryOperationIsRouted(t *testing.T) {
+	doc := loadSpec(t)
+	routed := map[string]bool{}
+	for _, rt := range router.Routes(&handler.Handler{}) {
+		routed[strings.ToLower(rt.Method)+" "+specPath(rt.Path)] = true
+	}
+
+	seenIDs := map[string]string{}
+	for path, ops := range doc.Paths {
+		for method, op := range ops {
+			key := method + " " + path
+			if !routed[key] {
+				t.Errorf("%s est décrite dans la spec mais n'est pas routée", key)
+			}
+			if op.OperationID == "" {
+				t.Errorf("%s n'a pas d'operationId", key)
+			} else if other, dup := seenIDs[op.OperationID]; dup {
+				t.Errorf("operationId %q dupliqué (%s et %s)", op.OperationID, other, key)
+			}
+			seenIDs[op.OperationID] = key
+		}
+	}
+}
+
+// La page de documentation doit s'afficher hors ligne
+func TestUIHasNoExternalResource(t *testing.T) {
+	rec := httptest.NewRecorder()
+	openapi.UIHandler(rec, httptest.NewRequest(http.MethodGet, "/api-docs", nil))
+	if page := rec.Body.String(); strings.Contains(page, "http://") || strings.Contains(page, "https://") {
+		t.Errorf("la page charge une ressource externe :\n%s", page)
+	}
+}
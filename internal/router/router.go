@@ -0,0 +1,143 @@
+package router
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/handlers"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/openapi"
+)
+
+// Route décrit une route enregistrée sur le mux
+type Route struct {
+	Method  string
+	Path    string
+	Handler http.HandlerFunc
+	// Successor est renseigné pour les anciennes routes plates : c'est la
+	// route /v1 qui les remplace
+	Successor string
+}
+
+// Pattern renvoie le motif Go 1.22 ("GET /v1/files/{cid}") de la route
+func (rt Route) Pattern() string {
+	return rt.Method + " " + rt.Path
+}
+
+// Deprecated indique si la route est un alias historique
+func (rt Route) Deprecated() bool {
+	return rt.Successor != ""
+}
+
+// Routes renvoie la table complète des routes du service
+func Routes(h *handler.Handler) []Route {
+	return []Route{
+		{Method: http.MethodGet, Path: "/{$}", Handler: func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("Hello, from Baki-IPFS-Service!"))
+		}},
+		{Method: http.MethodGet, Path: "/openapi.json", Handler: openapi.SpecHandler},
+		{Method: http.MethodGet, Path: "/api-docs", Handler: openapi.UIHandler},
+
+		// API v1
+		{Method: http.MethodPost, Path: "/v1/files", Handler: h.UploadFileHandler},
+		{Method: http.MethodGet, Path: "/v1/files", Handler: h.GetPublicFilesHandler},
+		{Method: http.MethodGet, Path: "/v1/files/search", Handler: h.SearchPublicFilesHandler},
+		{Method: http.MethodGet, Path: "/v1/files/{cid}", Handler: h.GetFileByCIDHandler},
+		{Method: http.MethodPatch, Path: "/v1/files/{cid}", Handler: h.UpdateFileDetailsHandler},
+		{Method: http.MethodDelete, Path: "/v1/files/{cid}", Handler: h.DeleteFileHandler},
+		{Method: http.MethodGet, Path: "/v1/files/{cid}/display", Handler: h.DisplayFileByCIDHandler},
+		{Method: http.MethodGet, Path: "/v1/files/{cid}/image", Handler: h.GetImageByCIDHandler},
+		{Method: http.MethodGet, Path: "/v1/files/{cid}/private-image", Handler: h.GetPrivateImageByCIDHandler},
+		{Method: http.MethodGet, Path: "/v1/files/{cid}/lottie", Handler: h.GetLottieFileByCIDHandler},
+		{Method: http.MethodGet, Path: "/v1/files/{cid}/lottie/manifest", Handler: h.GetDotLottieManifestHandler},
+		{Method: http.MethodPost, Path: "/v1/files/{cid}/dotlottie", Handler: h.ConvertToDotLottieHandler},
+		{Method: http.MethodPost, Path: "/v1/files/{cid}/toggle-private", Handler: h.ToggleFilePrivacyHandler},
+		{Method: http.MethodGet, Path: "/v1/account/files", Handler: h.GetAllFilesForAPIKeyHandler},
+
+		{Method: http.MethodGet, Path: "/v1/themes", Handler: h.GetCidThemesHandler},
+		{Method: http.MethodPost, Path: "/v1/themes", Handler: h.AddCidThemeHandler},
+		{Method: http.MethodGet, Path: "/v1/themes/{id}", Handler: h.GetCidThemeHandler},
+		{Method: http.MethodGet, Path: "/v1/themes/{id}/bundle", Handler: h.GetThemeBundleHandler},
+		{Method: http.MethodPut, Path: "/v1/themes/{id}", Handler: h.UpdateCidThemeHandler},
+		{Method: http.MethodDelete, Path: "/v1/themes/{id}", Handler: h.DeleteCidThemeHandler},
+
+		{Method: http.MethodGet, Path: "/v1/docs", Handler: h.GetAllDocsHandler},
+		{Method: http.MethodPost, Path: "/v1/docs", Handler: h.CreateDocHandler},
+		{Method: http.MethodGet, Path: "/v1/docs/tree", Handler: h.GetDocsTreeHandler},
+		{Method: http.MethodGet, Path: "/v1/docs/by-path", Handler: h.GetDocByPathHandler},
+		{Method: http.MethodGet, Path: "/v1/docs/search", Handler: h.SearchDocsHandler},
+		{Method: http.MethodPost, Path: "/v1/docs/reorder", Handler: h.ReorderDocsHandler},
+		{Method: http.MethodPost, Path: "/v1/docs/publish", Handler: h.PublishDocsHandler},
+		{Method: http.MethodPost, Path: "/v1/docs/export", Handler: h.ExportDocsHandler},
+		{Method: http.MethodGet, Path: "/v1/docs/{id}", Handler: h.GetDocHandler},
+		{Method: http.MethodPut, Path: "/v1/docs/{id}", Handler: h.UpdateDocHandler},
+		{Method: http.MethodPatch, Path: "/v1/docs/{id}", Handler: h.UpdateDocHandler},
+		{Method: http.MethodDelete, Path: "/v1/docs/{id}", Handler: h.DeleteDocHandler},
+		{Method: http.MethodPost, Path: "/v1/docs/{id}/move", Handler: h.MoveDocHandler},
+		{Method: http.MethodGet, Path: "/v1/docs/{id}/revisions", Handler: h.GetDocRevisionsHandler},
+		{Method: http.MethodGet, Path: "/v1/docs/{id}/revisions/diff", Handler: h.GetDocDiffHandler},
+		{Method: http.MethodGet, Path: "/v1/docs/{id}/revisions/{version}", Handler: h.GetDocRevisionHandler},
+		{Method: http.MethodPost, Path: "/v1/docs/{id}/revisions/{version}/restore", Handler: h.RestoreDocRevisionHandler},
+
+		// Anciennes routes plates, conservées comme alias dépréciés
+		{Method: http.MethodPost, Path: "/upload", Handler: h.UploadFileHandler, Successor: "/v1/files"},
+		{Method: http.MethodGet, Path: "/public-files", Handler: h.GetPublicFilesHandler, Successor: "/v1/files"},
+		{Method: http.MethodGet, Path: "/private-files", Handler: h.GetAllFilesForAPIKeyHandler, Successor: "/v1/account/files"},
+		{Method: http.MethodGet, Path: "/file", Handler: h.GetFileByCIDHandler, Successor: "/v1/files/{cid}"},
+		{Method: http.MethodGet, Path: "/file/display", Handler: h.DisplayFileByCIDHandler, Successor: "/v1/files/{cid}/display"},
+		{Method: http.MethodGet, Path: "/search-public-files", Handler: h.SearchPublicFilesHandler, Successor: "/v1/files/search"},
+		{Method: http.MethodGet, Path: "/file/img", Handler: h.GetImageByCIDHandler, Successor: "/v1/files/{cid}/image"},
+		{Method: http.MethodGet, Path: "/file/private/img", Handler: h.GetPrivateImageByCIDHandler, Successor: "/v1/files/{cid}/private-image"},
+		{Method: http.MethodPost, Path: "/file/toggle-private", Handler: h.ToggleFilePrivacyHandler, Successor: "/v1/files/{cid}/toggle-private"},
+		{Method: http.MethodGet, Path: "/file/lottie", Handler: h.GetLottieFileByCIDHandler, Successor: "/v1/files/{cid}/lottie"},
+
+		{Method: http.MethodGet, Path: "/cid-themes", Handler: h.GetCidThemesLegacyHandler, Successor: "/v1/themes"},
+		{Method: http.MethodPost, Path: "/cid-themes/add", Handler: h.AddCidThemeHandler, Successor: "/v1/themes"},
+		{Method: http.MethodPut, Path: "/cid-themes/update", Handler: h.UpdateCidThemeHandler, Successor: "/v1/themes/{id}"},
+		{Method: http.MethodDelete, Path: "/cid-themes/delete", Handler: h.DeleteCidThemeHandler, Successor: "/v1/themes/{id}"},
+
+		{Method: http.MethodPost, Path: "/docs/create", Handler: h.CreateDocHandler, Successor: "/v1/docs"},
+		{Method: http.MethodGet, Path: "/docs/get", Handler: h.GetDocHandler, Successor: "/v1/docs/{id}"},
+		{Method: http.MethodPut, Path: "/docs/update", Handler: h.UpdateDocHandler, Successor: "/v1/docs/{id}"},
+		{Method: http.MethodDelete, Path: "/docs/delete", Handler: h.DeleteDocHandler, Successor: "/v1/docs/{id}"},
+		{Method: http.MethodGet, Path: "/docs/all", Handler: h.GetAllDocsHandler, Successor: "/v1/docs"},
+	}
+}
+
+// New construit le mux avec toutes les routes. Les routes étant qualifiées par
+// méthode, le mux répond lui-même 405 avec l'en-tête Allow.
+func New(h *handler.Handler) *http.ServeMux {
+	mux := http.NewServeMux()
+	for _, rt := range Routes(h) {
+		var next http.Handler = rt.Handler
+		if rt.Deprecated() {
+			next = deprecated(rt.Successor, next)
+		}
+		mux.Handle(rt.Pattern(), next)
+	}
+	return mux
+}
+
+// deprecated signale aux clients qu'ils utilisent un alias historique et leur
+// indique la route /v1 qui le remplace
+func deprecated(successor string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+expandSuccessor(successor, r.URL.Query())+`>; rel="successor-version"`)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// expandSuccessor remplace les segments {name} du successeur par les
+// paramètres de requête correspondants de l'ancienne route (?cid=, ?id=)
+func expandSuccessor(successor string, query url.Values) string {
+	segments := strings.Split(successor, "/")
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			if v := query.Get(seg[1 : len(seg)-1]); v != "" {
+				segments[i] = url.PathEscape(v)
+			}
+		}
+	}
+	return strings.Join(segments, "/")
+}
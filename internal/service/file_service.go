@@ -6,13 +6,34 @@ import (
 	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/ipfs/go-ipfs-api"
 )
 
-func UploadFileToIPFS(filePath string) (string, error) {
-	sh := shell.NewShell("localhost:5001") // Connecter à l'API d'IPFS
+// IPFS regroupe les opérations effectuées par les handlers sur le nœud IPFS
+type IPFS interface {
+	UploadFileToIPFS(filePath string) (string, error)
+	// AddDirectoryToIPFS ajoute récursivement un répertoire et renvoie le CID
+	// de sa racine ; ses fichiers se lisent ensuite sous "<cid>/<chemin>"
+	AddDirectoryToIPFS(dirPath string) (string, error)
+	DownloadFileFromIPFS(cid string) ([]byte, error)
+	UnpinFileFromIPFS(cid string) error
+}
+
+// Node est l'implémentation de IPFS reposant sur l'API HTTP d'un nœud Kubo
+type Node struct {
+	Addr string
+}
+
+// NewNode crée un Node pour l'API IPFS à l'adresse donnée (ex. "localhost:5001")
+func NewNode(addr string) *Node {
+	return &Node{Addr: addr}
+}
+
+func (n *Node) UploadFileToIPFS(filePath string) (string, error) {
+	sh := shell.NewShell(n.Addr) // Connecter à l'API d'IPFS
 
 	// Vérifier que IPFS est bien accessible
 	if !sh.IsUp() {
@@ -34,8 +55,18 @@ func UploadFileToIPFS(filePath string) (string, error) {
 	return cid, nil
 }
 
-func DownloadFileFromIPFS(cid string) ([]byte, error) {
-	sh := shell.NewShell("localhost:5001") // Connexion à l'API d'IPFS
+func (n *Node) AddDirectoryToIPFS(dirPath string) (string, error) {
+	sh := shell.NewShell(n.Addr)
+
+	if !sh.IsUp() {
+		return "", fmt.Errorf("IPFS node is not available")
+	}
+
+	return sh.AddDir(dirPath)
+}
+
+func (n *Node) DownloadFileFromIPFS(cid string) ([]byte, error) {
+	sh := shell.NewShell(n.Addr) // Connexion à l'API d'IPFS
 
 	// Vérifier que le nœud IPFS est bien disponible
 	if !sh.IsUp() {
@@ -75,3 +106,52 @@ func DownloadFileFromIPFS(cid string) ([]byte, error) {
 	return nil, fmt.Errorf("IPFS download attempts failed for CID: %s", cid)
 }
 
+func (n *Node) UnpinFileFromIPFS(cid string) error {
+	sh := shell.NewShell(n.Addr) // Connexion à l'API d'IPFS
+
+	if !sh.IsUp() {
+		return fmt.Errorf("IPFS node is not available")
+	}
+
+	return sh.Unpin(cid)
+}
+
+// AddBytes ajoute un contenu généré en mémoire en passant par un fichier
+// temporaire, seule entrée de l'interface IPFS
+func AddBytes(ipfs IPFS, content []byte) (string, error) {
+	tmp, err := os.CreateTemp("", "ipfs-add-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	return ipfs.UploadFileToIPFS(tmp.Name())
+}
+
+// AddFiles ajoute un répertoire généré en mémoire (chemins relatifs, séparés
+// par "/") en passant par un répertoire temporaire, et renvoie son CID
+func AddFiles(ipfs IPFS, files map[string][]byte) (string, error) {
+	dir, err := os.MkdirTemp("", "ipfs-dir-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			return "", err
+		}
+	}
+	return ipfs.AddDirectoryToIPFS(dir)
+}
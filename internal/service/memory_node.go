@@ -0,0 +1,112 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemoryNode est une implémentation de IPFS en mémoire, destinée aux tests et
+// aux environnements sans nœud IPFS. Les CID sont dérivés du SHA-256 du
+// contenu : ils sont stables mais ne sont pas de vrais CID IPFS.
+type MemoryNode struct {
+	mu      sync.RWMutex
+	objects map[string][]byte
+}
+
+func NewMemoryNode() *MemoryNode {
+	return &MemoryNode{objects: map[string][]byte{}}
+}
+
+// Put ajoute directement un contenu et renvoie son CID
+func (m *MemoryNode) Put(content []byte) string {
+	sum := sha256.Sum256(content)
+	cid := "bafy" + hex.EncodeToString(sum[:20])
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[cid] = append([]byte(nil), content...)
+	return cid
+}
+
+// Has indique si le CID est présent (épinglé) sur le nœud
+func (m *MemoryNode) Has(cid string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.objects[cid]
+	return ok
+}
+
+func (m *MemoryNode) UploadFileToIPFS(filePath string) (string, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", err
+	}
+	return m.Put(content), nil
+}
+
+// AddDirectoryToIPFS dérive le CID de la racine des chemins et des CID des
+// fichiers. Chaque fichier reste lisible sous "<racine>/<chemin>" ; la
+// racine elle-même contient la liste des chemins.
+func (m *MemoryNode) AddDirectoryToIPFS(dirPath string) (string, error) {
+	files := map[string][]byte{}
+	err := filepath.WalkDir(dirPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dirPath, path)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		files[filepath.ToSlash(rel)] = content
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var listing strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&listing, "%s %s\n", m.Put(files[name]), name)
+	}
+	root := m.Put([]byte(listing.String()))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name, content := range files {
+		m.objects[root+"/"+name] = content
+	}
+	return root, nil
+}
+
+func (m *MemoryNode) DownloadFileFromIPFS(cid string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	content, ok := m.objects[cid]
+	if !ok {
+		return nil, fmt.Errorf("IPFS object not found: %s", cid)
+	}
+	return append([]byte(nil), content...), nil
+}
+
+func (m *MemoryNode) UnpinFileFromIPFS(cid string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.objects[cid]; !ok {
+		return fmt.Errorf("not pinned: %s", cid)
+	}
+	delete(m.objects, cid)
+	return nil
+}
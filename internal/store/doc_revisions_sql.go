@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+const revisionColumns = "id, doc_id, version, title, path, doc_src, cid, api_key_id, restored_from, status, created_at"
+
+func scanRevision(row interface{ Scan(...interface{}) error }) (DocRevision, error) {
+	var rev DocRevision
+	var apiKeyID, restoredFrom sql.NullInt64
+	err := row.Scan(&rev.ID, &rev.DocID, &rev.Version, &rev.Title, &rev.Path, &rev.DocSrc, &rev.CID, &apiKeyID, &restoredFrom, &rev.Status, &rev.CreatedAt)
+	rev.APIKeyID, rev.RestoredFrom = int(apiKeyID.Int64), int(restoredFrom.Int64)
+	return rev, err
+}
+
+// nextVersion renvoie le numéro de la prochaine révision du document
+func nextVersion(ctx context.Context, q queryer, docID int) (int, error) {
+	var max sql.NullInt64
+	err := q.QueryRowContext(ctx, "SELECT MAX(version) FROM doc_revisions WHERE doc_id = ?", docID).Scan(&max)
+	return int(max.Int64) + 1, err
+}
+
+// insertRevision enregistre le titre, le chemin, le contenu et le statut de
+// d comme révision version du document docID ; un numéro déjà pris par une
+// écriture concurrente donne ErrStale
+func insertRevision(ctx context.Context, tx *sql.Tx, docID, version int, d *Doc, apiKeyID, restoredFrom int) error {
+	_, err := tx.ExecContext(ctx,
+		"INSERT INTO doc_revisions (doc_id, version, title, path, doc_src, cid, api_key_id, restored_from, status) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		docID, version, d.Title, d.Path, d.DocSrc, d.CID, nullID(apiKeyID), nullID(restoredFrom), d.Status)
+	if uniqueViolation(err) {
+		return ErrStale
+	}
+	return err
+}
+
+// nullID traduit l'identifiant 0 en NULL
+func nullID(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+func (r *docRepo) ListRevisions(ctx context.Context, docID int, publishedOnly bool, limit, offset int) ([]DocRevision, int, error) {
+	where, args := "doc_id = ?", []interface{}{docID}
+	if publishedOnly {
+		where, args = where+" AND status = ?", append(args, DocPublished)
+	}
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM doc_revisions WHERE "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	// Sans contenu : la liste sert à choisir les révisions à comparer
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT id, doc_id, version, title, path, '', cid, api_key_id, restored_from, status, created_at FROM doc_revisions WHERE "+where+" ORDER BY version DESC LIMIT ? OFFSET ?",
+		append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	revisions := []DocRevision{}
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, total, rows.Err()
+}
+
+func (r *docRepo) GetRevision(ctx context.Context, docID, version int) (*DocRevision, error) {
+	rev, err := scanRevision(r.db.QueryRowContext(ctx,
+		"SELECT "+revisionColumns+" FROM doc_revisions WHERE doc_id = ? AND version = ?", docID, version))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &rev, nil
+}
+
+func (r *docRepo) FindRevisionByCID(ctx context.Context, docID int, cid string) (*DocRevision, error) {
+	rev, err := scanRevision(r.db.QueryRowContext(ctx,
+		"SELECT "+revisionColumns+" FROM doc_revisions WHERE doc_id = ? AND cid = ? ORDER BY version DESC LIMIT 1", docID, cid))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &rev, nil
+}
+
+func (r *docRepo) RestoreRevision(ctx context.Context, docID, version, apiKeyID int, cid string) (*Doc, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	d, err := scanDoc(tx.QueryRowContext(ctx, "SELECT "+docColumns+" FROM docs WHERE id = ?", docID))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	rev, err := scanRevision(tx.QueryRowContext(ctx,
+		"SELECT "+revisionColumns+" FROM doc_revisions WHERE doc_id = ? AND version = ?", docID, version))
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	// La restauration est une révision comme une autre : l'historique ne
+	// se réécrit pas
+	next, err := nextVersion(ctx, tx, docID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPath(ctx, tx, docID, d.ParentID, rev.Path); err != nil {
+		return nil, err
+	}
+	d.Title, d.Path, d.DocSrc, d.CID, d.Version = rev.Title, rev.Path, rev.DocSrc, cid, float64(next)
+	if err := insertRevision(ctx, tx, docID, next, &d, apiKeyID, version); err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE docs SET title = ?, path = ?, doc_src = ?, cid = ?, version = ?, row_version = row_version + 1 WHERE id = ?",
+		d.Title, d.Path, d.DocSrc, d.CID, d.Version, docID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.Get(ctx, docID)
+}
@@ -0,0 +1,577 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type docRepo struct {
+	db *sql.DB
+}
+
+const docColumns = "id, title, path, doc_src, version, cid, parent_id, position, row_version, status, publish_at, unpublish_at, created_at, updated_at"
+
+// outlineColumns lit les mêmes colonnes que docColumns, contenu vide
+const outlineColumns = "id, title, path, '', version, cid, parent_id, position, row_version, status, publish_at, unpublish_at, created_at, updated_at"
+
+func scanDoc(row interface{ Scan(...interface{}) error }) (Doc, error) {
+	var d Doc
+	var publishAt, unpublishAt sql.NullString
+	err := row.Scan(&d.ID, &d.Title, &d.Path, &d.DocSrc, &d.Version, &d.CID, &d.ParentID, &d.Position, &d.RowVersion,
+		&d.Status, &publishAt, &unpublishAt, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		return d, err
+	}
+	d.IsChildren = d.ParentID != nil
+	if d.PublishAt, err = parseDBTime(publishAt); err != nil {
+		return d, err
+	}
+	d.UnpublishAt, err = parseDBTime(unpublishAt)
+	return d, err
+}
+
+// dbTimeLayout est le format des dates écrites en base, en UTC
+const dbTimeLayout = "2006-01-02 15:04:05"
+
+// dbTime prépare une date facultative pour la base
+func dbTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(dbTimeLayout)
+}
+
+// parseDBTime lit une date facultative : MariaDB la rend au format
+// dbTimeLayout, le driver SQLite en RFC 3339
+func parseDBTime(s sql.NullString) (*time.Time, error) {
+	if !s.Valid || s.String == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s.String)
+	if err != nil {
+		if t, err = time.Parse(dbTimeLayout, s.String); err != nil {
+			return nil, err
+		}
+	}
+	t = t.UTC()
+	return &t, nil
+}
+
+// truncateTime ramène une date facultative à la seconde, précision de la base
+func truncateTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	truncated := t.UTC().Truncate(time.Second)
+	return &truncated
+}
+
+// queryer est satisfait par *sql.DB et *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// parentClause filtre les enfants de parentID (nil : premier niveau)
+func parentClause(parentID *int) (string, []interface{}) {
+	if parentID == nil {
+		return "parent_id IS NULL", nil
+	}
+	return "parent_id = ?", []interface{}{*parentID}
+}
+
+// nextPosition renvoie la position qui place un document après ses frères
+func nextPosition(ctx context.Context, q queryer, parentID *int) (int, error) {
+	where, args := parentClause(parentID)
+	var max sql.NullInt64
+	err := q.QueryRowContext(ctx, "SELECT MAX(position) FROM docs WHERE "+where, args...).Scan(&max)
+	return int(max.Int64) + 1, err
+}
+
+// children renvoie les ID des enfants de parentID, dans l'ordre des frères
+func children(ctx context.Context, q queryer, parentID *int) ([]int, error) {
+	where, args := parentClause(parentID)
+	rows, err := q.QueryContext(ctx, "SELECT id FROM docs WHERE "+where+" ORDER BY position, id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// parentOf renvoie le parent du document id, ou ErrNotFound
+func parentOf(ctx context.Context, q queryer, id int) (*int, error) {
+	var parentID *int
+	if err := q.QueryRowContext(ctx, "SELECT parent_id FROM docs WHERE id = ?", id).Scan(&parentID); err != nil {
+		return nil, notFound(err)
+	}
+	return parentID, nil
+}
+
+// checkParent vérifie que parentID existe et ne descend pas du document id
+func checkParent(ctx context.Context, q queryer, id int, parentID *int) error {
+	if parentID == nil {
+		return nil
+	}
+	if *parentID == id {
+		return ErrCycle
+	}
+	next, err := parentOf(ctx, q, *parentID)
+	if errors.Is(err, ErrNotFound) {
+		return ErrInvalidParent
+	} else if err != nil {
+		return err
+	}
+	// Remontée des ancêtres ; seen arrête un cycle antérieur étranger à id
+	seen := map[int]bool{*parentID: true}
+	for next != nil && !seen[*next] {
+		if *next == id {
+			return ErrCycle
+		}
+		seen[*next] = true
+		if next, err = parentOf(ctx, q, *next); errors.Is(err, ErrNotFound) {
+			return nil // ancêtre disparu : la branche s'arrête là
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renumber donne aux documents ids les positions 1, 2, ... sans toucher
+// ceux qui sont déjà en place
+func renumber(ctx context.Context, tx *sql.Tx, ids []int) error {
+	for i, id := range ids {
+		if _, err := tx.ExecContext(ctx, "UPDATE docs SET position = ?, row_version = row_version + 1 WHERE id = ? AND position <> ?", i+1, id, i+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closeGap renumérote les enfants de parentID après le départ de l'un d'eux
+func closeGap(ctx context.Context, tx *sql.Tx, parentID *int) error {
+	siblings, err := children(ctx, tx, parentID)
+	if err != nil {
+		return err
+	}
+	return renumber(ctx, tx, siblings)
+}
+
+// checkPath vérifie qu'aucun autre enfant de parentID que le document id ne
+// porte ce chemin
+func checkPath(ctx context.Context, q queryer, id int, parentID *int, path string) error {
+	where, args := parentClause(parentID)
+	var taken bool
+	err := q.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM docs WHERE path = ? AND "+where+" AND id <> ?)",
+		append(append([]interface{}{path}, args...), id)...).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrDuplicatePath
+	}
+	return nil
+}
+
+func sameParent(a, b *int) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+func (r *docRepo) Create(ctx context.Context, d *Doc, apiKeyID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkPath(ctx, tx, 0, d.ParentID, d.Path); err != nil {
+		return err
+	}
+	if d.Position == 0 {
+		if d.Position, err = nextPosition(ctx, tx, d.ParentID); err != nil {
+			return err
+		}
+	}
+	d.Version = 1
+	if d.Status == "" {
+		d.Status = DocDraft
+	}
+	d.PublishAt, d.UnpublishAt = truncateTime(d.PublishAt), truncateTime(d.UnpublishAt)
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO docs (title, path, doc_src, version, cid, parent_id, position, status, publish_at, unpublish_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		d.Title, d.Path, d.DocSrc, d.Version, d.CID, d.ParentID, d.Position, d.Status, dbTime(d.PublishAt), dbTime(d.UnpublishAt))
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	if err := insertRevision(ctx, tx, int(id), 1, d, apiKeyID, 0); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	d.ID, d.RowVersion = int(id), 1
+	d.IsChildren = d.ParentID != nil
+	return nil
+}
+
+func (r *docRepo) Get(ctx context.Context, id int) (*Doc, error) {
+	d, err := scanDoc(r.db.QueryRowContext(ctx, "SELECT "+docColumns+" FROM docs WHERE id = ?", id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &d, nil
+}
+
+func (r *docRepo) List(ctx context.Context) ([]Doc, error) {
+	return r.list(ctx, docColumns)
+}
+
+func (r *docRepo) ListOutline(ctx context.Context) ([]Doc, error) {
+	return r.list(ctx, outlineColumns)
+}
+
+func (r *docRepo) list(ctx context.Context, columns string) ([]Doc, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+columns+" FROM docs ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []Doc
+	for rows.Next() {
+		d, err := scanDoc(rows)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, d)
+	}
+	return docs, rows.Err()
+}
+
+func (r *docRepo) FindByPath(ctx context.Context, path string) ([]Doc, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+docColumns+" FROM docs WHERE path = ? ORDER BY id", path)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []Doc
+	for rows.Next() {
+		d, err := scanDoc(rows)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, d)
+	}
+	return docs, rows.Err()
+}
+
+func (r *docRepo) Update(ctx context.Context, d *Doc, apiKeyID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	current, err := scanDoc(tx.QueryRowContext(ctx, "SELECT "+docColumns+" FROM docs WHERE id = ?", d.ID))
+	if err != nil {
+		return notFound(err)
+	}
+	if d.RowVersion != 0 && d.RowVersion != current.RowVersion {
+		return ErrStale
+	}
+	if err := checkParent(ctx, tx, d.ID, d.ParentID); err != nil {
+		return err
+	}
+	if err := checkPath(ctx, tx, d.ID, d.ParentID, d.Path); err != nil {
+		return err
+	}
+	// Position 0 : le document garde sa place, ou passe à la fin de son
+	// nouveau parent
+	if d.Position == 0 {
+		d.Position = current.Position
+		if !sameParent(current.ParentID, d.ParentID) {
+			if d.Position, err = nextPosition(ctx, tx, d.ParentID); err != nil {
+				return err
+			}
+		}
+	}
+	if d.Status == "" {
+		d.Status = current.Status
+	}
+	d.PublishAt, d.UnpublishAt = truncateTime(d.PublishAt), truncateTime(d.UnpublishAt)
+	// La version envoyée est ignorée : seule une révision l'incrémente
+	d.Version = current.Version
+
+	// La condition sur row_version rend la mise à jour atomique face à une
+	// écriture concurrente ; row_version change toujours, MariaDB compte donc
+	// bien la ligne
+	result, err := tx.ExecContext(ctx,
+		"UPDATE docs SET title = ?, path = ?, doc_src = ?, cid = ?, parent_id = ?, position = ?, status = ?, publish_at = ?, unpublish_at = ?, row_version = row_version + 1 WHERE id = ? AND row_version = ?",
+		d.Title, d.Path, d.DocSrc, d.CID, d.ParentID, d.Position, d.Status, dbTime(d.PublishAt), dbTime(d.UnpublishAt), d.ID, current.RowVersion)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrStale
+	}
+
+	if !sameParent(current.ParentID, d.ParentID) {
+		if err := closeGap(ctx, tx, current.ParentID); err != nil {
+			return err
+		}
+	}
+
+	// La ligne est désormais verrouillée par la transaction : une écriture
+	// concurrente ne peut plus prendre le même numéro de révision
+	if d.Title != current.Title || d.Path != current.Path || d.DocSrc != current.DocSrc {
+		version, err := nextVersion(ctx, tx, d.ID)
+		if err != nil {
+			return err
+		}
+		if err := insertRevision(ctx, tx, d.ID, version, d, apiKeyID, 0); err != nil {
+			return err
+		}
+		d.Version = float64(version)
+		if _, err := tx.ExecContext(ctx, "UPDATE docs SET version = ? WHERE id = ?", d.Version, d.ID); err != nil {
+			return err
+		}
+	}
+	// Publier un document rend publique sa révision courante, même sans
+	// nouvelle révision
+	if d.Status == DocPublished && current.Status != DocPublished && d.Version == current.Version {
+		if _, err := tx.ExecContext(ctx, "UPDATE doc_revisions SET status = ? WHERE doc_id = ? AND version = ?",
+			DocPublished, d.ID, int(d.Version)); err != nil {
+			return err
+		}
+	}
+	d.RowVersion = current.RowVersion + 1
+	d.IsChildren = d.ParentID != nil
+	d.CreatedAt = current.CreatedAt
+	return tx.Commit()
+}
+
+func (r *docRepo) Move(ctx context.Context, id int, parentID *int, position int) (*Doc, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var path string
+	var oldParentID *int
+	if err := tx.QueryRowContext(ctx, "SELECT path, parent_id FROM docs WHERE id = ?", id).Scan(&path, &oldParentID); err != nil {
+		return nil, notFound(err)
+	}
+	if err := checkParent(ctx, tx, id, parentID); err != nil {
+		return nil, err
+	}
+	if err := checkPath(ctx, tx, id, parentID, path); err != nil {
+		return nil, err
+	}
+
+	siblings, err := children(ctx, tx, parentID)
+	if err != nil {
+		return nil, err
+	}
+	ordered := make([]int, 0, len(siblings)+1)
+	for _, s := range siblings {
+		if s != id {
+			ordered = append(ordered, s)
+		}
+	}
+	at := len(ordered)
+	if position > 0 && position-1 < at {
+		at = position - 1
+	}
+	ordered = append(ordered[:at], append([]int{id}, ordered[at:]...)...)
+
+	if _, err := tx.ExecContext(ctx, "UPDATE docs SET parent_id = ?, row_version = row_version + 1 WHERE id = ?", parentID, id); err != nil {
+		return nil, err
+	}
+	if err := renumber(ctx, tx, ordered); err != nil {
+		return nil, err
+	}
+	if !sameParent(oldParentID, parentID) {
+		if err := closeGap(ctx, tx, oldParentID); err != nil {
+			return nil, err
+		}
+	}
+	d, err := scanDoc(tx.QueryRowContext(ctx, "SELECT "+docColumns+" FROM docs WHERE id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+	return &d, tx.Commit()
+}
+
+func (r *docRepo) Reorder(ctx context.Context, parentID *int, ids []int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if parentID != nil {
+		if _, err := parentOf(ctx, tx, *parentID); errors.Is(err, ErrNotFound) {
+			return ErrInvalidParent
+		} else if err != nil {
+			return err
+		}
+	}
+	current, err := children(ctx, tx, parentID)
+	if err != nil {
+		return err
+	}
+	if len(ids) != len(current) {
+		return ErrInvalidOrder
+	}
+	pending := make(map[int]bool, len(current))
+	for _, id := range current {
+		pending[id] = true
+	}
+	for _, id := range ids {
+		if !pending[id] {
+			return ErrInvalidOrder // étranger à parentID, ou en double
+		}
+		delete(pending, id)
+	}
+
+	if err := renumber(ctx, tx, ids); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *docRepo) Delete(ctx context.Context, id int, mode DeleteMode) (*DocDeletion, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	parentID, err := parentOf(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	kids, err := children(ctx, tx, &id)
+	if err != nil {
+		return nil, err
+	}
+	kids = without(kids, id) // document qui serait son propre parent
+
+	res := &DocDeletion{Deleted: []int{id}, Reparented: []int{}}
+	var ordered []int // frères après rattachement, en mode DeleteReparent
+	switch mode {
+	case DeleteRefuse:
+		if len(kids) > 0 {
+			return nil, ErrHasChildren
+		}
+	case DeleteCascade:
+		// Parcours en largeur ; seen protège des cycles antérieurs
+		seen := map[int]bool{id: true}
+		for queue := kids; len(queue) > 0; {
+			next := queue[0]
+			queue = queue[1:]
+			if seen[next] {
+				continue
+			}
+			seen[next] = true
+			res.Deleted = append(res.Deleted, next)
+			grandchildren, err := children(ctx, tx, &next)
+			if err != nil {
+				return nil, err
+			}
+			queue = append(queue, grandchildren...)
+		}
+	case DeleteReparent:
+		// Les enfants prennent la place du document parmi ses frères
+		siblings, err := children(ctx, tx, parentID)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range siblings {
+			if s == id {
+				ordered = append(ordered, kids...)
+			} else {
+				ordered = append(ordered, s)
+			}
+		}
+		if len(kids) > 0 {
+			// Le document supprimé libère son propre chemin
+			where, args := parentClause(parentID)
+			var taken bool
+			err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM docs WHERE "+where+" AND id <> ? AND path IN (SELECT path FROM docs WHERE parent_id = ?))",
+				append(args, id, id)...).Scan(&taken)
+			if err != nil {
+				return nil, err
+			}
+			if taken {
+				return nil, ErrDuplicatePath
+			}
+		}
+		res.Reparented = kids
+	default:
+		return nil, fmt.Errorf("store: unknown delete mode %q", mode)
+	}
+
+	args := make([]interface{}, len(res.Deleted))
+	for i, d := range res.Deleted {
+		args[i] = d
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM docs WHERE id IN (?"+strings.Repeat(", ?", len(args)-1)+")", args...); err != nil {
+		return nil, err
+	}
+
+	// Rattachés après la suppression, pour que l'index unique des chemins
+	// ne voie pas le document supprimé
+	if mode == DeleteReparent {
+		if len(kids) > 0 {
+			args := []interface{}{parentID}
+			for _, k := range kids {
+				args = append(args, k)
+			}
+			if _, err := tx.ExecContext(ctx, "UPDATE docs SET parent_id = ?, row_version = row_version + 1 WHERE id IN (?"+strings.Repeat(", ?", len(kids)-1)+")", args...); err != nil {
+				return nil, err
+			}
+		}
+		if err := renumber(ctx, tx, ordered); err != nil {
+			return nil, err
+		}
+	}
+	return res, tx.Commit()
+}
+
+func without(ids []int, id int) []int {
+	out := ids[:0]
+	for _, v := range ids {
+		if v != id {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func (r *docRepo) Exists(ctx context.Context, id int) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM docs WHERE id = ?)", id).Scan(&exists)
+	return exists, err
+}
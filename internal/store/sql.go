@@ -0,0 +1,235 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// notFound traduit sql.ErrNoRows en ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// uniqueViolation indique si err vient d'un index unique. Le store ne dépend
+// d'aucun pilote : on reconnaît les messages de MySQL/MariaDB (1062) et de
+// SQLite.
+func uniqueViolation(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "Error 1062") || strings.Contains(msg, "UNIQUE constraint failed")
+}
+
+type keyRepo struct {
+	db *sql.DB
+}
+
+func (r *keyRepo) Lookup(ctx context.Context, apiKey string) (*APIKey, error) {
+	k := APIKey{Key: apiKey}
+	err := r.db.QueryRowContext(ctx, "SELECT id, permissions, strip_metadata FROM api_keys WHERE api_key = ?", apiKey).
+		Scan(&k.ID, &k.Permissions, &k.StripMetadata)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &k, nil
+}
+
+type fileRepo struct {
+	db *sql.DB
+}
+
+const fileColumns = "id, api_key_id, cid, is_private, file_name, mime_type, file_size, created_at, width, height, orientation, color_model, " +
+	"kind, frame_rate, duration, layer_count, asset_count, animation_count, theme_count, display_name, description, tags"
+
+func scanFile(row interface{ Scan(...interface{}) error }) (File, error) {
+	var f File
+	var tags string
+	err := row.Scan(&f.ID, &f.APIKeyID, &f.CID, &f.IsPrivate, &f.FileName, &f.MimeType, &f.FileSize, &f.CreatedAt,
+		&f.Width, &f.Height, &f.Orientation, &f.ColorModel,
+		&f.Kind, &f.FrameRate, &f.Duration, &f.LayerCount, &f.AssetCount, &f.AnimationCount, &f.ThemeCount,
+		&f.DisplayName, &f.Description, &tags)
+	if tags != "" {
+		f.Tags = strings.Split(tags, ",")
+	}
+	return f, err
+}
+
+func (r *fileRepo) Create(ctx context.Context, f *File) error {
+	result, err := r.db.ExecContext(ctx,
+		"INSERT INTO files (api_key_id, cid, is_private, file_name, mime_type, file_size, width, height, orientation, color_model, "+
+			"kind, frame_rate, duration, layer_count, asset_count, animation_count, theme_count, display_name, description, tags) "+
+			"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		f.APIKeyID, f.CID, f.IsPrivate, f.FileName, f.MimeType, f.FileSize, f.Width, f.Height, f.Orientation, f.ColorModel,
+		f.Kind, f.FrameRate, f.Duration, f.LayerCount, f.AssetCount, f.AnimationCount, f.ThemeCount,
+		f.DisplayName, f.Description, strings.Join(f.Tags, ","),
+	)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	f.ID = int(id)
+	return nil
+}
+
+func (r *fileRepo) Find(ctx context.Context, cid string, q FileQuery) (*File, error) {
+	query := "SELECT " + fileColumns + " FROM files WHERE cid = ?"
+	args := []interface{}{cid}
+	if q.PublicOnly {
+		query += " AND is_private = false"
+	}
+	if q.PrivateOnly {
+		query += " AND is_private = true"
+	}
+	if q.APIKeyID != 0 {
+		query += " AND api_key_id = ?"
+		args = append(args, q.APIKeyID)
+	}
+	if q.ExcludeAPIKeyID != 0 {
+		query += " AND api_key_id <> ?"
+		args = append(args, q.ExcludeAPIKeyID)
+	}
+	query += " ORDER BY id LIMIT 1"
+
+	f, err := scanFile(r.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &f, nil
+}
+
+func (r *fileRepo) list(ctx context.Context, where string, args []interface{}, limit, offset int) ([]File, int, error) {
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM files WHERE "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := "SELECT " + fileColumns + " FROM files WHERE " + where + " ORDER BY id"
+	if limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, limit, offset)
+	}
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var files []File
+	for rows.Next() {
+		f, err := scanFile(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		files = append(files, f)
+	}
+	return files, total, rows.Err()
+}
+
+func (r *fileRepo) ListPublic(ctx context.Context, limit, offset int) ([]File, int, error) {
+	return r.list(ctx, "is_private = false", nil, limit, offset)
+}
+
+func (r *fileRepo) ListByKey(ctx context.Context, apiKeyID, limit, offset int) ([]File, int, error) {
+	return r.list(ctx, "api_key_id = ?", []interface{}{apiKeyID}, limit, offset)
+}
+
+func (r *fileRepo) AllPublic(ctx context.Context) ([]File, error) {
+	files, _, err := r.list(ctx, "is_private = false", nil, 0, 0)
+	return files, err
+}
+
+func (r *fileRepo) SetPrivate(ctx context.Context, cid string, apiKeyID int, private bool) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE files SET is_private = ? WHERE cid = ? AND api_key_id = ?", private, cid, apiKeyID)
+	return err
+}
+
+func (r *fileRepo) SetDetails(ctx context.Context, cid string, apiKeyID int, d FileDetails) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE files SET display_name = ?, description = ?, tags = ? WHERE cid = ? AND api_key_id = ?",
+		d.DisplayName, d.Description, strings.Join(d.Tags, ","), cid, apiKeyID)
+	return err
+}
+
+func (r *fileRepo) Delete(ctx context.Context, cid string, apiKeyID int) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM files WHERE cid = ? AND api_key_id = ?", cid, apiKeyID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *fileRepo) CountByCID(ctx context.Context, cid string) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM files WHERE cid = ?", cid).Scan(&n)
+	return n, err
+}
+
+type variantRepo struct {
+	db *sql.DB
+}
+
+const variantColumns = "id, source_cid, variant_key, cid, mime_type, file_size, width, height"
+
+func scanVariant(row interface{ Scan(...interface{}) error }) (Variant, error) {
+	var v Variant
+	err := row.Scan(&v.ID, &v.SourceCID, &v.VariantKey, &v.CID, &v.MimeType, &v.FileSize, &v.Width, &v.Height)
+	return v, err
+}
+
+func (r *variantRepo) Find(ctx context.Context, sourceCID, key string) (*Variant, error) {
+	v, err := scanVariant(r.db.QueryRowContext(ctx,
+		"SELECT "+variantColumns+" FROM file_variants WHERE source_cid = ? AND variant_key = ?", sourceCID, key))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &v, nil
+}
+
+func (r *variantRepo) Create(ctx context.Context, v *Variant) error {
+	result, err := r.db.ExecContext(ctx,
+		"INSERT INTO file_variants (source_cid, variant_key, cid, mime_type, file_size, width, height) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		v.SourceCID, v.VariantKey, v.CID, v.MimeType, v.FileSize, v.Width, v.Height)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	v.ID = int(id)
+	return nil
+}
+
+func (r *variantRepo) ListBySource(ctx context.Context, sourceCID string) ([]Variant, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+variantColumns+" FROM file_variants WHERE source_cid = ? ORDER BY id", sourceCID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var variants []Variant
+	for rows.Next() {
+		v, err := scanVariant(rows)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, v)
+	}
+	return variants, rows.Err()
+}
+
+func (r *variantRepo) DeleteBySource(ctx context.Context, sourceCID string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM file_variants WHERE source_cid = ?", sourceCID)
+	return err
+}
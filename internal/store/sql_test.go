@@ -0,0 +1,262 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/store"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/pkg/database"
+)
+
+func newStore(t *testing.T) *store.Store {
+	t.Helper()
+	db, err := database.ConnectSQLite(filepath.Join(t.TempDir(), "store.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := database.MigrateUp(db, database.SQLite); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO api_keys (api_key, permissions) VALUES ('a', 'write'), ('b', 'read')"); err != nil {
+		t.Fatal(err)
+	}
+	return store.New(db)
+}
+
+func TestFileQueries(t *testing.T) {
+	ctx := context.Background()
+	s := newStore(t)
+
+	for _, f := range []store.File{
+		{APIKeyID: 1, CID: "pub", FileName: "a.png", MimeType: "image/png", FileSize: 1},
+		{APIKeyID: 2, CID: "priv", IsPrivate: true, FileName: "b.png", MimeType: "image/png", FileSize: 2},
+	} {
+		if err := s.Files.Create(ctx, &f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := s.Files.Find(ctx, "priv", store.FileQuery{PublicOnly: true}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Find(priv, PublicOnly) : err = %v, attendu ErrNotFound", err)
+	}
+	if f, err := s.Files.Find(ctx, "priv", store.FileQuery{PrivateOnly: true, APIKeyID: 2}); err != nil || f.FileName != "b.png" {
+		t.Errorf("Find(priv, clé 2) = %+v, %v", f, err)
+	}
+
+	files, total, err := s.Files.ListPublic(ctx, 10, 0)
+	if err != nil || total != 1 || len(files) != 1 || files[0].CID != "pub" {
+		t.Errorf("ListPublic = %+v (%d), %v", files, total, err)
+	}
+
+	details := store.FileDetails{DisplayName: "Logo", Description: "Logo du site", Tags: []string{"brand", "png"}}
+	if err := s.Files.SetDetails(ctx, "pub", 1, details); err != nil {
+		t.Fatalf("SetDetails : %v", err)
+	}
+	if f, err := s.Files.Find(ctx, "pub", store.FileQuery{}); err != nil || !reflect.DeepEqual(f.FileDetails, details) {
+		t.Errorf("Find(pub) après SetDetails = %+v, %v", f, err)
+	}
+
+	if err := s.Files.Delete(ctx, "pub", 2); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Delete par une autre clé : err = %v, attendu ErrNotFound", err)
+	}
+	if err := s.Files.Delete(ctx, "pub", 1); err != nil {
+		t.Errorf("Delete : %v", err)
+	}
+}
+
+func TestKeyLookup(t *testing.T) {
+	s := newStore(t)
+	k, err := s.Keys.Lookup(context.Background(), "a")
+	if err != nil || !k.CanWrite() {
+		t.Errorf("Lookup(a) = %+v, %v", k, err)
+	}
+	if _, err := s.Keys.Lookup(context.Background(), "inconnue"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Lookup(inconnue) : err = %v, attendu ErrNotFound", err)
+	}
+}
+
+func TestThemeLookups(t *testing.T) {
+	ctx := context.Background()
+	s := newStore(t)
+
+	theme := store.Theme{CID: "anim", Name: "Loader"}
+	if err := s.Themes.Create(ctx, &theme); err != nil {
+		t.Fatal(err)
+	}
+	if found, err := s.Themes.FindByName(ctx, "loader"); err != nil || found.ID != theme.ID {
+		t.Errorf("FindByName(loader) = %+v, %v", found, err)
+	}
+	if refs, err := s.Themes.ListByCID(ctx, "anim"); err != nil || len(refs) != 1 {
+		t.Errorf("ListByCID(anim) = %+v, %v", refs, err)
+	}
+
+	// Une mise à jour à l'identique n'est pas une absence
+	if err := s.Themes.Update(ctx, &theme); err != nil {
+		t.Errorf("Update à l'identique : %v", err)
+	}
+	if err := s.Themes.Update(ctx, &store.Theme{ID: 99, CID: "anim", Name: "x"}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Update(99) : err = %v, attendu ErrNotFound", err)
+	}
+	if err := s.Themes.Delete(ctx, 99); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Delete(99) : err = %v, attendu ErrNotFound", err)
+	}
+
+	// L'index unique refuse un nom déjà pris, quelle que soit la casse
+	if err := s.Themes.Create(ctx, &store.Theme{CID: "anim", Name: "LOADER"}); !errors.Is(err, store.ErrDuplicateName) {
+		t.Errorf("Create(LOADER) : err = %v, attendu ErrDuplicateName", err)
+	}
+	other := store.Theme{CID: "anim", Name: "Spinner"}
+	if err := s.Themes.Create(ctx, &other); err != nil {
+		t.Fatal(err)
+	}
+	other.Name = "loader"
+	if err := s.Themes.Update(ctx, &other); !errors.Is(err, store.ErrDuplicateName) {
+		t.Errorf("Update(loader) : err = %v, attendu ErrDuplicateName", err)
+	}
+}
+
+func TestDocMoves(t *testing.T) {
+	ctx := context.Background()
+	s := newStore(t)
+
+	root := store.Doc{Title: "root"}
+	if err := s.Docs.Create(ctx, &root, 1); err != nil {
+		t.Fatal(err)
+	}
+	child := store.Doc{Title: "child", ParentID: &root.ID}
+	if err := s.Docs.Create(ctx, &child, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Docs.Create(ctx, &store.Doc{Title: "copy", ParentID: &root.ID}, 1); !errors.Is(err, store.ErrDuplicatePath) {
+		t.Errorf("Create d'un chemin pris : err = %v, attendu ErrDuplicatePath", err)
+	}
+	if docs, err := s.Docs.FindByPath(ctx, ""); err != nil || len(docs) != 2 {
+		t.Errorf("FindByPath = %+v, %v", docs, err)
+	}
+
+	if _, err := s.Docs.Move(ctx, root.ID, &child.ID, 0); !errors.Is(err, store.ErrCycle) {
+		t.Errorf("Move sous un descendant : err = %v, attendu ErrCycle", err)
+	}
+	missing := 99
+	if _, err := s.Docs.Move(ctx, child.ID, &missing, 0); !errors.Is(err, store.ErrInvalidParent) {
+		t.Errorf("Move sous un parent inconnu : err = %v, attendu ErrInvalidParent", err)
+	}
+	if err := s.Docs.Reorder(ctx, &root.ID, []int{root.ID}); !errors.Is(err, store.ErrInvalidOrder) {
+		t.Errorf("Reorder étranger : err = %v, attendu ErrInvalidOrder", err)
+	}
+
+	// Le départ d'un enfant ne laisse pas de trou dans les positions
+	var moved [3]store.Doc
+	for i, path := range []string{"a", "b", "c"} {
+		moved[i] = store.Doc{Title: path, Path: path, ParentID: &child.ID}
+		if err := s.Docs.Create(ctx, &moved[i], 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.Docs.Move(ctx, moved[0].ID, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	// La renumérotation a changé sa ligne : mise à jour sans condition
+	moved[1].ParentID, moved[1].Position, moved[1].RowVersion = nil, 0, 0
+	if err := s.Docs.Update(ctx, &moved[1], 1); err != nil {
+		t.Fatal(err)
+	}
+	if last, err := s.Docs.Get(ctx, moved[2].ID); err != nil || last.Position != 1 {
+		t.Errorf("position du dernier enfant restant = %+v, %v", last, err)
+	}
+
+	if _, err := s.Docs.Delete(ctx, root.ID, store.DeleteRefuse); !errors.Is(err, store.ErrHasChildren) {
+		t.Errorf("Delete(refuse) : err = %v, attendu ErrHasChildren", err)
+	}
+	res, err := s.Docs.Delete(ctx, root.ID, store.DeleteReparent)
+	if err != nil || len(res.Reparented) != 1 || res.Reparented[0] != child.ID {
+		t.Fatalf("Delete(reparent) = %+v, %v", res, err)
+	}
+	if d, err := s.Docs.Get(ctx, child.ID); err != nil || d.ParentID != nil || d.IsChildren || d.Position != 1 {
+		t.Errorf("enfant rattaché = %+v, %v", d, err)
+	}
+}
+
+func TestDocRevisions(t *testing.T) {
+	ctx := context.Background()
+	s := newStore(t)
+
+	doc := store.Doc{Title: "Guide", Path: "/guide", DocSrc: "v1", CID: "cid1", Version: 7}
+	if err := s.Docs.Create(ctx, &doc, 1); err != nil || doc.Version != 1 {
+		t.Fatalf("Create : version %v, %v", doc.Version, err)
+	}
+	doc.DocSrc, doc.CID = "v2", "cid2"
+	if err := s.Docs.Update(ctx, &doc, 2); err != nil || doc.Version != 2 {
+		t.Fatalf("Update : version %v, %v", doc.Version, err)
+	}
+	// Une mise à jour à l'identique n'ajoute pas de révision
+	if err := s.Docs.Update(ctx, &doc, 2); err != nil || doc.Version != 2 {
+		t.Fatalf("Update à l'identique : version %v, %v", doc.Version, err)
+	}
+
+	if rev, err := s.Docs.FindRevisionByCID(ctx, doc.ID, "cid1"); err != nil || rev.Version != 1 || rev.DocSrc != "v1" {
+		t.Errorf("FindRevisionByCID(cid1) = %+v, %v", rev, err)
+	}
+	restored, err := s.Docs.RestoreRevision(ctx, doc.ID, 1, 1, "cid1")
+	if err != nil || restored.Version != 3 || restored.DocSrc != "v1" || restored.CID != "cid1" {
+		t.Fatalf("RestoreRevision = %+v, %v", restored, err)
+	}
+	revs, total, err := s.Docs.ListRevisions(ctx, doc.ID, false, 10, 0)
+	if err != nil || total != 3 || revs[0].Version != 3 || revs[0].RestoredFrom != 1 || revs[1].APIKeyID != 2 {
+		t.Fatalf("ListRevisions = %+v (%d), %v", revs, total, err)
+	}
+	if _, err := s.Docs.GetRevision(ctx, doc.ID, 4); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetRevision(4) : err = %v, attendu ErrNotFound", err)
+	}
+	// La restauration a changé la ligne : la copie locale est périmée
+	if err := s.Docs.Update(ctx, &doc, 2); !errors.Is(err, store.ErrStale) {
+		t.Errorf("Update d'une version périmée : err = %v, attendu ErrStale", err)
+	}
+}
+
+func TestDocStatus(t *testing.T) {
+	ctx := context.Background()
+	s := newStore(t)
+
+	doc := store.Doc{Title: "guide", DocSrc: "# Guide"}
+	if err := s.Docs.Create(ctx, &doc, 1); err != nil || doc.Status != store.DocDraft {
+		t.Fatalf("Create : statut %q, %v", doc.Status, err)
+	}
+	publishAt := time.Date(2030, 1, 2, 3, 4, 5, 600, time.FixedZone("CET", 3600))
+	doc.Status, doc.PublishAt = store.DocPublished, &publishAt
+	if err := s.Docs.Update(ctx, &doc, 1); err != nil {
+		t.Fatal(err)
+	}
+	// Publier sans changer le contenu publie la révision courante
+	if revs, total, err := s.Docs.ListRevisions(ctx, doc.ID, true, 10, 0); err != nil || total != 1 || revs[0].Status != store.DocPublished {
+		t.Fatalf("ListRevisions(publiées) = %+v (%d), %v", revs, total, err)
+	}
+	// Un statut vide garde le statut courant
+	doc.Status = ""
+	if err := s.Docs.Update(ctx, &doc, 1); err != nil || doc.Status != store.DocPublished {
+		t.Fatalf("Update sans statut : %q, %v", doc.Status, err)
+	}
+
+	if outline, err := s.Docs.ListOutline(ctx); err != nil || len(outline) != 1 || outline[0].DocSrc != "" || outline[0].Status != store.DocPublished || outline[0].Title != "guide" {
+		t.Errorf("ListOutline = %+v, %v", outline, err)
+	}
+
+	got, err := s.Docs.Get(ctx, doc.ID)
+	if err != nil || got.Status != store.DocPublished || got.PublishAt == nil || !got.PublishAt.Equal(publishAt.Truncate(time.Second)) || got.UnpublishAt != nil {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+	if got.Live(publishAt.Add(-time.Second)) || !got.Live(publishAt) {
+		t.Errorf("Live autour de publish_at = %v, %v", got.Live(publishAt.Add(-time.Second)), got.Live(publishAt))
+	}
+	unpublishAt := publishAt.Add(time.Hour)
+	got.UnpublishAt = &unpublishAt
+	if got.Live(unpublishAt) {
+		t.Error("Live après unpublish_at")
+	}
+}
@@ -0,0 +1,401 @@
+// Package store isole l'accès aux données derrière des interfaces de
+// dépôt. L'implémentation SQL fonctionne sur MariaDB en production et sur
+// SQLite (driver pur Go) pour les tests et les installations mono-nœud.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrNotFound est renvoyée lorsqu'aucune ligne ne correspond
+var ErrNotFound = errors.New("store: not found")
+
+// Erreurs des opérations sur l'arborescence des documents
+var (
+	// ErrInvalidParent : le parent désigné n'existe pas
+	ErrInvalidParent = errors.New("store: invalid parent")
+	// ErrCycle : le document deviendrait son propre ancêtre
+	ErrCycle = errors.New("store: document would become its own ancestor")
+	// ErrHasChildren : suppression refusée d'un document qui a des enfants
+	ErrHasChildren = errors.New("store: document has children")
+	// ErrInvalidOrder : l'ordre fourni ne liste pas exactement les enfants
+	ErrInvalidOrder = errors.New("store: order does not match children")
+	// ErrDuplicatePath : un frère porte déjà ce chemin
+	ErrDuplicatePath = errors.New("store: path already used under this parent")
+)
+
+// ErrDuplicateName est renvoyée par l'écriture d'un thème dont le nom est
+// déjà porté par un autre, sans tenir compte de la casse
+var ErrDuplicateName = errors.New("store: theme name already used")
+
+// ErrStale est renvoyée par une mise à jour conditionnelle lorsque la ligne a
+// été modifiée depuis la version attendue
+var ErrStale = errors.New("store: stale row version")
+
+// APIKey est une clé d'API et ses permissions
+type APIKey struct {
+	ID          int
+	Key         string
+	Permissions string
+	// StripMetadata retire par défaut les métadonnées EXIF/XMP des images uploadées
+	StripMetadata bool
+}
+
+// CanWrite indique si la clé possède la permission d'écriture
+func (k *APIKey) CanWrite() bool {
+	return k.Permissions == "write"
+}
+
+// File décrit un fichier uploadé sur IPFS
+type File struct {
+	ID        int
+	APIKeyID  int
+	CID       string
+	IsPrivate bool
+	FileName  string
+	MimeType  string
+	FileSize  int64
+	CreatedAt string
+	// Dimensions et métadonnées des images (zéro si inconnues)
+	Width       int
+	Height      int
+	Orientation int
+	ColorModel  string
+	// Kind vaut KindLottie ou KindDotLottie pour une animation validée, "" sinon.
+	// Pour une archive, les champs suivants décrivent l'animation active.
+	Kind       string
+	FrameRate  float64
+	Duration   float64
+	LayerCount int
+	AssetCount int
+	// Contenu d'une archive dotLottie
+	AnimationCount int
+	ThemeCount     int
+	FileDetails
+}
+
+// FileDetails regroupe les informations d'un fichier modifiables après son
+// envoi
+type FileDetails struct {
+	DisplayName string
+	Description string
+	// Tags ne contient pas de virgule (séparateur en base)
+	Tags []string
+}
+
+// Natures de fichier (colonne kind)
+const (
+	KindLottie    = "lottie"
+	KindDotLottie = "dotlottie"
+)
+
+// FileQuery restreint la recherche d'un fichier par CID
+type FileQuery struct {
+	PublicOnly  bool
+	PrivateOnly bool
+	// APIKeyID limite aux fichiers de cette clé (0 : toutes les clés)
+	APIKeyID int
+	// ExcludeAPIKeyID écarte les fichiers de cette clé (0 : aucune)
+	ExcludeAPIKeyID int
+}
+
+// Doc est une page de documentation
+type Doc struct {
+	ID     int    `json:"id"`
+	Title  string `json:"title"`
+	Path   string `json:"path"`
+	DocSrc string `json:"doc_src"`
+	// Version est le numéro de la dernière révision, fixé par le serveur
+	Version float64 `json:"version"`
+	// CID désigne DocSrc sur IPFS ("" pour un contenu antérieur au stockage IPFS)
+	CID string `json:"cid"`
+	// RowVersion compte toutes les modifications de la ligne ; non nul dans
+	// une mise à jour, c'est la version attendue (sinon ErrStale)
+	RowVersion int `json:"-"`
+	// IsChildren est déduit de ParentID ; la valeur envoyée est ignorée
+	IsChildren bool `json:"is_children"`
+	ParentID   *int `json:"parent_id"`
+	// Position ordonne les documents d'un même parent (0 : à la suite)
+	Position int `json:"position"`
+	// Status vide vaut DocDraft à la création et reste inchangé à la mise à
+	// jour
+	Status DocStatus `json:"status"`
+	// PublishAt et UnpublishAt bornent, à la seconde, la visibilité d'un
+	// document publié (nil : sans limite)
+	PublishAt   *time.Time `json:"publish_at"`
+	UnpublishAt *time.Time `json:"unpublish_at"`
+	CreatedAt   string     `json:"created_at"`
+	UpdatedAt   string     `json:"updated_at"`
+}
+
+// DocStatus est l'étape d'un document dans le circuit de publication
+type DocStatus string
+
+const (
+	DocDraft     DocStatus = "draft"
+	DocReview    DocStatus = "review"
+	DocPublished DocStatus = "published"
+	DocArchived  DocStatus = "archived"
+)
+
+// Valid indique si s est un statut connu
+func (s DocStatus) Valid() bool {
+	switch s {
+	case DocDraft, DocReview, DocPublished, DocArchived:
+		return true
+	}
+	return false
+}
+
+// Live indique si le document est publié et dans sa fenêtre de visibilité
+// à l'instant now
+func (d *Doc) Live(now time.Time) bool {
+	return d.Status == DocPublished &&
+		(d.PublishAt == nil || !now.Before(*d.PublishAt)) &&
+		(d.UnpublishAt == nil || now.Before(*d.UnpublishAt))
+}
+
+// LiveDocs garde les documents visibles à l'instant now : publiés, dans leur
+// fenêtre de publication et sous des ancêtres eux-mêmes visibles
+func LiveDocs(docs []Doc, now time.Time) []Doc {
+	byID := make(map[int]*Doc, len(docs))
+	for i := range docs {
+		byID[docs[i].ID] = &docs[i]
+	}
+	// live mémorise la réponse par document ; false pendant la remontée
+	// arrête aussi un cycle hérité de données anciennes
+	live := map[int]bool{}
+	var isLive func(d *Doc) bool
+	isLive = func(d *Doc) bool {
+		if v, ok := live[d.ID]; ok {
+			return v
+		}
+		live[d.ID] = false
+		v := d.Live(now)
+		if v && d.ParentID != nil && byID[*d.ParentID] != nil {
+			v = isLive(byID[*d.ParentID])
+		}
+		live[d.ID] = v
+		return v
+	}
+
+	out := []Doc{}
+	for i := range docs {
+		if isLive(&docs[i]) {
+			out = append(out, docs[i])
+		}
+	}
+	return out
+}
+
+// Theme associe un nom à une animation stockée sur IPFS et, le cas échéant,
+// aux animations de chaque rôle de l'interface
+type Theme struct {
+	ID   int    `json:"id"`
+	CID  string `json:"cid"`
+	Name string `json:"name"`
+	// Category regroupe les thèmes dans les sélecteurs des applications
+	Category  string `json:"category"`
+	SortOrder int    `json:"sort_order"`
+	Enabled   bool   `json:"enabled"`
+	// PreviewCID désigne une image d'aperçu publique
+	PreviewCID string           `json:"preview_cid,omitempty"`
+	Animations []ThemeAnimation `json:"animations"`
+	// RowVersion compte les modifications du thème ; non nul dans une mise
+	// à jour, c'est la version attendue (sinon ErrStale)
+	RowVersion int `json:"-"`
+}
+
+// ThemeAnimation est l'animation d'un thème pour un rôle (idle, loading,
+// success, error...) et une variante (light, dark ou "" par défaut)
+type ThemeAnimation struct {
+	Role    string `json:"role"`
+	Variant string `json:"variant,omitempty"`
+	CID     string `json:"cid"`
+}
+
+// ThemeQuery filtre la liste des thèmes
+type ThemeQuery struct {
+	Category string
+	// Enabled limite aux thèmes activés (true) ou désactivés (false)
+	Enabled *bool
+}
+
+// Variant est une version dérivée d'un fichier (image redimensionnée ou
+// encodage compressé), stockée sur IPFS sous son propre CID
+type Variant struct {
+	ID         int
+	SourceCID  string
+	VariantKey string
+	CID        string
+	MimeType   string
+	FileSize   int64
+	Width      int
+	Height     int
+}
+
+// KeyRepository donne accès aux clés d'API
+type KeyRepository interface {
+	// Lookup renvoie la clé correspondant à apiKey, ou ErrNotFound
+	Lookup(ctx context.Context, apiKey string) (*APIKey, error)
+}
+
+// FileRepository donne accès aux métadonnées des fichiers
+type FileRepository interface {
+	Create(ctx context.Context, f *File) error
+	// Find renvoie le premier fichier portant ce CID et satisfaisant q
+	Find(ctx context.Context, cid string, q FileQuery) (*File, error)
+	// ListPublic renvoie une page de fichiers publics et leur nombre total
+	ListPublic(ctx context.Context, limit, offset int) ([]File, int, error)
+	// ListByKey renvoie une page des fichiers d'une clé et leur nombre total
+	ListByKey(ctx context.Context, apiKeyID, limit, offset int) ([]File, int, error)
+	// AllPublic renvoie tous les fichiers publics (réindexation)
+	AllPublic(ctx context.Context) ([]File, error)
+	SetPrivate(ctx context.Context, cid string, apiKeyID int, private bool) error
+	// SetDetails remplace les informations modifiables des fichiers de la clé
+	SetDetails(ctx context.Context, cid string, apiKeyID int, d FileDetails) error
+	// Delete supprime le fichier de la clé, ou renvoie ErrNotFound
+	Delete(ctx context.Context, cid string, apiKeyID int) error
+	// CountByCID compte les fichiers (toutes clés) partageant ce CID
+	CountByCID(ctx context.Context, cid string) (int, error)
+}
+
+// DocRepository donne accès aux documents
+type DocRepository interface {
+	// Create enregistre le document et sa révision 1, écrite par la clé
+	// apiKeyID ; ErrDuplicatePath si un frère porte déjà son chemin
+	Create(ctx context.Context, d *Doc, apiKeyID int) error
+	Get(ctx context.Context, id int) (*Doc, error)
+	List(ctx context.Context) ([]Doc, error)
+	// ListOutline renvoie tous les documents sans leur contenu (DocSrc
+	// vide) : de quoi calculer leur visibilité ou l'arborescence sans lire
+	// le contenu de chaque document
+	ListOutline(ctx context.Context) ([]Doc, error)
+	// FindByPath renvoie les documents portant ce chemin, un au plus par
+	// parent, par id croissant
+	FindByPath(ctx context.Context, path string) ([]Doc, error)
+	// Update remplace le document d.ID et, si son titre, son chemin ou son
+	// contenu changent, ajoute une révision et incrémente d.Version ;
+	// ErrNotFound, ErrStale, ErrDuplicatePath, ou ErrInvalidParent ou
+	// ErrCycle si le nouveau parent est inconnu ou descend du document
+	Update(ctx context.Context, d *Doc, apiKeyID int) error
+	// Move rattache le document id à parentID (nil : premier niveau) au rang
+	// position parmi ses nouveaux frères (1 : en tête, 0 : à la fin) et les
+	// renumérote ; mêmes erreurs qu'Update
+	Move(ctx context.Context, id int, parentID *int, position int) (*Doc, error)
+	// Reorder renumérote les enfants de parentID dans l'ordre de ids, qui doit
+	// les lister tous une fois (ErrInvalidOrder sinon)
+	Reorder(ctx context.Context, parentID *int, ids []int) error
+	// Delete supprime le document id selon mode ; ErrNotFound,
+	// ErrHasChildren en mode DeleteRefuse, ou ErrDuplicatePath si un enfant
+	// rattaché en mode DeleteReparent a le chemin d'un de ses nouveaux frères
+	Delete(ctx context.Context, id int, mode DeleteMode) (*DocDeletion, error)
+	Exists(ctx context.Context, id int) (bool, error)
+
+	// ListRevisions renvoie une page des révisions du document, la plus
+	// récente d'abord et sans contenu, et leur nombre total ; publishedOnly
+	// ne garde que les révisions enregistrées quand le document était publié
+	ListRevisions(ctx context.Context, docID int, publishedOnly bool, limit, offset int) ([]DocRevision, int, error)
+	// GetRevision renvoie une révision complète, ou ErrNotFound
+	GetRevision(ctx context.Context, docID, version int) (*DocRevision, error)
+	// FindRevisionByCID renvoie la dernière révision du document dont le
+	// contenu a ce CID, ou ErrNotFound
+	FindRevisionByCID(ctx context.Context, docID int, cid string) (*DocRevision, error)
+	// RestoreRevision rétablit le contenu de la révision version, stocké
+	// sous cid, dans une nouvelle révision et renvoie le document ;
+	// ErrNotFound si le document ou la révision n'existe pas
+	RestoreRevision(ctx context.Context, docID, version, apiKeyID int, cid string) (*Doc, error)
+}
+
+// DocRevision est un état enregistré, immuable, d'un document
+type DocRevision struct {
+	ID      int    `json:"id"`
+	DocID   int    `json:"doc_id"`
+	Version int    `json:"version"`
+	Title   string `json:"title"`
+	Path    string `json:"path"`
+	// DocSrc est vide dans les listes de révisions
+	DocSrc string `json:"doc_src,omitempty"`
+	// CID désigne DocSrc sur IPFS ("" pour une révision antérieure)
+	CID string `json:"cid"`
+	// APIKeyID est la clé auteur (0, omis : état repris à la création de
+	// l'historique ; les handlers l'effacent aussi sans clé en écriture)
+	APIKeyID int `json:"api_key_id,omitempty"`
+	// Status est le statut du document à l'enregistrement de la révision
+	Status DocStatus `json:"status"`
+	// RestoredFrom est la version restaurée par cette révision (0 : aucune)
+	RestoredFrom int    `json:"restored_from,omitempty"`
+	CreatedAt    string `json:"created_at"`
+}
+
+// DeleteMode choisit le sort des enfants d'un document supprimé
+type DeleteMode string
+
+const (
+	// DeleteRefuse refuse la suppression d'un document qui a des enfants
+	DeleteRefuse DeleteMode = "refuse"
+	// DeleteCascade supprime aussi tous les descendants
+	DeleteCascade DeleteMode = "cascade"
+	// DeleteReparent rattache les enfants au parent du document, à sa place
+	DeleteReparent DeleteMode = "reparent"
+)
+
+// DocDeletion rapporte les documents touchés par une suppression
+type DocDeletion struct {
+	Deleted    []int `json:"deleted"`
+	Reparented []int `json:"reparented"`
+}
+
+// ThemeRepository donne accès aux thèmes d'animation
+type ThemeRepository interface {
+	// List renvoie une page des thèmes satisfaisant q, triés par sort_order
+	// puis par id, et leur nombre total ; limit = 0 renvoie tous les thèmes
+	List(ctx context.Context, q ThemeQuery, limit, offset int) ([]Theme, int, error)
+	// Get renvoie le thème id, ou ErrNotFound
+	Get(ctx context.Context, id int) (*Theme, error)
+	// FindByName renvoie le thème portant ce nom (sans tenir compte de la
+	// casse), ou ErrNotFound
+	FindByName(ctx context.Context, name string) (*Theme, error)
+	// ListByCID renvoie les thèmes qui référencent ce CID (animation
+	// principale, animation d'un rôle ou aperçu)
+	ListByCID(ctx context.Context, cid string) ([]Theme, error)
+	// Create ajoute le thème t, ou renvoie ErrDuplicateName
+	Create(ctx context.Context, t *Theme) error
+	// Update remplace le thème t.ID, ou renvoie ErrNotFound, ErrStale ou
+	// ErrDuplicateName
+	Update(ctx context.Context, t *Theme) error
+	// Delete supprime le thème id, ou renvoie ErrNotFound
+	Delete(ctx context.Context, id int) error
+}
+
+// VariantRepository donne accès au cache des variantes
+type VariantRepository interface {
+	// Find renvoie la variante sourceCID/key, ou ErrNotFound
+	Find(ctx context.Context, sourceCID, key string) (*Variant, error)
+	Create(ctx context.Context, v *Variant) error
+	ListBySource(ctx context.Context, sourceCID string) ([]Variant, error)
+	DeleteBySource(ctx context.Context, sourceCID string) error
+}
+
+// Store regroupe les dépôts du service
+type Store struct {
+	Files    FileRepository
+	Keys     KeyRepository
+	Docs     DocRepository
+	Themes   ThemeRepository
+	Variants VariantRepository
+}
+
+// New construit les dépôts SQL sur db (MariaDB ou SQLite)
+func New(db *sql.DB) *Store {
+	return &Store{
+		Files:    &fileRepo{db: db},
+		Keys:     &keyRepo{db: db},
+		Docs:     &docRepo{db: db},
+		Themes:   &themeRepo{db: db},
+		Variants: &variantRepo{db: db},
+	}
+}
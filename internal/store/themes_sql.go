@@ -0,0 +1,221 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+type themeRepo struct {
+	db *sql.DB
+}
+
+const themeColumns = "id, cid, name, category, sort_order, enabled, preview_cid, row_version"
+
+func scanTheme(row interface{ Scan(...interface{}) error }) (Theme, error) {
+	var t Theme
+	err := row.Scan(&t.ID, &t.CID, &t.Name, &t.Category, &t.SortOrder, &t.Enabled, &t.PreviewCID, &t.RowVersion)
+	return t, err
+}
+
+func (r *themeRepo) List(ctx context.Context, q ThemeQuery, limit, offset int) ([]Theme, int, error) {
+	where, args := "1 = 1", []interface{}{}
+	if q.Category != "" {
+		where += " AND category = ?"
+		args = append(args, q.Category)
+	}
+	if q.Enabled != nil {
+		where += " AND enabled = ?"
+		args = append(args, *q.Enabled)
+	}
+	return r.list(ctx, where, args, limit, offset)
+}
+
+func (r *themeRepo) ListByCID(ctx context.Context, cid string) ([]Theme, error) {
+	themes, _, err := r.list(ctx,
+		"cid = ? OR preview_cid = ? OR id IN (SELECT theme_id FROM cid_theme_animations WHERE cid = ?)",
+		[]interface{}{cid, cid, cid}, 0, 0)
+	return themes, err
+}
+
+func (r *themeRepo) list(ctx context.Context, where string, args []interface{}, limit, offset int) ([]Theme, int, error) {
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM cid_themes WHERE "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := "SELECT " + themeColumns + " FROM cid_themes WHERE " + where + " ORDER BY sort_order, id"
+	if limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, limit, offset)
+	}
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var themes []Theme
+	for rows.Next() {
+		t, err := scanTheme(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		themes = append(themes, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if err := r.loadAnimations(ctx, themes); err != nil {
+		return nil, 0, err
+	}
+	return themes, total, nil
+}
+
+// loadAnimations renseigne en une requête les animations des thèmes
+func (r *themeRepo) loadAnimations(ctx context.Context, themes []Theme) error {
+	if len(themes) == 0 {
+		return nil
+	}
+	index := make(map[int]*Theme, len(themes))
+	args := make([]interface{}, len(themes))
+	for i := range themes {
+		themes[i].Animations = []ThemeAnimation{}
+		index[themes[i].ID] = &themes[i]
+		args[i] = themes[i].ID
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT theme_id, role, variant, cid FROM cid_theme_animations WHERE theme_id IN (?"+
+			strings.Repeat(", ?", len(themes)-1)+") ORDER BY theme_id, role, variant", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var themeID int
+		var a ThemeAnimation
+		if err := rows.Scan(&themeID, &a.Role, &a.Variant, &a.CID); err != nil {
+			return err
+		}
+		t := index[themeID]
+		t.Animations = append(t.Animations, a)
+	}
+	return rows.Err()
+}
+
+func (r *themeRepo) Get(ctx context.Context, id int) (*Theme, error) {
+	return r.find(ctx, "id = ?", id)
+}
+
+func (r *themeRepo) FindByName(ctx context.Context, name string) (*Theme, error) {
+	return r.find(ctx, "LOWER(name) = LOWER(?)", name)
+}
+
+func (r *themeRepo) find(ctx context.Context, where string, arg interface{}) (*Theme, error) {
+	t, err := scanTheme(r.db.QueryRowContext(ctx, "SELECT "+themeColumns+" FROM cid_themes WHERE "+where+" ORDER BY id LIMIT 1", arg))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	themes := []Theme{t}
+	if err := r.loadAnimations(ctx, themes); err != nil {
+		return nil, err
+	}
+	return &themes[0], nil
+}
+
+func (r *themeRepo) Create(ctx context.Context, t *Theme) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO cid_themes (cid, name, category, sort_order, enabled, preview_cid) VALUES (?, ?, ?, ?, ?, ?)",
+		t.CID, t.Name, t.Category, t.SortOrder, t.Enabled, t.PreviewCID)
+	if uniqueViolation(err) {
+		return ErrDuplicateName
+	} else if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	if err := insertAnimations(ctx, tx, int(id), t.Animations); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	t.ID, t.RowVersion = int(id), 1
+	return nil
+}
+
+func (r *themeRepo) Update(ctx context.Context, t *Theme) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Existence vérifiée explicitement, avant la version : une absence n'est
+	// pas un conflit
+	var current int
+	if err := tx.QueryRowContext(ctx, "SELECT row_version FROM cid_themes WHERE id = ?", t.ID).Scan(&current); err != nil {
+		return notFound(err)
+	}
+	if t.RowVersion != 0 && t.RowVersion != current {
+		return ErrStale
+	}
+
+	// row_version change toujours : MariaDB compte la ligne même si le reste
+	// est identique, et une écriture concurrente est détectée
+	result, err := tx.ExecContext(ctx,
+		"UPDATE cid_themes SET cid = ?, name = ?, category = ?, sort_order = ?, enabled = ?, preview_cid = ?, row_version = row_version + 1 WHERE id = ? AND row_version = ?",
+		t.CID, t.Name, t.Category, t.SortOrder, t.Enabled, t.PreviewCID, t.ID, current)
+	if uniqueViolation(err) {
+		return ErrDuplicateName
+	} else if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrStale
+	}
+	t.RowVersion = current + 1
+	if _, err := tx.ExecContext(ctx, "DELETE FROM cid_theme_animations WHERE theme_id = ?", t.ID); err != nil {
+		return err
+	}
+	if err := insertAnimations(ctx, tx, t.ID, t.Animations); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertAnimations(ctx context.Context, tx *sql.Tx, themeID int, animations []ThemeAnimation) error {
+	for _, a := range animations {
+		_, err := tx.ExecContext(ctx, "INSERT INTO cid_theme_animations (theme_id, role, variant, cid) VALUES (?, ?, ?, ?)",
+			themeID, a.Role, a.Variant, a.CID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *themeRepo) Delete(ctx context.Context, id int) error {
+	// Les animations du thème sont supprimées en cascade
+	result, err := r.db.ExecContext(ctx, "DELETE FROM cid_themes WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+// Package testenv monte un environnement de test complet et hors ligne :
+// base SQLite migrée, index Bleve dans un répertoire temporaire et nœud IPFS
+// en mémoire, branchés sur les vrais handlers.
+package testenv
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/handlers"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/service"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/store"
+	bleveindex "github.com/TomPo62/bakiverse-ipfs-service-go/pkg/bleve"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/pkg/database"
+	"github.com/blevesearch/bleve/v2"
+)
+
+// Clés d'API créées dans chaque environnement
+const (
+	WriteKey = "write-key"
+	ReadKey  = "read-key"
+	// OtherKey est une seconde clé en écriture, pour les tests de propriété
+	OtherKey = "other-key"
+)
+
+// Env regroupe les dépendances d'un environnement de test
+type Env struct {
+	DB    *sql.DB
+	Store *store.Store
+	Index bleve.Index
+	// DocsIndex est l'index des documents
+	DocsIndex bleve.Index
+	IPFS      *service.MemoryNode
+	Handler   *handler.Handler
+}
+
+// New crée un environnement isolé, libéré à la fin du test
+func New(t testing.TB) *Env {
+	t.Helper()
+	dir := t.TempDir()
+
+	db, err := database.ConnectSQLite(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := database.MigrateUp(db, database.SQLite); err != nil {
+		t.Fatalf("migrations : %v", err)
+	}
+	_, err = db.Exec("INSERT INTO api_keys (api_key, permissions) VALUES (?, 'write'), (?, 'read'), (?, 'write')",
+		WriteKey, ReadKey, OtherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := bleve.New(filepath.Join(dir, "files_index.bleve"), bleve.NewIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { index.Close() })
+	docsIndex, err := bleve.New(filepath.Join(dir, "docs_index.bleve"), bleveindex.DocsIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { docsIndex.Close() })
+
+	s := store.New(db)
+	ipfs := service.NewMemoryNode()
+	return &Env{
+		DB:        db,
+		Store:     s,
+		Index:     index,
+		DocsIndex: docsIndex,
+		IPFS:      ipfs,
+		Handler:   handler.New(s, index, docsIndex, ipfs),
+	}
+}
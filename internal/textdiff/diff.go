@@ -0,0 +1,182 @@
+// Package textdiff calcule le diff ligne à ligne de deux textes (algorithme
+// de Myers) et le met en forme au format unifié.
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kind est la nature d'une ligne du diff
+type Kind byte
+
+const (
+	Equal  Kind = ' '
+	Insert Kind = '+'
+	Delete Kind = '-'
+)
+
+// Op est une ligne du diff
+type Op struct {
+	Kind Kind
+	Text string
+}
+
+// Lines renvoie le plus court script d'édition qui transforme a en b
+func Lines(a, b string) []Op {
+	x, y := split(a), split(b)
+	n, m := len(x), len(y)
+	max := n + m
+	v := make([]int, 2*max+2)
+	// trace[d] est l'état des diagonales avant l'étape d
+	var trace [][]int
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var i int
+			if k == -d || k != d && v[max+k-1] < v[max+k+1] {
+				i = v[max+k+1]
+			} else {
+				i = v[max+k-1] + 1
+			}
+			j := i - k
+			for i < n && j < m && x[i] == y[j] {
+				i, j = i+1, j+1
+			}
+			v[max+k] = i
+			if i >= n && j >= m {
+				return backtrack(trace, x, y, max)
+			}
+		}
+	}
+	return nil
+}
+
+func backtrack(trace [][]int, x, y []string, max int) []Op {
+	var ops []Op
+	i, j := len(x), len(y)
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := i - j
+		prevK := k - 1
+		if k == -d || k != d && v[max+k-1] < v[max+k+1] {
+			prevK = k + 1
+		}
+		prevI := v[max+prevK]
+		prevJ := prevI - prevK
+		for i > prevI && j > prevJ {
+			ops = append(ops, Op{Equal, x[i-1]})
+			i, j = i-1, j-1
+		}
+		if d > 0 {
+			if i == prevI {
+				ops = append(ops, Op{Insert, y[j-1]})
+				j--
+			} else {
+				ops = append(ops, Op{Delete, x[i-1]})
+				i--
+			}
+		}
+	}
+	for l, r := 0, len(ops)-1; l < r; l, r = l+1, r-1 {
+		ops[l], ops[r] = ops[r], ops[l]
+	}
+	return ops
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// Stats compte les lignes ajoutées et supprimées
+func Stats(ops []Op) (added, removed int) {
+	for _, op := range ops {
+		switch op.Kind {
+		case Insert:
+			added++
+		case Delete:
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// Unified met ops au format unifié, avec context lignes inchangées autour
+// de chaque modification ; renvoie "" si les textes sont identiques
+func Unified(ops []Op, fromName, toName string, context int) string {
+	// Numéros de ligne, dans a et dans b, qui précèdent chaque op
+	aBefore := make([]int, len(ops)+1)
+	bBefore := make([]int, len(ops)+1)
+	for i, op := range ops {
+		aBefore[i+1], bBefore[i+1] = aBefore[i], bBefore[i]
+		if op.Kind != Insert {
+			aBefore[i+1]++
+		}
+		if op.Kind != Delete {
+			bBefore[i+1]++
+		}
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(ops); {
+		for i < len(ops) && ops[i].Kind == Equal {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+		if sb.Len() == 0 {
+			fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+		}
+
+		// Un hunk absorbe les modifications séparées par au plus 2*context
+		// lignes inchangées
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for {
+			for end < len(ops) && ops[end].Kind != Equal {
+				end++
+			}
+			run := end
+			for run < len(ops) && ops[run].Kind == Equal {
+				run++
+			}
+			if run < len(ops) && run-end <= 2*context {
+				end = run
+				continue
+			}
+			if end += context; end > len(ops) {
+				end = len(ops)
+			}
+			break
+		}
+
+		aLen, bLen := aBefore[end]-aBefore[start], bBefore[end]-bBefore[start]
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(aBefore[start], aLen), hunkRange(bBefore[start], bLen))
+		for _, op := range ops[start:end] {
+			sb.WriteByte(byte(op.Kind))
+			sb.WriteString(op.Text)
+			sb.WriteByte('\n')
+		}
+		i = end
+	}
+	return sb.String()
+}
+
+// hunkRange formate "début,longueur" ; une plage vide désigne la ligne
+// qui la précède
+func hunkRange(before, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	if length == 1 {
+		return fmt.Sprint(before + 1)
+	}
+	return fmt.Sprintf("%d,%d", before+1, length)
+}
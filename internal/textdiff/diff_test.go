@@ -0,0 +1,71 @@
+package textdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+// apply reconstruit les deux textes à partir du script
+func apply(ops []Op) (a, b []string) {
+	for _, op := range ops {
+		if op.Kind != Insert {
+			a = append(a, op.Text)
+		}
+		if op.Kind != Delete {
+			b = append(b, op.Text)
+		}
+	}
+	return a, b
+}
+
+func TestLines(t *testing.T) {
+	for _, tc := range []struct {
+		a, b           string
+		added, removed int
+	}{
+		{"", "", 0, 0},
+		{"a\nb\nc\n", "a\nb\nc\n", 0, 0},
+		{"", "x\ny", 2, 0},
+		{"a\nb\nc", "a\nc", 0, 1},
+		{"a\nb\nc\na\nb\nb\na", "c\nb\na\nb\na\nc", 2, 3},
+	} {
+		ops := Lines(tc.a, tc.b)
+		a, b := apply(ops)
+		if strings.Join(a, "\n") != strings.Join(split(tc.a), "\n") || strings.Join(b, "\n") != strings.Join(split(tc.b), "\n") {
+			t.Errorf("Lines(%q, %q) ne reconstruit pas les textes : %v", tc.a, tc.b, ops)
+		}
+		if added, removed := Stats(ops); added != tc.added || removed != tc.removed {
+			t.Errorf("Lines(%q, %q) : +%d -%d, attendu +%d -%d", tc.a, tc.b, added, removed, tc.added, tc.removed)
+		}
+	}
+}
+
+func TestUnified(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n"
+	want := `--- v1
++++ v2
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -10,3 +10,4 @@
+ 10
+ 11
+ 12
++13
+`
+	if got := Unified(Lines(a, b), "v1", "v2", 3); got != want {
+		t.Errorf("Unified =\n%s\nattendu\n%s", got, want)
+	}
+	if got := Unified(Lines(a, a), "v1", "v2", 3); got != "" {
+		t.Errorf("Unified de textes identiques = %q", got)
+	}
+	if got := Unified(Lines("", "x\n"), "v1", "v2", 3); got != "--- v1\n+++ v2\n@@ -0,0 +1 @@\n+x\n" {
+		t.Errorf("Unified depuis un texte vide = %q", got)
+	}
+}
@@ -1,6 +1,7 @@
 package bleve
 import (
 	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/mapping"
 )
 
 func InitBleveIndex() (bleve.Index, error) {
@@ -11,3 +12,35 @@ func InitBleveIndex() (bleve.Index, error) {
 	}
 	return index, err
 }
+
+// InitDocsIndex ouvre l'index des documents, créé vide au premier démarrage ;
+// created signale qu'il faut le remplir depuis la table docs
+func InitDocsIndex() (index bleve.Index, created bool, err error) {
+	index, err = bleve.Open("docs_index.bleve")
+	if err == bleve.ErrorIndexPathDoesNotExist {
+		index, err = bleve.New("docs_index.bleve", DocsIndexMapping())
+		created = true
+	}
+	return index, created, err
+}
+
+// DocsIndexMapping décrit l'index des documents : titre et corps (texte
+// brut, sans Markdown) analysés et conservés pour les extraits, chemin et
+// sous-arbre (ids du document et de ses ancêtres) pris tels quels
+func DocsIndexMapping() mapping.IndexMapping {
+	text := bleve.NewTextFieldMapping()
+	text.IncludeTermVectors = true
+	keyword := bleve.NewKeywordFieldMapping()
+	subtree := bleve.NewKeywordFieldMapping()
+	subtree.Store = false
+
+	doc := bleve.NewDocumentMapping()
+	doc.AddFieldMappingsAt("title", text)
+	doc.AddFieldMappingsAt("body", text)
+	doc.AddFieldMappingsAt("path", keyword)
+	doc.AddFieldMappingsAt("subtree", subtree)
+
+	m := bleve.NewIndexMapping()
+	m.DefaultMapping = doc
+	return m
+}
@@ -0,0 +1,234 @@
+// Package client est le SDK Go du service Baki IPFS. Il couvre les routes /v1
+// (fichiers, thèmes d'animation et documentation) avec des méthodes typées,
+// la prise en charge de context.Context, des tentatives répétées avec backoff
+// pour les requêtes idempotentes et des erreurs typées.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultMaxAttempts est le nombre de tentatives par défaut d'une requête
+// idempotente (première tentative comprise)
+const DefaultMaxAttempts = 3
+
+// DefaultBaseDelay est le délai avant la première nouvelle tentative ; il
+// double ensuite à chaque tentative
+const DefaultBaseDelay = 200 * time.Millisecond
+
+// Client appelle l'API du service
+type Client struct {
+	baseURL     *url.URL
+	apiKey      string
+	httpClient  *http.Client
+	maxAttempts int
+	baseDelay   time.Duration
+}
+
+// Option configure un Client
+type Option func(*Client)
+
+// WithHTTPClient remplace le client HTTP utilisé (http.DefaultClient par défaut)
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithRetry configure le nombre maximal de tentatives et le délai de base du
+// backoff exponentiel. maxAttempts <= 1 désactive les nouvelles tentatives.
+func WithRetry(maxAttempts int, baseDelay time.Duration) Option {
+	return func(c *Client) {
+		c.maxAttempts = maxAttempts
+		c.baseDelay = baseDelay
+	}
+}
+
+// New crée un client pour le service à l'URL baseURL (ex.
+// "https://ipfs.example.com"). apiKey est envoyée dans l'en-tête X-API-Key ;
+// elle peut être vide pour les routes publiques.
+func New(baseURL, apiKey string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, errors.New("client: baseURL must be an absolute URL")
+	}
+
+	c := &Client{
+		baseURL:     u,
+		apiKey:      apiKey,
+		httpClient:  http.DefaultClient,
+		maxAttempts: DefaultMaxAttempts,
+		baseDelay:   DefaultBaseDelay,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// request décrit un appel à l'API
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	header http.Header
+	// body est relu à chaque tentative ; bodyStream n'est envoyé qu'une fois
+	body       []byte
+	bodyStream io.Reader
+	// idempotent autorise les nouvelles tentatives
+	idempotent bool
+}
+
+// endpoint construit l'URL d'un chemin d'API (les segments doivent déjà être
+// échappés)
+func (c *Client) endpoint(path string, query url.Values) string {
+	u := *c.baseURL
+	u.Path = c.baseURL.Path + path
+	if len(query) > 0 {
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+// do exécute la requête, avec de nouvelles tentatives si elle est idempotente,
+// et renvoie la réponse si son statut est 2xx. L'appelant ferme le corps.
+func (c *Client) do(ctx context.Context, req request) (*http.Response, error) {
+	attempts := 1
+	if req.idempotent && req.bodyStream == nil && c.maxAttempts > 1 {
+		attempts = c.maxAttempts
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if err := sleep(ctx, c.backoff(attempt)); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := c.send(ctx, req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = err
+			continue
+		}
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return resp, nil
+		}
+
+		apiErr := newError(resp)
+		if !retryable(resp.StatusCode) {
+			return nil, apiErr
+		}
+		lastErr = apiErr
+	}
+	return nil, lastErr
+}
+
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	var body io.Reader
+	switch {
+	case req.bodyStream != nil:
+		body = req.bodyStream
+	case req.body != nil:
+		body = bytes.NewReader(req.body)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, c.endpoint(req.path, req.query), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range req.header {
+		httpReq.Header[k] = v
+	}
+	if req.body != nil && httpReq.Header.Get("Content-Type") == "" {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		httpReq.Header.Set("X-API-Key", c.apiKey)
+	}
+	return c.httpClient.Do(httpReq)
+}
+
+// backoff renvoie le délai avant la tentative n (n >= 1) : délai de base
+// doublé à chaque tentative, avec une gigue de ±25 %
+func (c *Client) backoff(n int) time.Duration {
+	d := c.baseDelay << (n - 1)
+	jitter := time.Duration(rand.Int63n(int64(d)/2+1)) - d/4
+	return d + jitter
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// retryable indique si une réponse d'erreur peut être retentée
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout, http.StatusInternalServerError:
+		return true
+	}
+	return false
+}
+
+// doJSON exécute la requête et décode la réponse JSON dans out (si non nil)
+func (c *Client) doJSON(ctx context.Context, req request, out interface{}) error {
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// doJSONETag exécute la requête comme doJSON et renvoie l'ETag de la réponse
+func (c *Client) doJSONETag(ctx context.Context, req request, out interface{}) (string, error) {
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	return resp.Header.Get("ETag"), json.NewDecoder(resp.Body).Decode(out)
+}
+
+// ifMatch conditionne une mise à jour à l'ETag lu, s'il est connu
+func ifMatch(etag string) http.Header {
+	if etag == "" {
+		return nil
+	}
+	return http.Header{"If-Match": {etag}}
+}
+
+// mergePatchHeader annonce un corps JSON Merge Patch (RFC 7396)
+func mergePatchHeader() http.Header {
+	return http.Header{"Content-Type": {"application/merge-patch+json"}}
+}
+
+// jsonBody encode v pour l'envoyer en corps de requête
+func jsonBody(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
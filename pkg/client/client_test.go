@@ -0,0 +1,306 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/router"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/testenv"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/pkg/client"
+)
+
+const (
+	writeKey = testenv.WriteKey
+	readKey  = testenv.ReadKey
+)
+
+// newServer démarre les vrais handlers dans un environnement de test hors ligne
+func newServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(router.New(testenv.New(t).Handler))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newClient(t *testing.T, srv *httptest.Server, apiKey string) *client.Client {
+	t.Helper()
+	c, err := client.New(srv.URL, apiKey, client.WithRetry(3, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestUploadDownloadAndPrivacy(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t, newServer(t), writeKey)
+
+	content := []byte("hello ipfs")
+	up, err := c.Upload(ctx, "hello.txt", bytes.NewReader(content), client.UploadOptions{ContentType: "text/plain"})
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if up.CID == "" {
+		t.Fatal("Upload: CID vide")
+	}
+
+	d, err := c.Download(ctx, up.CID)
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	got, _ := io.ReadAll(d)
+	d.Close()
+	if !bytes.Equal(got, content) || d.FileName != "hello.txt" || d.ContentType != "text/plain" {
+		t.Errorf("Download = %q (%s, %s)", got, d.FileName, d.ContentType)
+	}
+
+	res, err := c.TogglePrivacy(ctx, up.CID)
+	if err != nil || !res.IsPrivate {
+		t.Fatalf("TogglePrivacy = %+v, %v", res, err)
+	}
+	if _, err := c.Display(ctx, up.CID); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("Display d'un fichier privé : err = %v, attendu ErrNotFound", err)
+	}
+
+	if err := c.DeleteFile(ctx, up.CID); err != nil {
+		t.Fatalf("DeleteFile: %v", err)
+	}
+	if _, err := c.Download(ctx, up.CID); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("Download après suppression : err = %v, attendu ErrNotFound", err)
+	}
+}
+
+func TestFileIterator(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t, newServer(t), writeKey)
+
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("f%d.txt", i)
+		if _, err := c.Upload(ctx, name, bytes.NewBufferString(name), client.UploadOptions{}); err != nil {
+			t.Fatalf("Upload: %v", err)
+		}
+	}
+
+	it := c.PublicFiles(2)
+	var names []string
+	for it.Next(ctx) {
+		names = append(names, it.File().FileName)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("itération : %v", err)
+	}
+	if len(names) != 5 {
+		t.Errorf("itération : %d fichiers (%v), attendu 5", len(names), names)
+	}
+
+	results, err := c.SearchPublicFiles(ctx, "f3.txt", 1, 10)
+	if err != nil {
+		t.Fatalf("SearchPublicFiles: %v", err)
+	}
+	if results.Total == 0 {
+		t.Error("SearchPublicFiles : aucun résultat")
+	}
+}
+
+func TestDotLottieConversion(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t, newServer(t), writeKey)
+
+	animation := `{"v":"5.7.4","fr":30,"ip":0,"op":60,"w":64,"h":64,"layers":[{"ty":4}]}`
+	up, err := c.Upload(ctx, "wave.json", bytes.NewBufferString(animation), client.UploadOptions{ContentType: "application/json"})
+	if err != nil || up.Kind != "lottie" {
+		t.Fatalf("Upload = %+v, %v", up, err)
+	}
+
+	conv, err := c.ConvertToDotLottie(ctx, up.CID, client.ConvertOptions{AnimationID: "wave"})
+	if err != nil || conv.Kind != "dotlottie" || conv.AnimationCount != 1 {
+		t.Fatalf("ConvertToDotLottie = %+v, %v", conv, err)
+	}
+	manifest, err := c.DotLottieManifest(ctx, conv.CID)
+	if err != nil || manifest.ActiveAnimationID != "wave" || manifest.Animations[0].Duration != 2 {
+		t.Fatalf("DotLottieManifest = %+v, %v", manifest, err)
+	}
+	got, err := c.LottieAnimation(ctx, conv.CID, "wave")
+	if err != nil || string(got) != animation {
+		t.Errorf("LottieAnimation = %s, %v", got, err)
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	ctx := context.Background()
+	srv := newServer(t)
+
+	if _, err := newClient(t, srv, "").ListPublicFiles(ctx, 1, 10); !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("sans API key : err = %v, attendu ErrUnauthorized", err)
+	}
+
+	_, err := newClient(t, srv, readKey).CreateTheme(ctx, client.Theme{CID: "x", Name: "y"})
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden || !errors.Is(err, client.ErrForbidden) {
+		t.Errorf("CreateTheme en lecture seule : err = %v, attendu 403", err)
+	}
+
+	if _, err := newClient(t, srv, "").GetDoc(ctx, 42); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("GetDoc inconnu : err = %v, attendu ErrNotFound", err)
+	}
+}
+
+func TestDocsAndThemes(t *testing.T) {
+	ctx := context.Background()
+	srv := newServer(t)
+	c := newClient(t, srv, writeKey)
+
+	doc, err := c.CreateDoc(ctx, client.Doc{Title: "Intro", Path: "/intro", DocSrc: "# Intro", Version: 1})
+	if err != nil || doc.Status != client.DocDraft {
+		t.Fatalf("CreateDoc: %v", err)
+	}
+	doc.Title = "Introduction"
+	if err := c.UpdateDoc(ctx, *doc); err != nil {
+		t.Fatalf("UpdateDoc: %v", err)
+	}
+	got, err := c.GetDoc(ctx, doc.ID)
+	if err != nil || got.Title != "Introduction" || got.ETag == "" || got.ETag == doc.ETag {
+		t.Fatalf("GetDoc = %+v, %v", got, err)
+	}
+	if err := c.UpdateDoc(ctx, *doc); !errors.Is(err, client.ErrPreconditionFailed) {
+		t.Errorf("UpdateDoc avec un ETag périmé : err = %v, attendu ErrPreconditionFailed", err)
+	}
+	if err := c.PatchDoc(ctx, doc.ID, map[string]interface{}{"doc_src": "# Intro v2"}, got.ETag); err != nil {
+		t.Fatalf("PatchDoc: %v", err)
+	}
+	if got, err := c.GetDoc(ctx, doc.ID); err != nil || got.Title != "Introduction" || got.DocSrc != "# Intro v2" {
+		t.Fatalf("GetDoc après PatchDoc = %+v, %v", got, err)
+	}
+	child, err := c.CreateDoc(ctx, client.Doc{Title: "Setup", Path: "/setup", DocSrc: "x", ParentID: &doc.ID})
+	if err != nil || !child.IsChildren {
+		t.Fatalf("CreateDoc(enfant) = %+v, %v", child, err)
+	}
+	if nav, err := c.GetDocByPath(ctx, "/setup"); err != nil || nav.Doc.ID != child.ID || len(nav.Breadcrumbs) != 1 || nav.Breadcrumbs[0].ID != doc.ID {
+		t.Fatalf("GetDocByPath = %+v, %v", nav, err)
+	}
+	if rendered, err := c.GetDocHTML(ctx, doc.ID); err != nil || !strings.Contains(rendered.HTML, `<h1 id="intro-v2">`) ||
+		len(rendered.TOC) != 1 || rendered.TOC[0].ID != "intro-v2" || rendered.Title != "Introduction" {
+		t.Fatalf("GetDocHTML = %+v, %v", rendered, err)
+	}
+	if _, err := newClient(t, srv, "").GetDoc(ctx, doc.ID); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("GetDoc d'un brouillon sans clé : err = %v, attendu ErrNotFound", err)
+	}
+	if found, err := c.SearchDocs(ctx, "intro", doc.ID, 0, 0); err != nil || found.Total != 1 || found.Results[0].ID != doc.ID ||
+		len(found.Results[0].Fragments["title"]) == 0 {
+		t.Fatalf("SearchDocs = %+v, %v", found, err)
+	}
+	tree, err := c.DocTree(ctx, doc.ID, 0)
+	if err != nil || len(tree) != 1 || len(tree[0].Children) != 1 || tree[0].Children[0].ID != child.ID {
+		t.Fatalf("DocTree = %+v, %v", tree, err)
+	}
+	archive, err := c.ExportDocsSite(ctx, "tar", client.DocSiteOptions{Title: "Bakiverse"})
+	if err != nil || archive.FileName != "docs-site.tar" || archive.ContentType != "application/x-tar" {
+		t.Fatalf("ExportDocsSite = %+v, %v", archive, err)
+	}
+	archive.Close()
+	// Les brouillons restent hors du site
+	if site, err := c.PublishDocsSite(ctx, client.DocSiteOptions{}); err != nil || site.CID == "" || site.Docs != 0 || site.Files != 3 {
+		t.Fatalf("PublishDocsSite = %+v, %v", site, err)
+	}
+	if _, err := c.MoveDoc(ctx, doc.ID, &child.ID, 0); !errors.Is(err, client.ErrConflict) {
+		t.Errorf("MoveDoc sous un descendant : err = %v, attendu ErrConflict", err)
+	}
+	if err := c.DeleteDoc(ctx, doc.ID); !errors.Is(err, client.ErrConflict) {
+		t.Errorf("DeleteDoc d'un parent : err = %v, attendu ErrConflict", err)
+	}
+	deletion, err := c.DeleteDocTree(ctx, doc.ID, client.DeleteCascade)
+	if err != nil || len(deletion.Deleted) != 2 {
+		t.Fatalf("DeleteDocTree = %+v, %v", deletion, err)
+	}
+
+	animation := []byte(`{"v":"5.7.4","fr":30,"ip":0,"op":60,"w":64,"h":64,"layers":[{"ty":4}]}`)
+	up, err := c.Upload(ctx, "loader.json", bytes.NewReader(animation), client.UploadOptions{ContentType: "application/json"})
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	name := "Loader"
+	if f, err := c.UpdateFileDetails(ctx, up.CID, client.FileDetailsPatch{DisplayName: &name, Tags: []string{"UI"}}); err != nil || f.DisplayName != "Loader" || len(f.Tags) != 1 || f.Tags[0] != "ui" {
+		t.Fatalf("UpdateFileDetails = %+v, %v", f, err)
+	}
+	theme, err := c.CreateTheme(ctx, client.Theme{CID: up.CID, Name: "dark"})
+	if err != nil || theme.ID == 0 {
+		t.Fatalf("CreateTheme = %+v, %v", theme, err)
+	}
+	if _, err := c.CreateTheme(ctx, client.Theme{CID: up.CID, Name: "dark"}); !errors.Is(err, client.ErrConflict) {
+		t.Errorf("CreateTheme en double : err = %v, attendu ErrConflict", err)
+	}
+	theme.Name = "light"
+	if updated, err := c.UpdateTheme(ctx, *theme); err != nil || updated.Name != "light" {
+		t.Fatalf("UpdateTheme = %+v, %v", updated, err)
+	}
+	if _, err := c.UpdateTheme(ctx, *theme); !errors.Is(err, client.ErrPreconditionFailed) {
+		t.Errorf("UpdateTheme avec un ETag périmé : err = %v, attendu ErrPreconditionFailed", err)
+	}
+	themes, err := c.ListThemes(ctx, client.ThemeFilter{}, 1, 10)
+	if err != nil || themes.Total != 1 || themes.Themes[0].Name != "light" || !*themes.Themes[0].Enabled {
+		t.Fatalf("ListThemes = %+v, %v", themes, err)
+	}
+	if got, err := c.GetTheme(ctx, theme.ID); err != nil || got.Name != "light" {
+		t.Fatalf("GetTheme = %+v, %v", got, err)
+	}
+
+	bundle, err := c.ThemeBundle(ctx, theme.ID, "")
+	if err != nil || bundle.ETag == "" || string(bundle.Files[up.CID].Animation) != string(animation) {
+		t.Fatalf("ThemeBundle = %+v, %v", bundle, err)
+	}
+	if _, err := c.ThemeBundle(ctx, theme.ID, bundle.ETag); !errors.Is(err, client.ErrNotModified) {
+		t.Errorf("ThemeBundle(etag) : err = %v, attendu ErrNotModified", err)
+	}
+
+	if err := c.DeleteFile(ctx, up.CID); !errors.Is(err, client.ErrConflict) {
+		t.Errorf("DeleteFile d'une animation utilisée : err = %v, attendu ErrConflict", err)
+	}
+	orphaned, err := c.ForceDeleteFile(ctx, up.CID)
+	if err != nil || len(orphaned) != 1 || orphaned[0].ID != theme.ID {
+		t.Fatalf("ForceDeleteFile = %+v, %v", orphaned, err)
+	}
+	if err := c.DeleteTheme(ctx, theme.ID); err != nil {
+		t.Fatalf("DeleteTheme: %v", err)
+	}
+	if err := c.DeleteTheme(ctx, theme.ID); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("DeleteTheme(supprimé) : err = %v, attendu ErrNotFound", err)
+	}
+}
+
+func TestRetryOnServerError(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			http.Error(w, "indisponible", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"themes":[]}`))
+	}))
+	defer srv.Close()
+
+	c := newClient(t, srv, "")
+	if _, err := c.ListThemes(context.Background(), client.ThemeFilter{}, 0, 0); err != nil {
+		t.Fatalf("ListThemes: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("%d appels, attendu 3", calls)
+	}
+
+	// Les requêtes non idempotentes ne sont pas retentées
+	atomic.StoreInt32(&calls, 0)
+	if _, err := c.TogglePrivacy(context.Background(), "x"); !errors.Is(err, client.ErrServer) {
+		t.Errorf("TogglePrivacy : err = %v, attendu ErrServer", err)
+	}
+	if calls != 1 {
+		t.Errorf("TogglePrivacy : %d appels, attendu 1", calls)
+	}
+}
@@ -0,0 +1,474 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Doc est une page de documentation
+type Doc struct {
+	ID         int     `json:"id"`
+	Title      string  `json:"title"`
+	Path       string  `json:"path"`
+	DocSrc     string  `json:"doc_src"`
+	Version    float64 `json:"version"`     // numéro de la dernière révision, fixé par le serveur
+	CID        string  `json:"cid"`         // CID du contenu sur IPFS, fixé par le serveur
+	IsChildren bool    `json:"is_children"` // dérivé de ParentID par le serveur
+	ParentID   *int    `json:"parent_id"`
+	Position   int     `json:"position"`
+	// Status est DocDraft, DocReview, DocPublished ou DocArchived ; vide, il
+	// vaut DocDraft à la création et reste inchangé à la mise à jour
+	Status string `json:"status,omitempty"`
+	// PublishAt et UnpublishAt bornent la visibilité d'un document publié
+	PublishAt   *time.Time `json:"publish_at"`
+	UnpublishAt *time.Time `json:"unpublish_at"`
+	CreatedAt   string     `json:"created_at,omitempty"`
+	UpdatedAt   string     `json:"updated_at,omitempty"`
+	// ETag est la version lue par GetDoc ou CreateDoc ; UpdateDoc l'envoie
+	// en If-Match et échoue avec ErrPreconditionFailed si le document a
+	// changé depuis
+	ETag string `json:"-"`
+}
+
+// Statuts d'un document. Sans clé en écriture, seuls les documents publiés,
+// dans leur fenêtre de publication et sous des ancêtres visibles, sont lus.
+const (
+	DocDraft     = "draft"
+	DocReview    = "review"
+	DocPublished = "published"
+	DocArchived  = "archived"
+)
+
+// DocNode est un document de l'arborescence, sans son contenu
+type DocNode struct {
+	ID          int       `json:"id"`
+	Title       string    `json:"title"`
+	Path        string    `json:"path"`
+	Version     float64   `json:"version"`
+	ParentID    *int      `json:"parent_id"`
+	Position    int       `json:"position"`
+	Status      string    `json:"status"`
+	UpdatedAt   string    `json:"updated_at"`
+	HasChildren bool      `json:"has_children"`
+	Children    []DocNode `json:"children"`
+}
+
+// ListDocs renvoie tous les documents lisibles par la clé du client
+func (c *Client) ListDocs(ctx context.Context) ([]Doc, error) {
+	var out []Doc
+	err := c.doJSON(ctx, request{method: http.MethodGet, path: "/v1/docs", idempotent: true}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetDoc renvoie un document
+func (c *Client) GetDoc(ctx context.Context, id int) (*Doc, error) {
+	var out Doc
+	etag, err := c.doJSONETag(ctx, request{method: http.MethodGet, path: docPath(id), idempotent: true}, &out)
+	if err != nil {
+		return nil, err
+	}
+	out.ETag = etag
+	return &out, nil
+}
+
+// DocHeading est une entrée de la table des matières d'un document rendu
+type DocHeading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	ID    string `json:"id"`
+}
+
+// RenderedDoc est un document accompagné de son rendu HTML assaini
+type RenderedDoc struct {
+	Doc
+	HTML string       `json:"html"`
+	TOC  []DocHeading `json:"toc"`
+}
+
+// GetDocHTML renvoie un document avec son Markdown rendu en HTML par le
+// serveur et sa table des matières
+func (c *Client) GetDocHTML(ctx context.Context, id int) (*RenderedDoc, error) {
+	var out RenderedDoc
+	err := c.doJSON(ctx, request{method: http.MethodGet, path: docPath(id), query: url.Values{"format": {"html"}}, idempotent: true}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DocLink désigne un document dans la navigation
+type DocLink struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+	Path  string `json:"path"`
+}
+
+// DocNavigation est un document trouvé par son chemin, avec ses ancêtres
+// (du premier niveau au parent) et ses frères précédent et suivant (nil en
+// bout de liste)
+type DocNavigation struct {
+	Doc         Doc       `json:"doc"`
+	Breadcrumbs []DocLink `json:"breadcrumbs"`
+	Prev        *DocLink  `json:"prev"`
+	Next        *DocLink  `json:"next"`
+}
+
+// GetDocByPath renvoie le document de chemin path. Si ce chemin existe sous
+// plusieurs parents, l'erreur satisfait errors.Is(err, ErrConflict) et
+// GetDocByPathUnder lève l'ambiguïté.
+func (c *Client) GetDocByPath(ctx context.Context, path string) (*DocNavigation, error) {
+	return c.getDocByPath(ctx, url.Values{"path": {path}})
+}
+
+// GetDocByPathUnder renvoie le document de chemin path enfant de parentID
+// (0 : premier niveau)
+func (c *Client) GetDocByPathUnder(ctx context.Context, path string, parentID int) (*DocNavigation, error) {
+	return c.getDocByPath(ctx, url.Values{"path": {path}, "parent_id": {strconv.Itoa(parentID)}})
+}
+
+func (c *Client) getDocByPath(ctx context.Context, q url.Values) (*DocNavigation, error) {
+	var out DocNavigation
+	err := c.doJSON(ctx, request{method: http.MethodGet, path: "/v1/docs/by-path", query: q, idempotent: true}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DocSearchHit est un document trouvé par SearchDocs
+type DocSearchHit struct {
+	ID    int     `json:"id"`
+	Title string  `json:"title"`
+	Path  string  `json:"path"`
+	Score float64 `json:"score"`
+	// Fragments donne, par champ (title, body), des extraits HTML où les
+	// termes trouvés sont entourés de <mark>
+	Fragments map[string][]string `json:"fragments"`
+}
+
+// DocSearchPage est une page de résultats de SearchDocs
+type DocSearchPage struct {
+	Results    []DocSearchHit `json:"results"`
+	Total      int            `json:"total"`
+	TotalPages int            `json:"totalPages"`
+}
+
+// SearchDocs recherche query dans le titre et le contenu des documents ;
+// root > 0 limite la recherche à ce document et à ses descendants
+func (c *Client) SearchDocs(ctx context.Context, query string, root, page, limit int) (*DocSearchPage, error) {
+	q := pageQuery(page, limit)
+	q.Set("q", query)
+	if root > 0 {
+		q.Set("root", strconv.Itoa(root))
+	}
+
+	var out DocSearchPage
+	err := c.doJSON(ctx, request{method: http.MethodGet, path: "/v1/docs/search", query: q, idempotent: true}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetDocAtCID renvoie un document tel qu'il était dans sa révision de
+// contenu cid ; le serveur lit ce contenu sur IPFS
+func (c *Client) GetDocAtCID(ctx context.Context, id int, cid string) (*Doc, error) {
+	var out Doc
+	err := c.doJSON(ctx, request{method: http.MethodGet, path: docPath(id), query: url.Values{"cid": {cid}}, idempotent: true}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PublishedDoc décrit un document d'un répertoire publié
+type PublishedDoc struct {
+	ID       int     `json:"id"`
+	Title    string  `json:"title"`
+	Path     string  `json:"path"`
+	Version  float64 `json:"version"`
+	CID      string  `json:"cid"`
+	File     string  `json:"file"`
+	ParentID *int    `json:"parent_id"`
+	Position int     `json:"position"`
+}
+
+// DocPublication est le résultat de PublishDocs
+type DocPublication struct {
+	CID  string         `json:"cid"`
+	Root *int           `json:"root"`
+	Docs []PublishedDoc `json:"docs"`
+}
+
+// PublishDocs publie l'arborescence des documents, ou le sous-arbre du
+// document root si root > 0, dans un répertoire IPFS dont il renvoie le CID
+func (c *Client) PublishDocs(ctx context.Context, root int) (*DocPublication, error) {
+	q := url.Values{}
+	if root > 0 {
+		q.Set("root", strconv.Itoa(root))
+	}
+	var out DocPublication
+	if err := c.doJSON(ctx, request{method: http.MethodPost, path: "/v1/docs/publish", query: q}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DocSiteOptions règle l'export du site statique des documents
+type DocSiteOptions struct {
+	// Title est le nom du site (« Documentation » par défaut)
+	Title string
+	// Gateway préfixe les URL des fichiers IPFS référencés par les documents
+	Gateway string
+}
+
+func (o DocSiteOptions) query(format string) url.Values {
+	q := url.Values{"format": {format}}
+	if o.Title != "" {
+		q.Set("title", o.Title)
+	}
+	if o.Gateway != "" {
+		q.Set("gateway", o.Gateway)
+	}
+	return q
+}
+
+// DocSiteExport est le résultat de PublishDocsSite
+type DocSiteExport struct {
+	CID   string `json:"cid"`
+	Docs  int    `json:"docs"`
+	Files int    `json:"files"`
+}
+
+// ExportDocsSite génère le site statique des documents visibles et ouvre
+// l'archive obtenue ; format vaut "zip" ou "tar"
+func (c *Client) ExportDocsSite(ctx context.Context, format string, opts DocSiteOptions) (*Download, error) {
+	resp, err := c.do(ctx, request{method: http.MethodPost, path: "/v1/docs/export", query: opts.query(format)})
+	if err != nil {
+		return nil, err
+	}
+	return newDownload(resp), nil
+}
+
+// PublishDocsSite ajoute le site statique des documents visibles sur IPFS et
+// renvoie le CID de son répertoire
+func (c *Client) PublishDocsSite(ctx context.Context, opts DocSiteOptions) (*DocSiteExport, error) {
+	var out DocSiteExport
+	if err := c.doJSON(ctx, request{method: http.MethodPost, path: "/v1/docs/export", query: opts.query("ipfs")}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DocTree renvoie l'arborescence des documents, ou le sous-arbre du
+// document root si root > 0, sur depth niveaux (0 : sans limite)
+func (c *Client) DocTree(ctx context.Context, root, depth int) ([]DocNode, error) {
+	q := url.Values{}
+	if root > 0 {
+		q.Set("root", strconv.Itoa(root))
+	}
+	if depth > 0 {
+		q.Set("depth", strconv.Itoa(depth))
+	}
+	var out struct {
+		Nodes []DocNode `json:"nodes"`
+	}
+	err := c.doJSON(ctx, request{method: http.MethodGet, path: "/v1/docs/tree", query: q, idempotent: true}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return out.Nodes, nil
+}
+
+// CreateDoc crée un document et renvoie le document enregistré
+func (c *Client) CreateDoc(ctx context.Context, doc Doc) (*Doc, error) {
+	body, err := jsonBody(doc)
+	if err != nil {
+		return nil, err
+	}
+	var out Doc
+	etag, err := c.doJSONETag(ctx, request{method: http.MethodPost, path: "/v1/docs", body: body}, &out)
+	if err != nil {
+		return nil, err
+	}
+	out.ETag = etag
+	return &out, nil
+}
+
+// UpdateDoc remplace le document doc.ID, à condition qu'il n'ait pas changé
+// depuis doc.ETag si celui-ci est renseigné. En cas d'échec de la condition,
+// l'Error renvoyée porte dans Body la version actuelle du document.
+func (c *Client) UpdateDoc(ctx context.Context, doc Doc) error {
+	body, err := jsonBody(doc)
+	if err != nil {
+		return err
+	}
+	return c.doJSON(ctx, request{method: http.MethodPut, path: docPath(doc.ID), header: ifMatch(doc.ETag), body: body, idempotent: true}, nil)
+}
+
+// PatchDoc modifie les seuls champs de patch (JSON Merge Patch : une valeur
+// nil efface le champ, parent_id nil rattache au premier niveau). Si etag
+// n'est pas vide, la modification échoue avec ErrPreconditionFailed quand le
+// document a changé depuis.
+func (c *Client) PatchDoc(ctx context.Context, id int, patch map[string]interface{}, etag string) error {
+	body, err := jsonBody(patch)
+	if err != nil {
+		return err
+	}
+	header := mergePatchHeader()
+	if etag != "" {
+		header.Set("If-Match", etag)
+	}
+	return c.doJSON(ctx, request{method: http.MethodPatch, path: docPath(id), header: header, body: body}, nil)
+}
+
+// MoveDoc rattache un document à parentID (nil : premier niveau) au rang
+// position parmi ses nouveaux frères (1 : en tête, 0 : à la fin). Un
+// déplacement sous l'un de ses descendants renvoie ErrConflict.
+func (c *Client) MoveDoc(ctx context.Context, id int, parentID *int, position int) (*Doc, error) {
+	body, err := jsonBody(map[string]interface{}{"parent_id": parentID, "position": position})
+	if err != nil {
+		return nil, err
+	}
+	var out Doc
+	err = c.doJSON(ctx, request{method: http.MethodPost, path: docPath(id) + "/move", body: body, idempotent: true}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ReorderDocs fixe l'ordre des enfants de parentID (nil : premier niveau) ;
+// ids doit les lister tous, une fois chacun
+func (c *Client) ReorderDocs(ctx context.Context, parentID *int, ids []int) error {
+	body, err := jsonBody(map[string]interface{}{"parent_id": parentID, "ids": ids})
+	if err != nil {
+		return err
+	}
+	return c.doJSON(ctx, request{method: http.MethodPost, path: "/v1/docs/reorder", body: body, idempotent: true}, nil)
+}
+
+// Modes de suppression d'un document qui a des enfants
+const (
+	DeleteRefuse   = "refuse"
+	DeleteCascade  = "cascade"
+	DeleteReparent = "reparent"
+)
+
+// DocDeletion rapporte les documents touchés par une suppression
+type DocDeletion struct {
+	Deleted    []int `json:"deleted"`
+	Reparented []int `json:"reparented"`
+}
+
+// DeleteDoc supprime un document sans enfant ; s'il en a, renvoie
+// ErrConflict (voir DeleteDocTree)
+func (c *Client) DeleteDoc(ctx context.Context, id int) error {
+	return c.doJSON(ctx, request{method: http.MethodDelete, path: docPath(id), idempotent: true}, nil)
+}
+
+// DeleteDocTree supprime un document selon mode (DeleteRefuse,
+// DeleteCascade ou DeleteReparent) et rapporte les documents touchés
+func (c *Client) DeleteDocTree(ctx context.Context, id int, mode string) (*DocDeletion, error) {
+	var out DocDeletion
+	err := c.doJSON(ctx, request{method: http.MethodDelete, path: docPath(id), query: url.Values{"mode": {mode}}, idempotent: true}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DocRevision est un état enregistré d'un document
+type DocRevision struct {
+	ID           int    `json:"id"`
+	DocID        int    `json:"doc_id"`
+	Version      int    `json:"version"`
+	Title        string `json:"title"`
+	Path         string `json:"path"`
+	DocSrc       string `json:"doc_src"` // vide dans les listes
+	CID          string `json:"cid"`
+	APIKeyID     int    `json:"api_key_id"` // 0 sans clé en écriture
+	Status       string `json:"status"`
+	RestoredFrom int    `json:"restored_from"`
+	CreatedAt    string `json:"created_at"`
+}
+
+// DocRevisionPage est une page de l'historique d'un document
+type DocRevisionPage struct {
+	Revisions  []DocRevision `json:"revisions"`
+	Total      int           `json:"total"`
+	Page       int           `json:"page"`
+	Limit      int           `json:"limit"`
+	TotalPages int           `json:"totalPages"`
+}
+
+// DocDiff compare le contenu de deux révisions
+type DocDiff struct {
+	From      int    `json:"from"`
+	To        int    `json:"to"`
+	FromTitle string `json:"from_title"`
+	ToTitle   string `json:"to_title"`
+	Added     int    `json:"added"`
+	Removed   int    `json:"removed"`
+	Diff      string `json:"diff"` // format unifié
+}
+
+// ListDocRevisions renvoie une page de l'historique d'un document, la
+// révision la plus récente d'abord
+func (c *Client) ListDocRevisions(ctx context.Context, id, page, limit int) (*DocRevisionPage, error) {
+	var out DocRevisionPage
+	err := c.doJSON(ctx, request{method: http.MethodGet, path: docPath(id) + "/revisions", query: pageQuery(page, limit), idempotent: true}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetDocRevision renvoie une révision complète d'un document
+func (c *Client) GetDocRevision(ctx context.Context, id, version int) (*DocRevision, error) {
+	var out DocRevision
+	err := c.doJSON(ctx, request{method: http.MethodGet, path: revisionPath(id, version), idempotent: true}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DiffDocRevisions compare les révisions from et to (0 : version actuelle)
+func (c *Client) DiffDocRevisions(ctx context.Context, id, from, to int) (*DocDiff, error) {
+	q := url.Values{"from": {strconv.Itoa(from)}}
+	if to > 0 {
+		q.Set("to", strconv.Itoa(to))
+	}
+	var out DocDiff
+	err := c.doJSON(ctx, request{method: http.MethodGet, path: docPath(id) + "/revisions/diff", query: q, idempotent: true}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RestoreDocRevision rétablit une révision dans une nouvelle révision et
+// renvoie le document
+func (c *Client) RestoreDocRevision(ctx context.Context, id, version int) (*Doc, error) {
+	var out Doc
+	err := c.doJSON(ctx, request{method: http.MethodPost, path: revisionPath(id, version) + "/restore"}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func revisionPath(id, version int) string {
+	return docPath(id) + "/revisions/" + strconv.Itoa(version)
+}
+
+func docPath(id int) string {
+	return "/v1/docs/" + strconv.Itoa(id)
+}
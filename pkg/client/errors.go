@@ -0,0 +1,80 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Erreurs sentinelles correspondant aux codes d'erreur du service. Elles
+// s'utilisent avec errors.Is sur les erreurs renvoyées par le client.
+var (
+	ErrNotModified        = errors.New("not modified")
+	ErrBadRequest         = errors.New("bad request")
+	ErrUnauthorized       = errors.New("unauthorized")
+	ErrForbidden          = errors.New("forbidden")
+	ErrNotFound           = errors.New("not found")
+	ErrMethodNotAllowed   = errors.New("method not allowed")
+	ErrConflict           = errors.New("conflict")
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrServer             = errors.New("server error")
+)
+
+// Error est l'erreur renvoyée lorsque le service répond avec un statut non 2xx
+type Error struct {
+	StatusCode int
+	// Message est le corps de la réponse d'erreur renvoyé par le service
+	Message string
+	// Body contient le corps brut de la réponse
+	Body []byte
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("client: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("client: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Is permet errors.Is(err, ErrNotFound) et les autres sentinelles
+func (e *Error) Is(target error) bool {
+	return sentinel(e.StatusCode) == target
+}
+
+func sentinel(status int) error {
+	switch {
+	case status == http.StatusNotModified:
+		return ErrNotModified
+	case status == http.StatusBadRequest:
+		return ErrBadRequest
+	case status == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case status == http.StatusForbidden:
+		return ErrForbidden
+	case status == http.StatusNotFound:
+		return ErrNotFound
+	case status == http.StatusMethodNotAllowed:
+		return ErrMethodNotAllowed
+	case status == http.StatusConflict:
+		return ErrConflict
+	case status == http.StatusPreconditionFailed:
+		return ErrPreconditionFailed
+	case status >= 500:
+		return ErrServer
+	}
+	return nil
+}
+
+// newError construit une Error à partir de la réponse, dont il consomme et
+// ferme le corps
+func newError(resp *http.Response) *Error {
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	return &Error{
+		StatusCode: resp.StatusCode,
+		Message:    strings.TrimSpace(string(body)),
+		Body:       body,
+	}
+}
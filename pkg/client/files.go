@@ -0,0 +1,467 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+)
+
+// File décrit un fichier stocké par le service
+type File struct {
+	CID       string `json:"cid"`
+	FileName  string `json:"file_name"`
+	MimeType  string `json:"mime_type"`
+	FileSize  int64  `json:"file_size"`
+	IsPrivate bool   `json:"is_private"`
+	// Informations modifiables avec UpdateFileDetails
+	DisplayName string   `json:"display_name,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	ImageInfo
+	LottieInfo
+}
+
+// LottieInfo décrit une animation Lottie ou une archive dotLottie validée à
+// l'upload ; Kind est vide pour les autres fichiers
+type LottieInfo struct {
+	Kind       string  `json:"kind,omitempty"`
+	FrameRate  float64 `json:"frame_rate,omitempty"`
+	Duration   float64 `json:"duration,omitempty"`
+	LayerCount int     `json:"layer_count,omitempty"`
+	AssetCount int     `json:"asset_count,omitempty"`
+	// Contenu d'une archive dotLottie
+	AnimationCount int `json:"animation_count,omitempty"`
+	ThemeCount     int `json:"theme_count,omitempty"`
+}
+
+// ImageInfo décrit une image ; les champs sont nuls pour les autres fichiers
+type ImageInfo struct {
+	Width       int    `json:"width,omitempty"`
+	Height      int    `json:"height,omitempty"`
+	Orientation int    `json:"orientation,omitempty"`
+	ColorModel  string `json:"color_model,omitempty"`
+}
+
+// FilePage est une page de fichiers
+type FilePage struct {
+	Files      []File `json:"files"`
+	Total      int    `json:"total"`
+	Page       int    `json:"page"`
+	Limit      int    `json:"limit"`
+	TotalPages int    `json:"totalPages"`
+}
+
+// SearchHit est un résultat de recherche dans les fichiers publics
+type SearchHit struct {
+	CID      string `json:"cid"`
+	FileName string `json:"file_name"`
+	MimeType string `json:"mime_type"`
+	// DisplayName n'est renseigné que si le propriétaire l'a saisi
+	DisplayName string `json:"display_name,omitempty"`
+	// Kind, Duration et FrameRate ne sont renseignés que pour les animations Lottie
+	Kind      string  `json:"kind,omitempty"`
+	Duration  float64 `json:"duration,omitempty"`
+	FrameRate float64 `json:"frame_rate,omitempty"`
+}
+
+// SearchPage est une page de résultats de recherche
+type SearchPage struct {
+	Results    []SearchHit `json:"results"`
+	Total      int         `json:"total"`
+	TotalPages int         `json:"totalPages"`
+}
+
+// UploadOptions précise un upload
+type UploadOptions struct {
+	// Private rend le fichier visible uniquement de l'API key qui l'uploade
+	Private bool
+	// ContentType est le type MIME enregistré pour le fichier
+	// (application/octet-stream par défaut)
+	ContentType string
+	// Kind vaut "lottie" ou "dotlottie" pour exiger une animation valide
+	Kind string
+	// StripMetadata retire les métadonnées EXIF/XMP d'une image avant l'ajout
+	// à IPFS ; nil applique le réglage par défaut de l'API key
+	StripMetadata *bool
+	// Optimize minifie une animation Lottie JSON avant l'ajout à IPFS
+	Optimize bool
+	// Precision est le nombre de décimales conservées par Optimize ; nil
+	// applique la valeur du serveur (3)
+	Precision *int
+}
+
+// UploadResult est la réponse à un upload
+type UploadResult struct {
+	CID              string `json:"cid"`
+	Message          string `json:"message"`
+	MetadataStripped bool   `json:"metadata_stripped"`
+	ImageInfo
+	LottieInfo
+}
+
+// PrivacyResult est la réponse au basculement de visibilité d'un fichier
+type PrivacyResult struct {
+	CID       string `json:"cid"`
+	IsPrivate bool   `json:"is_private"`
+	Message   string `json:"message"`
+}
+
+// Download est le contenu d'un fichier en cours de lecture ; l'appelant doit
+// le fermer
+type Download struct {
+	io.ReadCloser
+	ContentType string
+	FileName    string
+	// Size vaut -1 si le service n'a pas annoncé de taille
+	Size int64
+}
+
+// Upload envoie le contenu de r sous le nom fileName. Le contenu est transmis
+// en flux sans être chargé en mémoire ; un upload n'est jamais retenté.
+func (c *Client) Upload(ctx context.Context, fileName string, r io.Reader, opts UploadOptions) (*UploadResult, error) {
+	contentType := opts.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		err := writeUploadForm(mw, fileName, contentType, opts, r)
+		pw.CloseWithError(err)
+	}()
+
+	var out UploadResult
+	err := c.doJSON(ctx, request{
+		method:     http.MethodPost,
+		path:       "/v1/files",
+		header:     http.Header{"Content-Type": {mw.FormDataContentType()}},
+		bodyStream: pr,
+	}, &out)
+	// Débloquer l'écrivain si la requête a échoué avant d'avoir tout lu
+	pr.Close()
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func writeUploadForm(mw *multipart.Writer, fileName, contentType string, opts UploadOptions, r io.Reader) error {
+	if err := mw.WriteField("is_private", strconv.FormatBool(opts.Private)); err != nil {
+		return err
+	}
+	if opts.Kind != "" {
+		if err := mw.WriteField("kind", opts.Kind); err != nil {
+			return err
+		}
+	}
+	if opts.StripMetadata != nil {
+		if err := mw.WriteField("strip_metadata", strconv.FormatBool(*opts.StripMetadata)); err != nil {
+			return err
+		}
+	}
+	if opts.Optimize {
+		if err := mw.WriteField("optimize", "true"); err != nil {
+			return err
+		}
+	}
+	if opts.Precision != nil {
+		if err := mw.WriteField("precision", strconv.Itoa(*opts.Precision)); err != nil {
+			return err
+		}
+	}
+
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{"name": "file", "filename": fileName}))
+	h.Set("Content-Type", contentType)
+	part, err := mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return err
+	}
+	return mw.Close()
+}
+
+// ListPublicFiles renvoie une page des fichiers publics
+func (c *Client) ListPublicFiles(ctx context.Context, page, limit int) (*FilePage, error) {
+	return c.listFiles(ctx, "/v1/files", page, limit)
+}
+
+// ListAccountFiles renvoie une page des fichiers de l'API key du client
+func (c *Client) ListAccountFiles(ctx context.Context, page, limit int) (*FilePage, error) {
+	return c.listFiles(ctx, "/v1/account/files", page, limit)
+}
+
+func (c *Client) listFiles(ctx context.Context, path string, page, limit int) (*FilePage, error) {
+	var out FilePage
+	err := c.doJSON(ctx, request{
+		method:     http.MethodGet,
+		path:       path,
+		query:      pageQuery(page, limit),
+		idempotent: true,
+	}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PublicFiles renvoie un itérateur sur tous les fichiers publics, par pages
+// de limit éléments
+func (c *Client) PublicFiles(limit int) *FileIterator {
+	return &FileIterator{fetch: c.ListPublicFiles, limit: limit}
+}
+
+// AccountFiles renvoie un itérateur sur tous les fichiers de l'API key
+func (c *Client) AccountFiles(limit int) *FileIterator {
+	return &FileIterator{fetch: c.ListAccountFiles, limit: limit}
+}
+
+// SearchPublicFiles recherche dans les fichiers publics (syntaxe query string
+// de Bleve)
+func (c *Client) SearchPublicFiles(ctx context.Context, query string, page, limit int) (*SearchPage, error) {
+	q := pageQuery(page, limit)
+	q.Set("query", query)
+
+	var out SearchPage
+	err := c.doJSON(ctx, request{
+		method:     http.MethodGet,
+		path:       "/v1/files/search",
+		query:      q,
+		idempotent: true,
+	}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Download ouvre le contenu d'un fichier (route authentifiée)
+func (c *Client) Download(ctx context.Context, cid string) (*Download, error) {
+	return c.download(ctx, filePath(cid, ""), nil)
+}
+
+// Display ouvre le contenu d'un fichier public
+func (c *Client) Display(ctx context.Context, cid string) (*Download, error) {
+	return c.download(ctx, filePath(cid, "/display"), nil)
+}
+
+// ImageOptions demande une variante redimensionnée d'une image. Les champs
+// vides laissent le serveur appliquer ses valeurs par défaut.
+type ImageOptions struct {
+	Width  int
+	Height int
+	// Fit vaut "contain", "cover" ou "fill"
+	Fit string
+	// Format vaut "jpeg" ou "png"
+	Format string
+}
+
+func (o *ImageOptions) query() url.Values {
+	if o == nil {
+		return nil
+	}
+	q := url.Values{}
+	if o.Width > 0 {
+		q.Set("w", strconv.Itoa(o.Width))
+	}
+	if o.Height > 0 {
+		q.Set("h", strconv.Itoa(o.Height))
+	}
+	if o.Fit != "" {
+		q.Set("fit", o.Fit)
+	}
+	if o.Format != "" {
+		q.Set("format", o.Format)
+	}
+	return q
+}
+
+// Image ouvre une image publique, redimensionnée si opts n'est pas nil
+func (c *Client) Image(ctx context.Context, cid string, opts *ImageOptions) (*Download, error) {
+	return c.download(ctx, filePath(cid, "/image"), opts.query())
+}
+
+// PrivateImage ouvre une image privée de l'API key, redimensionnée si opts n'est pas nil
+func (c *Client) PrivateImage(ctx context.Context, cid string, opts *ImageOptions) (*Download, error) {
+	return c.download(ctx, filePath(cid, "/private-image"), opts.query())
+}
+
+func (c *Client) download(ctx context.Context, path string, query url.Values) (*Download, error) {
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: path, query: query, idempotent: true})
+	if err != nil {
+		return nil, err
+	}
+	return newDownload(resp), nil
+}
+
+// newDownload expose le corps de resp ; l'appelant le ferme
+func newDownload(resp *http.Response) *Download {
+	d := &Download{
+		ReadCloser:  resp.Body,
+		ContentType: resp.Header.Get("Content-Type"),
+		Size:        resp.ContentLength,
+	}
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		d.FileName = params["filename"]
+	}
+	return d
+}
+
+// Lottie renvoie le JSON d'une animation Lottie publique ; voir DotLottie et
+// LottieAnimation pour les archives dotLottie
+func (c *Client) Lottie(ctx context.Context, cid string) (json.RawMessage, error) {
+	var out json.RawMessage
+	err := c.doJSON(ctx, request{method: http.MethodGet, path: filePath(cid, "/lottie"), idempotent: true}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// TogglePrivacy bascule la visibilité d'un fichier de l'API key
+func (c *Client) TogglePrivacy(ctx context.Context, cid string) (*PrivacyResult, error) {
+	var out PrivacyResult
+	err := c.doJSON(ctx, request{method: http.MethodPost, path: filePath(cid, "/toggle-private")}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// FileDetailsPatch décrit une modification des informations d'un fichier :
+// les champs nil sont conservés, une chaîne vide ou une liste vide efface
+// le champ
+type FileDetailsPatch struct {
+	DisplayName *string
+	Description *string
+	// Tags remplace tous les tags
+	Tags []string
+}
+
+// UpdateFileDetails modifie le nom d'affichage, la description et les tags
+// d'un fichier de l'API key et renvoie le fichier modifié
+func (c *Client) UpdateFileDetails(ctx context.Context, cid string, patch FileDetailsPatch) (*File, error) {
+	fields := map[string]interface{}{}
+	if patch.DisplayName != nil {
+		fields["display_name"] = *patch.DisplayName
+	}
+	if patch.Description != nil {
+		fields["description"] = *patch.Description
+	}
+	if patch.Tags != nil {
+		fields["tags"] = patch.Tags
+	}
+	body, err := jsonBody(fields)
+	if err != nil {
+		return nil, err
+	}
+	var out File
+	req := request{method: http.MethodPatch, path: filePath(cid, ""), header: mergePatchHeader(), body: body, idempotent: true}
+	if err := c.doJSON(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteFile supprime un fichier de l'API key
+func (c *Client) DeleteFile(ctx context.Context, cid string) error {
+	return c.doJSON(ctx, request{method: http.MethodDelete, path: filePath(cid, ""), idempotent: true}, nil)
+}
+
+// ForceDeleteFile supprime un fichier même si des thèmes l'utilisent encore
+// (DeleteFile renvoie alors ErrConflict) et renvoie ces thèmes
+func (c *Client) ForceDeleteFile(ctx context.Context, cid string) ([]Theme, error) {
+	var out struct {
+		OrphanedThemes []Theme `json:"orphaned_themes"`
+	}
+	err := c.doJSON(ctx, request{method: http.MethodDelete, path: filePath(cid, ""), query: url.Values{"force": {"true"}}, idempotent: true}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return out.OrphanedThemes, nil
+}
+
+func filePath(cid, suffix string) string {
+	return "/v1/files/" + url.PathEscape(cid) + suffix
+}
+
+func pageQuery(page, limit int) url.Values {
+	q := url.Values{}
+	if page > 0 {
+		q.Set("page", strconv.Itoa(page))
+	}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	return q
+}
+
+// FileIterator parcourt une liste paginée de fichiers :
+//
+//	it := c.PublicFiles(50)
+//	for it.Next(ctx) {
+//		f := it.File()
+//	}
+//	if err := it.Err(); err != nil { ... }
+type FileIterator struct {
+	fetch func(ctx context.Context, page, limit int) (*FilePage, error)
+	limit int
+
+	page    *FilePage
+	pos     int
+	current File
+	err     error
+	done    bool
+}
+
+// Next avance au fichier suivant, en chargeant la page suivante si besoin.
+// Il renvoie false à la fin de la liste ou en cas d'erreur.
+func (it *FileIterator) Next(ctx context.Context) bool {
+	if it.done || it.err != nil {
+		return false
+	}
+	for it.page == nil || it.pos >= len(it.page.Files) {
+		next := 1
+		if it.page != nil {
+			if it.page.Page >= it.page.TotalPages {
+				it.done = true
+				return false
+			}
+			next = it.page.Page + 1
+		}
+
+		page, err := it.fetch(ctx, next, it.limit)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		if len(page.Files) == 0 {
+			it.done = true
+			return false
+		}
+		it.page, it.pos = page, 0
+	}
+
+	it.current = it.page.Files[it.pos]
+	it.pos++
+	return true
+}
+
+// File renvoie le fichier courant
+func (it *FileIterator) File() File {
+	return it.current
+}
+
+// Err renvoie l'erreur qui a interrompu l'itération, le cas échéant
+func (it *FileIterator) Err() error {
+	return it.err
+}
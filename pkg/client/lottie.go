@@ -0,0 +1,104 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// DotLottieManifest décrit le contenu d'une archive dotLottie
+type DotLottieManifest struct {
+	CID               string               `json:"cid"`
+	Version           string               `json:"version"`
+	Generator         string               `json:"generator"`
+	ActiveAnimationID string               `json:"active_animation_id"`
+	Animations        []DotLottieAnimation `json:"animations"`
+	Themes            []DotLottieTheme     `json:"themes"`
+}
+
+// DotLottieAnimation est une animation d'une archive
+type DotLottieAnimation struct {
+	ID         string  `json:"id"`
+	FrameRate  float64 `json:"frame_rate"`
+	Duration   float64 `json:"duration"`
+	Width      int     `json:"width"`
+	Height     int     `json:"height"`
+	LayerCount int     `json:"layer_count"`
+	AssetCount int     `json:"asset_count"`
+}
+
+// DotLottieTheme est un thème d'une archive
+type DotLottieTheme struct {
+	ID         string   `json:"id"`
+	Animations []string `json:"animations,omitempty"`
+}
+
+// ConvertOptions précise une conversion en dotLottie
+type ConvertOptions struct {
+	// AnimationID nomme l'animation dans l'archive (nom du fichier source par défaut)
+	AnimationID string
+	// Private fixe la visibilité de l'archive ; nil reprend celle de la source
+	Private *bool
+}
+
+// Conversion est la réponse à une conversion en dotLottie
+type Conversion struct {
+	CID       string `json:"cid"`
+	SourceCID string `json:"source_cid"`
+	FileName  string `json:"file_name"`
+	IsPrivate bool   `json:"is_private"`
+	Message   string `json:"message"`
+	LottieInfo
+}
+
+// DotLottie ouvre une archive dotLottie publique
+func (c *Client) DotLottie(ctx context.Context, cid string) (*Download, error) {
+	return c.download(ctx, filePath(cid, "/lottie"), nil)
+}
+
+// LottieAnimation renvoie le JSON de l'animation animationID d'une archive
+// dotLottie publique
+func (c *Client) LottieAnimation(ctx context.Context, cid, animationID string) (json.RawMessage, error) {
+	var out json.RawMessage
+	err := c.doJSON(ctx, request{
+		method:     http.MethodGet,
+		path:       filePath(cid, "/lottie"),
+		query:      url.Values{"animation": {animationID}},
+		idempotent: true,
+	}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DotLottieManifest liste les animations et les thèmes d'une archive dotLottie
+func (c *Client) DotLottieManifest(ctx context.Context, cid string) (*DotLottieManifest, error) {
+	var out DotLottieManifest
+	err := c.doJSON(ctx, request{method: http.MethodGet, path: filePath(cid, "/lottie/manifest"), idempotent: true}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ConvertToDotLottie crée une archive dotLottie à partir d'une animation
+// Lottie JSON publique ou appartenant à l'API key
+func (c *Client) ConvertToDotLottie(ctx context.Context, cid string, opts ConvertOptions) (*Conversion, error) {
+	q := url.Values{}
+	if opts.AnimationID != "" {
+		q.Set("animation_id", opts.AnimationID)
+	}
+	if opts.Private != nil {
+		q.Set("is_private", strconv.FormatBool(*opts.Private))
+	}
+
+	var out Conversion
+	err := c.doJSON(ctx, request{method: http.MethodPost, path: filePath(cid, "/dotlottie"), query: q}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
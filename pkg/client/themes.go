@@ -0,0 +1,172 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Theme associe un nom à une animation stockée sur IPFS et, le cas échéant,
+// aux animations de chaque rôle de l'interface
+type Theme struct {
+	ID         int    `json:"id"`
+	CID        string `json:"cid"`
+	Name       string `json:"name"`
+	Category   string `json:"category,omitempty"`
+	SortOrder  int    `json:"sort_order"`
+	PreviewCID string `json:"preview_cid,omitempty"`
+	// Enabled vaut nil à la création pour un thème activé
+	Enabled    *bool            `json:"enabled,omitempty"`
+	Animations []ThemeAnimation `json:"animations,omitempty"`
+	// ETag est la version lue ou enregistrée ; UpdateTheme l'envoie en
+	// If-Match et échoue avec ErrPreconditionFailed si le thème a changé
+	// depuis
+	ETag string `json:"-"`
+}
+
+// ThemeAnimation est l'animation d'un thème pour un rôle (idle, loading,
+// success, error...) et une variante ("light", "dark" ou "" par défaut)
+type ThemeAnimation struct {
+	Role    string `json:"role"`
+	Variant string `json:"variant,omitempty"`
+	CID     string `json:"cid"`
+}
+
+// ThemeFilter filtre la liste des thèmes
+type ThemeFilter struct {
+	Category string
+	// Enabled limite aux thèmes activés (true) ou désactivés (false)
+	Enabled *bool
+}
+
+// ThemePage est une page de thèmes, triés par SortOrder puis par ID
+type ThemePage struct {
+	Themes     []Theme `json:"themes"`
+	Total      int     `json:"total"`
+	Page       int     `json:"page"`
+	Limit      int     `json:"limit"`
+	TotalPages int     `json:"totalPages"`
+}
+
+// ListThemes renvoie une page des thèmes satisfaisant filter
+func (c *Client) ListThemes(ctx context.Context, filter ThemeFilter, page, limit int) (*ThemePage, error) {
+	q := pageQuery(page, limit)
+	if filter.Category != "" {
+		q.Set("category", filter.Category)
+	}
+	if filter.Enabled != nil {
+		q.Set("enabled", strconv.FormatBool(*filter.Enabled))
+	}
+	var out ThemePage
+	err := c.doJSON(ctx, request{method: http.MethodGet, path: "/v1/themes", query: q, idempotent: true}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetTheme renvoie le thème id
+func (c *Client) GetTheme(ctx context.Context, id int) (*Theme, error) {
+	var out Theme
+	etag, err := c.doJSONETag(ctx, request{method: http.MethodGet, path: themePath(id), idempotent: true}, &out)
+	if err != nil {
+		return nil, err
+	}
+	out.ETag = etag
+	return &out, nil
+}
+
+// ThemeBundle est un thème accompagné du contenu de ses animations
+type ThemeBundle struct {
+	Theme Theme `json:"theme"`
+	// Files associe chaque CID d'animation à son contenu
+	Files map[string]BundleFile `json:"files"`
+	// Missing liste les CID référencés qui ne sont plus publics
+	Missing []string `json:"missing"`
+	// ETag permet de revalider le bundle avec ThemeBundle
+	ETag string `json:"-"`
+}
+
+// BundleFile est une animation d'un bundle : Animation contient le JSON
+// d'une animation Lottie, Data le contenu d'une archive dotLottie
+type BundleFile struct {
+	Kind      string          `json:"kind"`
+	MimeType  string          `json:"mime_type"`
+	FileSize  int64           `json:"file_size"`
+	Animation json.RawMessage `json:"animation,omitempty"`
+	Data      []byte          `json:"data,omitempty"`
+}
+
+// ThemeBundle renvoie le thème id et le contenu de ses animations. Si etag
+// est celui du bundle courant, l'erreur renvoyée satisfait
+// errors.Is(err, ErrNotModified).
+func (c *Client) ThemeBundle(ctx context.Context, id int, etag string) (*ThemeBundle, error) {
+	req := request{method: http.MethodGet, path: themePath(id) + "/bundle", idempotent: true}
+	if etag != "" {
+		req.header = http.Header{"If-None-Match": {etag}}
+	}
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	out := ThemeBundle{ETag: resp.Header.Get("ETag")}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ThemeBundleZip télécharge le bundle du thème id sous forme d'archive zip
+// (bundle.json et animations/<cid>.json ou .lottie)
+func (c *Client) ThemeBundleZip(ctx context.Context, id int) (*Download, error) {
+	return c.download(ctx, themePath(id)+"/bundle", url.Values{"format": {"zip"}})
+}
+
+// CreateTheme crée un thème (API key avec la permission write) et renvoie le
+// thème enregistré. Le CID doit désigner une animation publique et le nom
+// être libre (ErrConflict sinon).
+func (c *Client) CreateTheme(ctx context.Context, theme Theme) (*Theme, error) {
+	body, err := jsonBody(theme)
+	if err != nil {
+		return nil, err
+	}
+	var out Theme
+	etag, err := c.doJSONETag(ctx, request{method: http.MethodPost, path: "/v1/themes", body: body}, &out)
+	if err != nil {
+		return nil, err
+	}
+	out.ETag = etag
+	return &out, nil
+}
+
+// UpdateTheme remplace le thème theme.ID, à condition qu'il n'ait pas changé
+// depuis theme.ETag si celui-ci est renseigné, et renvoie le thème
+// enregistré. En cas d'échec de la condition, l'Error renvoyée porte dans
+// Body la version actuelle du thème.
+func (c *Client) UpdateTheme(ctx context.Context, theme Theme) (*Theme, error) {
+	body, err := jsonBody(theme)
+	if err != nil {
+		return nil, err
+	}
+	var out Theme
+	req := request{method: http.MethodPut, path: themePath(theme.ID), header: ifMatch(theme.ETag), body: body, idempotent: true}
+	etag, err := c.doJSONETag(ctx, req, &out)
+	if err != nil {
+		return nil, err
+	}
+	out.ETag = etag
+	return &out, nil
+}
+
+// DeleteTheme supprime un thème
+func (c *Client) DeleteTheme(ctx context.Context, id int) error {
+	return c.doJSON(ctx, request{method: http.MethodDelete, path: themePath(id), idempotent: true}, nil)
+}
+
+func themePath(id int) string {
+	return "/v1/themes/" + strconv.Itoa(id)
+}
@@ -0,0 +1,21 @@
+package database
+
+import (
+	"database/sql"
+	"os"
+)
+
+// Connect ouvre la base configurée par DB_DRIVER : "mysql" (MariaDB, par
+// défaut) ou "sqlite", dont le fichier est donné par DB_PATH
+func Connect() (*sql.DB, Dialect, error) {
+	if os.Getenv("DB_DRIVER") == string(SQLite) {
+		path := os.Getenv("DB_PATH")
+		if path == "" {
+			path = "baki-ipfs.db"
+		}
+		db, err := ConnectSQLite(path)
+		return db, SQLite, err
+	}
+	db, err := ConnectDB()
+	return db, MySQL, err
+}
@@ -0,0 +1,306 @@
+package database
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/mysql/*.sql migrations/sqlite/*.sql
+var migrationsFS embed.FS
+
+// Dialect désigne le moteur SQL ciblé par les migrations
+type Dialect string
+
+const (
+	MySQL  Dialect = "mysql"
+	SQLite Dialect = "sqlite"
+)
+
+// Migration est une migration SQL versionnée, embarquée dans le binaire.
+// Les fichiers suivent le format migrations/<dialecte>/NNNN_nom.up.sql et
+// NNNN_nom.down.sql ; chaque dialecte porte les mêmes versions.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus indique si une migration a été appliquée sur la base
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt string
+}
+
+// Migrations renvoie les migrations embarquées du dialecte, triées par version
+func Migrations(dialect Dialect) ([]Migration, error) {
+	dir := "migrations/" + string(dialect)
+	entries, err := fs.ReadDir(migrationsFS, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		name := e.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		versionStr, label, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name: %s", name)
+		}
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %v", name, err)
+		}
+
+		content, err := fs.ReadFile(migrationsFS, dir+"/"+name)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: label}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// LatestVersion renvoie la version de schéma attendue par ce binaire
+func LatestVersion(dialect Dialect) (int, error) {
+	migrations, err := Migrations(dialect)
+	if err != nil {
+		return 0, err
+	}
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
+
+func ensureMigrationsTable(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INT NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	return err
+}
+
+// appliedMigrations renvoie les versions appliquées et leur date d'application
+func appliedMigrations(db *sql.DB) (map[int]string, error) {
+	if err := ensureMigrationsTable(db); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]string{}
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// SchemaVersion renvoie la plus haute version de migration appliquée (0 si aucune)
+func SchemaVersion(db *sql.DB) (int, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return 0, err
+	}
+	version := 0
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+	return version, nil
+}
+
+// MigrateUp applique toutes les migrations en attente et renvoie celles qui
+// ont été appliquées
+func MigrateUp(db *sql.DB, dialect Dialect) ([]Migration, error) {
+	migrations, err := Migrations(dialect)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := execScript(db, m.Up); err != nil {
+			return done, fmt.Errorf("migration %04d_%s: %v", m.Version, m.Name, err)
+		}
+		if _, err := db.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.Version, m.Name); err != nil {
+			return done, err
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// MigrateDown annule les steps dernières migrations appliquées et renvoie
+// celles qui ont été annulées
+func MigrateDown(db *sql.DB, dialect Dialect, steps int) ([]Migration, error) {
+	migrations, err := Migrations(dialect)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if m.Down == "" {
+			return done, fmt.Errorf("migration %04d_%s is irreversible", m.Version, m.Name)
+		}
+		if err := execScript(db, m.Down); err != nil {
+			return done, fmt.Errorf("migration %04d_%s: %v", m.Version, m.Name, err)
+		}
+		if _, err := db.Exec("DELETE FROM schema_migrations WHERE version = ?", m.Version); err != nil {
+			return done, err
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// Status renvoie l'état de chaque migration embarquée
+func Status(db *sql.DB, dialect Dialect) ([]MigrationStatus, error) {
+	migrations, err := Migrations(dialect)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		appliedAt, ok := applied[m.Version]
+		status = append(status, MigrationStatus{Migration: m, Applied: ok, AppliedAt: appliedAt})
+	}
+	return status, nil
+}
+
+// CheckSchemaVersion refuse une base dont le schéma ne correspond pas à la
+// version attendue par le binaire : base en retard (migrations à appliquer)
+// ou en avance (binaire plus ancien que la base)
+func CheckSchemaVersion(db *sql.DB, dialect Dialect) error {
+	latest, err := LatestVersion(dialect)
+	if err != nil {
+		return err
+	}
+	current, err := SchemaVersion(db)
+	if err != nil {
+		return fmt.Errorf("unable to read schema version: %v", err)
+	}
+
+	switch {
+	case current < latest:
+		return fmt.Errorf("database schema is at version %d, expected %d: run `migrate up`", current, latest)
+	case current > latest:
+		return fmt.Errorf("database schema is at version %d, newer than the %d supported by this binary", current, latest)
+	}
+	return nil
+}
+
+// execScript exécute un script SQL instruction par instruction, le driver
+// MySQL n'acceptant qu'une instruction par appel par défaut
+func execScript(db *sql.DB, script string) error {
+	for _, stmt := range splitStatements(script) {
+		if _, err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitStatements découpe un script sur les ';' de fin de ligne et ignore les
+// commentaires "--". Les blocs BEGIN ... END; (triggers) restent entiers.
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	inBlock := false
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		upper := strings.ToUpper(trimmed)
+		if strings.HasSuffix(upper, "BEGIN") {
+			inBlock = true
+			continue
+		}
+		if inBlock {
+			if upper == "END;" {
+				inBlock = false
+			} else {
+				continue
+			}
+		}
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMigrationsAreOrderedAndReversible(t *testing.T) {
+	for _, dialect := range []Dialect{MySQL, SQLite} {
+		migrations, err := Migrations(dialect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(migrations) == 0 {
+			t.Fatalf("%s : aucune migration embarquée", dialect)
+		}
+		for i, m := range migrations {
+			if m.Version != i+1 {
+				t.Errorf("%s : migration %s en version %d, attendu %d (numérotation continue)", dialect, m.Name, m.Version, i+1)
+			}
+			if m.Down == "" {
+				t.Errorf("%s : migration %04d_%s sans script down", dialect, m.Version, m.Name)
+			}
+		}
+	}
+}
+
+// Les deux dialectes doivent porter exactement les mêmes versions
+func TestDialectsInSync(t *testing.T) {
+	mysql, err := Migrations(MySQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlite, err := Migrations(SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mysql) != len(sqlite) {
+		t.Fatalf("%d migrations MySQL pour %d SQLite", len(mysql), len(sqlite))
+	}
+	for i := range mysql {
+		if mysql[i].Version != sqlite[i].Version || mysql[i].Name != sqlite[i].Name {
+			t.Errorf("migration %d : %04d_%s (MySQL) / %04d_%s (SQLite)", i, mysql[i].Version, mysql[i].Name, sqlite[i].Version, sqlite[i].Name)
+		}
+	}
+}
+
+func TestMigrateUpDownSQLite(t *testing.T) {
+	db, err := ConnectSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := CheckSchemaVersion(db, SQLite); err == nil {
+		t.Error("CheckSchemaVersion doit refuser une base vide")
+	}
+	if _, err := MigrateUp(db, SQLite); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if err := CheckSchemaVersion(db, SQLite); err != nil {
+		t.Errorf("CheckSchemaVersion après MigrateUp : %v", err)
+	}
+
+	latest, _ := LatestVersion(SQLite)
+	if _, err := MigrateDown(db, SQLite, latest); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	if v, _ := SchemaVersion(db); v != 0 {
+		t.Errorf("SchemaVersion après MigrateDown = %d, attendu 0", v)
+	}
+	// Une base remontée doit de nouveau être compatible
+	if _, err := MigrateUp(db, SQLite); err != nil {
+		t.Fatalf("MigrateUp après MigrateDown : %v", err)
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- commentaire
+CREATE TABLE a (
+    id INT
+);
+
+DROP TABLE b;
+CREATE TRIGGER t AFTER UPDATE ON a
+BEGIN
+    UPDATE a SET id = 1;
+END;
+SELECT 1`
+	want := []string{
+		"CREATE TABLE a (\n    id INT\n)",
+		"DROP TABLE b",
+		"CREATE TRIGGER t AFTER UPDATE ON a\nBEGIN\n    UPDATE a SET id = 1;\nEND",
+		"SELECT 1",
+	}
+	if got := splitStatements(script); !reflect.DeepEqual(got, want) {
+		t.Errorf("splitStatements = %q, attendu %q", got, want)
+	}
+}
@@ -0,0 +1,42 @@
+-- Schéma initial : tables utilisées par le service avant l'introduction des
+-- migrations. IF NOT EXISTS permet d'adopter une base existante.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    api_key VARCHAR(255) NOT NULL,
+    permissions VARCHAR(32) NOT NULL DEFAULT 'read',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_api_keys_api_key (api_key)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS files (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    api_key_id INT NOT NULL,
+    cid VARCHAR(255) NOT NULL,
+    is_private BOOLEAN NOT NULL DEFAULT FALSE,
+    file_name VARCHAR(255) NOT NULL,
+    mime_type VARCHAR(255) NOT NULL,
+    file_size BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_files_cid (cid),
+    KEY idx_files_api_key_id (api_key_id),
+    CONSTRAINT fk_files_api_key FOREIGN KEY (api_key_id) REFERENCES api_keys (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS docs (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    title VARCHAR(255) NOT NULL,
+    path VARCHAR(512) NOT NULL,
+    doc_src LONGTEXT NOT NULL,
+    version DOUBLE NOT NULL DEFAULT 0,
+    is_children BOOLEAN NOT NULL DEFAULT FALSE,
+    parent_id INT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    KEY idx_docs_parent_id (parent_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS cid_themes (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    cid VARCHAR(255) NOT NULL,
+    name VARCHAR(255) NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
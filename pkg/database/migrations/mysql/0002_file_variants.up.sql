@@ -0,0 +1,13 @@
+-- Variantes redimensionnées des images, mises en cache sur IPFS
+CREATE TABLE IF NOT EXISTS file_variants (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    source_cid VARCHAR(255) NOT NULL,
+    variant_key VARCHAR(255) NOT NULL,
+    cid VARCHAR(255) NOT NULL,
+    mime_type VARCHAR(255) NOT NULL,
+    file_size BIGINT NOT NULL,
+    width INT NOT NULL,
+    height INT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_file_variants_source_key (source_cid, variant_key)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
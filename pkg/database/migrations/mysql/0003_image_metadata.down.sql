@@ -0,0 +1,7 @@
+ALTER TABLE api_keys DROP COLUMN strip_metadata;
+
+ALTER TABLE files
+    DROP COLUMN color_model,
+    DROP COLUMN orientation,
+    DROP COLUMN height,
+    DROP COLUMN width;
@@ -0,0 +1,9 @@
+-- Métadonnées des images (0 / '' : inconnues ou fichier non image) et
+-- suppression des métadonnées EXIF/XMP par défaut pour une clé
+ALTER TABLE files
+    ADD COLUMN width INT NOT NULL DEFAULT 0,
+    ADD COLUMN height INT NOT NULL DEFAULT 0,
+    ADD COLUMN orientation SMALLINT NOT NULL DEFAULT 0,
+    ADD COLUMN color_model VARCHAR(32) NOT NULL DEFAULT '';
+
+ALTER TABLE api_keys ADD COLUMN strip_metadata BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,7 @@
+ALTER TABLE files
+    DROP KEY idx_files_kind,
+    DROP COLUMN asset_count,
+    DROP COLUMN layer_count,
+    DROP COLUMN duration,
+    DROP COLUMN frame_rate,
+    DROP COLUMN kind;
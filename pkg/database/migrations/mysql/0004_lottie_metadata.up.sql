@@ -0,0 +1,9 @@
+-- Nature du fichier ('lottie' pour une animation validée, '' sinon) et
+-- métadonnées des animations ; les dimensions réutilisent width et height
+ALTER TABLE files
+    ADD COLUMN kind VARCHAR(32) NOT NULL DEFAULT '',
+    ADD COLUMN frame_rate DOUBLE NOT NULL DEFAULT 0,
+    ADD COLUMN duration DOUBLE NOT NULL DEFAULT 0,
+    ADD COLUMN layer_count INT NOT NULL DEFAULT 0,
+    ADD COLUMN asset_count INT NOT NULL DEFAULT 0,
+    ADD KEY idx_files_kind (kind);
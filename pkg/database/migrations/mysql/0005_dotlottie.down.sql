@@ -0,0 +1,3 @@
+ALTER TABLE files
+    DROP COLUMN theme_count,
+    DROP COLUMN animation_count;
@@ -0,0 +1,5 @@
+-- Contenu des archives dotLottie (kind = 'dotlottie') ; les autres colonnes
+-- Lottie décrivent l'animation active de l'archive
+ALTER TABLE files
+    ADD COLUMN animation_count INT NOT NULL DEFAULT 0,
+    ADD COLUMN theme_count INT NOT NULL DEFAULT 0;
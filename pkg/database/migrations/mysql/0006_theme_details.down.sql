@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS cid_theme_animations;
+
+ALTER TABLE cid_themes
+    DROP COLUMN preview_cid,
+    DROP COLUMN enabled,
+    DROP COLUMN sort_order,
+    DROP COLUMN category;
@@ -0,0 +1,19 @@
+-- Thèmes enrichis : catégorie, ordre d'affichage, activation et aperçu
+ALTER TABLE cid_themes
+    ADD COLUMN category VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN sort_order INT NOT NULL DEFAULT 0,
+    ADD COLUMN enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD COLUMN preview_cid VARCHAR(255) NOT NULL DEFAULT '';
+
+-- Animations d'un thème par rôle (idle, loading, success, error...) et
+-- variante (light, dark ou '' pour la variante par défaut)
+CREATE TABLE IF NOT EXISTS cid_theme_animations (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    theme_id INT NOT NULL,
+    role VARCHAR(32) NOT NULL,
+    variant VARCHAR(16) NOT NULL DEFAULT '',
+    cid VARCHAR(255) NOT NULL,
+    UNIQUE KEY uq_cid_theme_animations_role (theme_id, role, variant),
+    KEY idx_cid_theme_animations_cid (cid),
+    CONSTRAINT fk_cid_theme_animations_theme FOREIGN KEY (theme_id) REFERENCES cid_themes (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,22 @@
+-- Historique immuable des documents : chaque modification ajoute une
+-- révision, numérotée par le serveur
+CREATE TABLE IF NOT EXISTS doc_revisions (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    doc_id INT NOT NULL,
+    version INT NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    path VARCHAR(512) NOT NULL,
+    doc_src LONGTEXT NOT NULL,
+    -- Clé auteur ; NULL pour l'état repris à la migration
+    api_key_id INT NULL,
+    -- Version restaurée, le cas échéant
+    restored_from INT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_doc_revisions_version (doc_id, version),
+    CONSTRAINT fk_doc_revisions_doc FOREIGN KEY (doc_id) REFERENCES docs (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- L'état actuel de chaque document devient sa révision 1
+INSERT INTO doc_revisions (doc_id, version, title, path, doc_src, created_at)
+SELECT id, 1, title, path, doc_src, updated_at FROM docs;
+UPDATE docs SET version = 1, updated_at = updated_at;
@@ -0,0 +1,3 @@
+DROP INDEX idx_doc_revisions_cid ON doc_revisions;
+ALTER TABLE doc_revisions DROP COLUMN cid;
+ALTER TABLE docs DROP COLUMN cid;
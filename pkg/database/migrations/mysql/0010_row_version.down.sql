@@ -0,0 +1,2 @@
+ALTER TABLE cid_themes DROP COLUMN row_version;
+ALTER TABLE docs DROP COLUMN row_version;
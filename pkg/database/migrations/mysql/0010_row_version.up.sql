@@ -0,0 +1,4 @@
+-- Compteur de modifications des documents et des thèmes, d'où sont
+-- dérivés les ETag de la concurrence optimiste (If-Match)
+ALTER TABLE docs ADD COLUMN row_version INT NOT NULL DEFAULT 1;
+ALTER TABLE cid_themes ADD COLUMN row_version INT NOT NULL DEFAULT 1;
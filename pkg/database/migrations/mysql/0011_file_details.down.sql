@@ -0,0 +1,4 @@
+ALTER TABLE files
+    DROP COLUMN tags,
+    DROP COLUMN description,
+    DROP COLUMN display_name;
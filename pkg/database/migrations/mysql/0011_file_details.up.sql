@@ -0,0 +1,6 @@
+-- Nom d'affichage, description et tags (séparés par des virgules) d'un
+-- fichier, modifiables sans nouvel envoi
+ALTER TABLE files
+    ADD COLUMN display_name VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN description VARCHAR(2048) NOT NULL DEFAULT '',
+    ADD COLUMN tags VARCHAR(1024) NOT NULL DEFAULT '';
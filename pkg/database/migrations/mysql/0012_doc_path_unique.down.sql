@@ -0,0 +1,3 @@
+ALTER TABLE docs
+    DROP INDEX uq_docs_parent_path,
+    DROP COLUMN parent_key;
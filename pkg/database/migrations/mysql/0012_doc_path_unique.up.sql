@@ -0,0 +1,15 @@
+-- Un chemin n'est porté que par un document par parent. Les doublons
+-- existants reçoivent le suffixe -<id>, le plus ancien garde le chemin.
+UPDATE docs d
+JOIN (
+    SELECT DISTINCT d2.id
+    FROM docs d1
+    JOIN docs d2 ON d2.path = d1.path AND COALESCE(d2.parent_id, 0) = COALESCE(d1.parent_id, 0) AND d2.id > d1.id
+) dup ON dup.id = d.id
+SET d.path = CONCAT(d.path, '-', d.id), d.row_version = d.row_version + 1, d.updated_at = d.updated_at;
+
+-- NULL ne se compare pas dans un index unique : parent_key ramène le
+-- premier niveau à 0
+ALTER TABLE docs
+    ADD COLUMN parent_key INT AS (COALESCE(parent_id, 0)) STORED,
+    ADD UNIQUE KEY uq_docs_parent_path (parent_key, path);
@@ -0,0 +1,4 @@
+ALTER TABLE docs
+    DROP COLUMN unpublish_at,
+    DROP COLUMN publish_at,
+    DROP COLUMN status;
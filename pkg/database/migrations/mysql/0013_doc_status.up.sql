@@ -0,0 +1,8 @@
+-- Circuit de publication des documents : statut et fenêtre de visibilité
+-- programmée. Les nouveaux documents sont des brouillons ; les documents
+-- existants, jusqu'ici tous visibles, sont publiés.
+ALTER TABLE docs
+    ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'draft',
+    ADD COLUMN publish_at DATETIME NULL,
+    ADD COLUMN unpublish_at DATETIME NULL;
+UPDATE docs SET status = 'published', updated_at = updated_at;
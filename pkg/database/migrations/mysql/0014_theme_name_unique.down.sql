@@ -0,0 +1 @@
+ALTER TABLE cid_themes DROP INDEX uq_cid_themes_name;
@@ -0,0 +1,12 @@
+-- Un nom n'est porté que par un thème, sans tenir compte de la casse (la
+-- collation utf8mb4 par défaut l'ignore). Les doublons existants reçoivent
+-- le suffixe -<id>, le plus ancien garde le nom.
+UPDATE cid_themes t
+JOIN (
+    SELECT DISTINCT t2.id
+    FROM cid_themes t1
+    JOIN cid_themes t2 ON t2.name = t1.name AND t2.id > t1.id
+) dup ON dup.id = t.id
+SET t.name = CONCAT(t.name, '-', t.id), t.row_version = t.row_version + 1;
+
+ALTER TABLE cid_themes ADD UNIQUE KEY uq_cid_themes_name (name);
@@ -0,0 +1 @@
+ALTER TABLE doc_revisions DROP COLUMN status;
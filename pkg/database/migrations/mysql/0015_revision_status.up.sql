@@ -0,0 +1,5 @@
+-- Statut du document à l'enregistrement de chaque révision : seules les
+-- révisions publiées sont lisibles sans clé en écriture. L'historique
+-- existant reprend le statut actuel de son document.
+ALTER TABLE doc_revisions ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'draft';
+UPDATE doc_revisions r JOIN docs d ON d.id = r.doc_id SET r.status = d.status;
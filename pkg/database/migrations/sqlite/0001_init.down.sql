@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS cid_themes;
+DROP TABLE IF EXISTS docs;
+DROP TABLE IF EXISTS files;
+DROP TABLE IF EXISTS api_keys;
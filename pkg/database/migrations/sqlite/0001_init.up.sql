@@ -0,0 +1,46 @@
+-- Schéma initial (SQLite), équivalent de mysql/0001_init.up.sql
+CREATE TABLE IF NOT EXISTS api_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    api_key TEXT NOT NULL UNIQUE,
+    permissions TEXT NOT NULL DEFAULT 'read',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS files (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    api_key_id INTEGER NOT NULL REFERENCES api_keys (id),
+    cid TEXT NOT NULL,
+    is_private BOOLEAN NOT NULL DEFAULT FALSE,
+    file_name TEXT NOT NULL,
+    mime_type TEXT NOT NULL,
+    file_size INTEGER NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_files_cid ON files (cid);
+CREATE INDEX IF NOT EXISTS idx_files_api_key_id ON files (api_key_id);
+
+CREATE TABLE IF NOT EXISTS docs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title TEXT NOT NULL,
+    path TEXT NOT NULL,
+    doc_src TEXT NOT NULL,
+    version REAL NOT NULL DEFAULT 0,
+    is_children BOOLEAN NOT NULL DEFAULT FALSE,
+    parent_id INTEGER NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_docs_parent_id ON docs (parent_id);
+
+-- SQLite n'a pas de ON UPDATE CURRENT_TIMESTAMP
+CREATE TRIGGER IF NOT EXISTS trg_docs_updated_at AFTER UPDATE ON docs
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE docs SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+CREATE TABLE IF NOT EXISTS cid_themes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    cid TEXT NOT NULL,
+    name TEXT NOT NULL
+);
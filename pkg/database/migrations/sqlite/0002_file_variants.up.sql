@@ -0,0 +1,13 @@
+-- Variantes redimensionnées des images, mises en cache sur IPFS
+CREATE TABLE IF NOT EXISTS file_variants (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    source_cid TEXT NOT NULL,
+    variant_key TEXT NOT NULL,
+    cid TEXT NOT NULL,
+    mime_type TEXT NOT NULL,
+    file_size INTEGER NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (source_cid, variant_key)
+);
@@ -0,0 +1,6 @@
+ALTER TABLE api_keys DROP COLUMN strip_metadata;
+
+ALTER TABLE files DROP COLUMN color_model;
+ALTER TABLE files DROP COLUMN orientation;
+ALTER TABLE files DROP COLUMN height;
+ALTER TABLE files DROP COLUMN width;
@@ -0,0 +1,8 @@
+-- Métadonnées des images (0 / '' : inconnues ou fichier non image) et
+-- suppression des métadonnées EXIF/XMP par défaut pour une clé
+ALTER TABLE files ADD COLUMN width INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE files ADD COLUMN height INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE files ADD COLUMN orientation INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE files ADD COLUMN color_model TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE api_keys ADD COLUMN strip_metadata BOOLEAN NOT NULL DEFAULT FALSE;
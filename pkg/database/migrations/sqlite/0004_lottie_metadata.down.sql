@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_files_kind;
+ALTER TABLE files DROP COLUMN asset_count;
+ALTER TABLE files DROP COLUMN layer_count;
+ALTER TABLE files DROP COLUMN duration;
+ALTER TABLE files DROP COLUMN frame_rate;
+ALTER TABLE files DROP COLUMN kind;
@@ -0,0 +1,8 @@
+-- Nature du fichier ('lottie' pour une animation validée, '' sinon) et
+-- métadonnées des animations ; les dimensions réutilisent width et height
+ALTER TABLE files ADD COLUMN kind TEXT NOT NULL DEFAULT '';
+ALTER TABLE files ADD COLUMN frame_rate REAL NOT NULL DEFAULT 0;
+ALTER TABLE files ADD COLUMN duration REAL NOT NULL DEFAULT 0;
+ALTER TABLE files ADD COLUMN layer_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE files ADD COLUMN asset_count INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_files_kind ON files (kind);
@@ -0,0 +1,2 @@
+ALTER TABLE files DROP COLUMN theme_count;
+ALTER TABLE files DROP COLUMN animation_count;
@@ -0,0 +1,4 @@
+-- Contenu des archives dotLottie (kind = 'dotlottie') ; les autres colonnes
+-- Lottie décrivent l'animation active de l'archive
+ALTER TABLE files ADD COLUMN animation_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE files ADD COLUMN theme_count INTEGER NOT NULL DEFAULT 0;
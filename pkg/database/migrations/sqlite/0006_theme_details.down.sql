@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS cid_theme_animations;
+
+ALTER TABLE cid_themes DROP COLUMN preview_cid;
+ALTER TABLE cid_themes DROP COLUMN enabled;
+ALTER TABLE cid_themes DROP COLUMN sort_order;
+ALTER TABLE cid_themes DROP COLUMN category;
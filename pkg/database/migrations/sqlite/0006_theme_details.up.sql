@@ -0,0 +1,17 @@
+-- Thèmes enrichis : catégorie, ordre d'affichage, activation et aperçu
+ALTER TABLE cid_themes ADD COLUMN category TEXT NOT NULL DEFAULT '';
+ALTER TABLE cid_themes ADD COLUMN sort_order INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE cid_themes ADD COLUMN enabled BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE cid_themes ADD COLUMN preview_cid TEXT NOT NULL DEFAULT '';
+
+-- Animations d'un thème par rôle (idle, loading, success, error...) et
+-- variante (light, dark ou '' pour la variante par défaut)
+CREATE TABLE IF NOT EXISTS cid_theme_animations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    theme_id INTEGER NOT NULL REFERENCES cid_themes (id) ON DELETE CASCADE,
+    role TEXT NOT NULL,
+    variant TEXT NOT NULL DEFAULT '',
+    cid TEXT NOT NULL,
+    UNIQUE (theme_id, role, variant)
+);
+CREATE INDEX IF NOT EXISTS idx_cid_theme_animations_cid ON cid_theme_animations (cid);
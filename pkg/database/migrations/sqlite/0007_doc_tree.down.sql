@@ -0,0 +1,5 @@
+ALTER TABLE docs ADD COLUMN is_children BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE docs SET is_children = (parent_id IS NOT NULL);
+
+DROP INDEX IF EXISTS idx_docs_parent_position;
+ALTER TABLE docs DROP COLUMN position;
@@ -0,0 +1,7 @@
+-- Ordre des documents entre frères ; les documents existants (position 0)
+-- gardent l'ordre de leur id
+ALTER TABLE docs ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_docs_parent_position ON docs (parent_id, position);
+
+-- is_children se déduit désormais de parent_id
+ALTER TABLE docs DROP COLUMN is_children;
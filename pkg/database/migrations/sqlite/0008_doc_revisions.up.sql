@@ -0,0 +1,28 @@
+-- Historique immuable des documents : chaque modification ajoute une
+-- révision, numérotée par le serveur
+CREATE TABLE IF NOT EXISTS doc_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    doc_id INTEGER NOT NULL REFERENCES docs (id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    path TEXT NOT NULL,
+    doc_src TEXT NOT NULL,
+    -- Clé auteur ; NULL pour l'état repris à la migration
+    api_key_id INTEGER NULL,
+    -- Version restaurée, le cas échéant
+    restored_from INTEGER NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (doc_id, version)
+);
+
+-- L'état actuel de chaque document devient sa révision 1. Le trigger est
+-- suspendu pour que la renumérotation garde updated_at.
+INSERT INTO doc_revisions (doc_id, version, title, path, doc_src, created_at)
+SELECT id, 1, title, path, doc_src, updated_at FROM docs;
+DROP TRIGGER IF EXISTS trg_docs_updated_at;
+UPDATE docs SET version = 1;
+CREATE TRIGGER IF NOT EXISTS trg_docs_updated_at AFTER UPDATE ON docs
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE docs SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_doc_revisions_cid;
+ALTER TABLE doc_revisions DROP COLUMN cid;
+ALTER TABLE docs DROP COLUMN cid;
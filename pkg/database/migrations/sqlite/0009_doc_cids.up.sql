@@ -0,0 +1,5 @@
+-- Contenu des documents sur IPFS : CID de la version actuelle et de chaque
+-- révision ('' pour les contenus antérieurs)
+ALTER TABLE docs ADD COLUMN cid TEXT NOT NULL DEFAULT '';
+ALTER TABLE doc_revisions ADD COLUMN cid TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_doc_revisions_cid ON doc_revisions (doc_id, cid);
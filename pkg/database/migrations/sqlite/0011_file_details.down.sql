@@ -0,0 +1,3 @@
+ALTER TABLE files DROP COLUMN tags;
+ALTER TABLE files DROP COLUMN description;
+ALTER TABLE files DROP COLUMN display_name;
@@ -0,0 +1,5 @@
+-- Nom d'affichage, description et tags (séparés par des virgules) d'un
+-- fichier, modifiables sans nouvel envoi
+ALTER TABLE files ADD COLUMN display_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE files ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE files ADD COLUMN tags TEXT NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS uq_docs_parent_path;
@@ -0,0 +1,18 @@
+-- Un chemin n'est porté que par un document par parent. Les doublons
+-- existants reçoivent le suffixe -<id>, le plus ancien garde le chemin. Le
+-- trigger est suspendu pour que le renommage garde updated_at.
+DROP TRIGGER IF EXISTS trg_docs_updated_at;
+UPDATE docs SET path = path || '-' || id, row_version = row_version + 1
+WHERE EXISTS (
+    SELECT 1 FROM docs d1
+    WHERE d1.path = docs.path AND COALESCE(d1.parent_id, 0) = COALESCE(docs.parent_id, 0) AND d1.id < docs.id
+);
+CREATE TRIGGER IF NOT EXISTS trg_docs_updated_at AFTER UPDATE ON docs
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE docs SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+-- NULL ne se compare pas dans un index unique : le premier niveau compte
+-- pour le parent 0
+CREATE UNIQUE INDEX IF NOT EXISTS uq_docs_parent_path ON docs (COALESCE(parent_id, 0), path);
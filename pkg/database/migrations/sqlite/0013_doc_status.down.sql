@@ -0,0 +1,3 @@
+ALTER TABLE docs DROP COLUMN unpublish_at;
+ALTER TABLE docs DROP COLUMN publish_at;
+ALTER TABLE docs DROP COLUMN status;
@@ -0,0 +1,14 @@
+-- Circuit de publication des documents : statut et fenêtre de visibilité
+-- programmée. Les nouveaux documents sont des brouillons ; les documents
+-- existants, jusqu'ici tous visibles, sont publiés. Le trigger est suspendu
+-- pour que la reprise garde updated_at.
+ALTER TABLE docs ADD COLUMN status TEXT NOT NULL DEFAULT 'draft';
+ALTER TABLE docs ADD COLUMN publish_at DATETIME;
+ALTER TABLE docs ADD COLUMN unpublish_at DATETIME;
+DROP TRIGGER IF EXISTS trg_docs_updated_at;
+UPDATE docs SET status = 'published';
+CREATE TRIGGER IF NOT EXISTS trg_docs_updated_at AFTER UPDATE ON docs
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE docs SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
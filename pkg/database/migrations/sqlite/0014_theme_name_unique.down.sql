@@ -0,0 +1 @@
+DROP INDEX IF EXISTS uq_cid_themes_name;
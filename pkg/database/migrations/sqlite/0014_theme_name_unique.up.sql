@@ -0,0 +1,9 @@
+-- Un nom n'est porté que par un thème, sans tenir compte de la casse. Les
+-- doublons existants reçoivent le suffixe -<id>, le plus ancien garde le nom.
+UPDATE cid_themes SET name = name || '-' || id, row_version = row_version + 1
+WHERE EXISTS (
+    SELECT 1 FROM cid_themes t1
+    WHERE LOWER(t1.name) = LOWER(cid_themes.name) AND t1.id < cid_themes.id
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_cid_themes_name ON cid_themes (LOWER(name));
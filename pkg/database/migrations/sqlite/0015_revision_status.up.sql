@@ -0,0 +1,5 @@
+-- Statut du document à l'enregistrement de chaque révision : seules les
+-- révisions publiées sont lisibles sans clé en écriture. L'historique
+-- existant reprend le statut actuel de son document.
+ALTER TABLE doc_revisions ADD COLUMN status TEXT NOT NULL DEFAULT 'draft';
+UPDATE doc_revisions SET status = COALESCE((SELECT status FROM docs WHERE docs.id = doc_revisions.doc_id), 'draft');
@@ -0,0 +1,26 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+
+	_ "modernc.org/sqlite"
+)
+
+// ConnectSQLite ouvre une base SQLite (driver pur Go), utilisée par les tests
+// et les installations mono-nœud
+func ConnectSQLite(path string) (*sql.DB, error) {
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite ne gère qu'un écrivain à la fois
+	db.SetMaxOpenConns(1)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	log.Println("Connected to the SQLite database successfully:", path)
+	return db, nil
+}
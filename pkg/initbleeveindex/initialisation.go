@@ -1,26 +1,21 @@
 package initbleeveindex
 import (
-	"database/sql"
+	"context"
 	"log"
 
 	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/handlers"
+	"github.com/TomPo62/bakiverse-ipfs-service-go/internal/store"
 	"github.com/blevesearch/bleve/v2"
 )
 
-func IndexInitialData(db *sql.DB, index bleve.Index) {
-	rows, err := db.Query("SELECT cid, file_name, mime_type FROM files WHERE is_private = false")
+func IndexInitialData(files store.FileRepository, index bleve.Index) {
+	publicFiles, err := files.AllPublic(context.Background())
 	if err != nil {
 			log.Fatal("Erreur lors de la récupération des fichiers :", err)
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-			var doc handler.FileDoc
-			if err := rows.Scan(&doc.CID, &doc.FileName, &doc.MimeType); err != nil {
-					log.Println("Erreur lors de la lecture d'un fichier :", err)
-					continue
-			}
-			doc.IsPrivate = false
+	for _, f := range publicFiles {
+			doc := handler.NewFileDoc(&f)
 
 			// Ajouter le fichier à l’index Bleve
 			if err := index.Index(doc.CID, doc); err != nil {
@@ -28,3 +23,12 @@ func IndexInitialData(db *sql.DB, index bleve.Index) {
 			}
 	}
 }
+
+// IndexInitialDocs reconstruit l'index des documents depuis la table docs
+func IndexInitialDocs(docs store.DocRepository, index bleve.Index) {
+	log.Println("Reconstruction de l'index des documents...")
+	if err := handler.IndexDocs(context.Background(), docs, index); err != nil {
+		log.Fatal("Erreur lors de l'indexation des documents :", err)
+	}
+	log.Println("Index des documents reconstruit.")
+}